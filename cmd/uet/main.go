@@ -10,8 +10,12 @@ import (
 	"strings"
 	"user_experience_toolkit/internal/config"
 	"user_experience_toolkit/internal/handlers"
+	"user_experience_toolkit/internal/history"
+	"user_experience_toolkit/internal/sessionstore"
 
 	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/extractors"
+	"github.com/gofiber/fiber/v3/middleware/csrf"
 	"github.com/gofiber/fiber/v3/middleware/session"
 )
 
@@ -46,14 +50,75 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
+	if cfg.EncryptionEnabled {
+		encryptionCfg, _ := cfg.Encryption.WithEnv() // already validated by LoadConfig
+		log.Printf("Config encryption: enabled, key provider: %s", encryptionCfg.ProviderName())
+	}
 
 	// Initialize Fiber app
 	app := fiber.New(fiber.Config{
 		Views: &templateEngine{},
 	})
 
-	// Setup session store
-	store := session.NewStore()
+	// Setup session store (backend, cookie and TTL from config.yaml or UET_SESSION_* env)
+	sessionCfg, err := cfg.Session.WithEnv()
+	if err != nil {
+		log.Fatalf("Invalid session configuration: %v", err)
+	}
+	sessionStorage, err := sessionstore.New(sessionCfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize session storage: %v", err)
+	}
+	idleTimeout, _ := sessionCfg.IdleTimeout()
+
+	store := session.NewStore(session.Config{
+		Storage:        sessionStorage,
+		IdleTimeout:    idleTimeout,
+		CookieSecure:   sessionCfg.CookieSecure,
+		CookieSameSite: sessionCfg.SameSite(),
+		CookieDomain:   sessionCfg.CookieDomain,
+		CookieHTTPOnly: true,
+	})
+	log.Printf("Session backend: %s, TTL: %s, cookie SameSite=%s Secure=%v", sessionCfg.BackendName(), idleTimeout, sessionCfg.SameSite(), sessionCfg.CookieSecure)
+
+	// Admin authentication for the configuration UI and API (admin section of config.yaml or UET_ADMIN_* env)
+	adminCfg := cfg.Admin.WithEnv()
+	if err := adminCfg.Validate(cfg.Applications); err != nil {
+		log.Fatalf("Invalid admin configuration: %v", err)
+	}
+	if adminCfg.Enabled() {
+		log.Printf("Admin authentication enabled: %d user(s), API token: %v, Duo second factor: %v", len(adminCfg.Users), adminCfg.HasAPIToken(), adminCfg.DuoEnabled())
+	} else {
+		log.Printf("WARNING: admin authentication is not configured; /configure, /history and their APIs are open to anyone who can reach this server")
+	}
+
+	// Admin logins use their own cookie so signing out of a demo application keeps the admin signed in
+	adminStore := session.NewStore(session.Config{
+		Storage:        sessionStorage,
+		IdleTimeout:    idleTimeout,
+		Extractor:      extractors.FromCookie("uet_admin"),
+		CookieSecure:   sessionCfg.CookieSecure,
+		CookieSameSite: sessionCfg.SameSite(),
+		CookieDomain:   sessionCfg.CookieDomain,
+		CookieHTTPOnly: true,
+	})
+
+	// Authentication history of the demo applications (history section of config.yaml or UET_HISTORY_* env)
+	historyCfg, err := cfg.History.WithEnv()
+	if err != nil {
+		log.Fatalf("Invalid history configuration: %v", err)
+	}
+	var historyStore *history.Store
+	if historyCfg.Disabled {
+		log.Printf("Authentication history disabled")
+	} else {
+		historyStore, err = history.Open(historyCfg.Path(configPath), historyCfg.Limit())
+		if err != nil {
+			log.Fatalf("Failed to open authentication history: %v", err)
+		}
+		handlers.SetHistoryStore(historyStore)
+		log.Printf("Authentication history: %s (keeping %d attempts)", historyCfg.Path(configPath), historyCfg.Limit())
+	}
 
 	// Setup static files from embedded filesystem
 	app.Get("/static/*", func(c fiber.Ctx) error {
@@ -71,27 +136,96 @@ func main() {
 		return c.Send(data)
 	})
 
+	// CSRF protection for everything except the IdP callbacks of the demo applications
+	// (handlers.SkipCSRF). The token is sent back in the X-Csrf-Token header
+	// (static/js/csrf.js) or a _csrf form field. Requests authenticated with a bearer
+	// token carry no ambient credentials and skip the check.
+	app.Use(csrf.New(csrf.Config{
+		Storage:        sessionStorage,
+		IdleTimeout:    idleTimeout,
+		CookieSecure:   sessionCfg.CookieSecure,
+		CookieSameSite: sessionCfg.SameSite(),
+		CookieDomain:   sessionCfg.CookieDomain,
+		Extractor: extractors.Chain(
+			extractors.FromHeader(csrf.HeaderName),
+			extractors.FromForm("_csrf"),
+		),
+		ErrorHandler: func(c fiber.Ctx, err error) error {
+			log.Printf("CSRF check failed for %s %s: %v", c.Method(), c.Path(), err)
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Invalid or missing CSRF token, reload the page and try again",
+			})
+		},
+		Next: func(c fiber.Ctx) bool {
+			if handlers.SkipCSRF(c) {
+				return true
+			}
+			_, hasBearer := handlers.BearerToken(c)
+			return hasBearer
+		},
+	}))
+
+	// Pages get the CSRF token as {{.CSRFToken}} for the _csrf field of their forms
+	app.Use(func(c fiber.Ctx) error {
+		if err := c.ViewBind(fiber.Map{"CSRFToken": csrf.TokenFromContext(c)}); err != nil {
+			return err
+		}
+		return c.Next()
+	})
+
 	// Initialize handlers
 	homeHandler := handlers.NewHomeHandler(cfg)
 	configHandler := handlers.NewConfigHandler(cfg)
+	adminHandler := handlers.NewAdminHandler(cfg, adminCfg, adminStore)
+	historyHandler := handlers.NewHistoryHandler(cfg, historyStore)
 
 	// Routes
 	app.Get("/", homeHandler.Index)
 
+	// Admin login routes
+	app.Get("/admin/login", adminHandler.ShowLogin)
+	app.Post("/admin/login", adminHandler.ProcessLogin)
+	app.Get("/admin/duo/callback", adminHandler.DuoCallback)
+	app.Post("/admin/logout", adminHandler.Logout)
+
 	// Configuration routes
-	app.Get("/configure", configHandler.Show)
+	app.Get("/configure", adminHandler.RequireAdmin, configHandler.Show)
 
 	// API routes for configuration management
-	app.Get("/api/config/applications", configHandler.ListApplications)
-	app.Post("/api/config/applications", configHandler.AddApplication)
-	app.Post("/api/config/applications/auto-create", configHandler.AutoCreateApplication)
-	app.Put("/api/config/applications/:id", configHandler.UpdateApplication)
-	app.Delete("/api/config/applications/:id", configHandler.DeleteApplication)
+	api := app.Group("/api/config", adminHandler.RequireAdmin)
+	api.Get("/applications", configHandler.ListApplications)
+	api.Post("/applications", configHandler.AddApplication)
+	api.Post("/applications/auto-create", configHandler.AutoCreateApplication)
+	api.Put("/applications/:id", configHandler.UpdateApplication)
+	api.Delete("/applications/:id", configHandler.DeleteApplication)
+	api.Post("/applications/:id/saml-metadata", configHandler.ImportSAMLMetadata)
+	api.Post("/applications/:id/reveal", configHandler.RevealApplicationSecrets)
+	api.Get("/applications/:id/integration", configHandler.GetIntegrationSettings)
+	api.Put("/applications/:id/integration", configHandler.UpdateIntegrationSettings)
+
+	// API routes for drift between config.yaml and the Duo tenants
+	api.Get("/drift", configHandler.CheckDrift)
+	api.Post("/applications/:id/drift/push", configHandler.PushDrift)
 
 	// API routes for tenant management
-	app.Get("/api/config/tenants", configHandler.ListTenants)
-	app.Post("/api/config/tenants", configHandler.AddTenant)
-	app.Delete("/api/config/tenants/:id", configHandler.DeleteTenant)
+	api.Get("/tenants", configHandler.ListTenants)
+	api.Post("/tenants", configHandler.AddTenant)
+	api.Put("/tenants/:id", configHandler.UpdateTenant)
+	api.Post("/tenants/:id/reveal", configHandler.RevealTenantSecret)
+	api.Post("/tenants/:id/rotation", configHandler.StartTenantRotation)
+	api.Post("/tenants/:id/rotation/confirm", configHandler.ConfirmTenantRotation)
+	api.Delete("/tenants/:id/rotation", configHandler.CancelTenantRotation)
+	api.Get("/tenants/:id/integrations", configHandler.ListTenantIntegrations)
+	api.Post("/tenants/:id/integrations/import", configHandler.ImportTenantIntegrations)
+	api.Delete("/tenants/:id", configHandler.DeleteTenant)
+
+	// Authentication history page and API
+	app.Get("/history", adminHandler.RequireAdmin, historyHandler.Show)
+	historyAPI := app.Group("/api/history", adminHandler.RequireAdmin)
+	historyAPI.Get("/", historyHandler.List)
+	historyAPI.Get("/export", historyHandler.Export)
+	historyAPI.Get("/:id", historyHandler.Get)
+	historyAPI.Delete("/", historyHandler.Clear)
 
 	// Dynamic application routes
 	app.All("/app/:id/*", func(c fiber.Ctx) error {
@@ -110,19 +244,7 @@ func main() {
 		}
 
 		// Route based on application type
-		appType := app.GetApplicationType()
-		switch appType {
-		case "dmp":
-			return handleDMPRequest(c, app, path, store)
-		case "saml":
-			return handleSAMLRequest(c, app, path, store)
-		case "oidc":
-			return handleOIDCRequest(c, app, path, store)
-		case "websdk":
-			return handleV4Request(c, app, path, store)
-		default:
-			return handleV4Request(c, app, path, store)
-		}
+		return handlers.ServeApplication(c, app, path, store)
 	})
 
 	// Start server
@@ -130,114 +252,6 @@ func main() {
 	log.Fatal(app.Listen(port))
 }
 
-// handleV4Request handles requests for V4 applications
-func handleV4Request(c fiber.Ctx, app *config.Application, path string, store *session.Store) error {
-	// Get base URL for redirect URI generation
-	baseURL := c.BaseURL()
-
-	handler, err := handlers.NewV4HandlerFromApp(app, store, baseURL)
-	if err != nil {
-		log.Printf("Failed to create V4 handler: %v", err)
-		return c.Status(fiber.StatusInternalServerError).SendString("Failed to initialize V4 handler")
-	}
-
-	switch {
-	case path == "" || path == "/":
-		if c.Method() == "GET" {
-			return handler.Login(c)
-		} else if c.Method() == "POST" {
-			return handler.ProcessLogin(c)
-		}
-	case path == "callback":
-		return handler.Callback(c)
-	}
-
-	return c.Status(fiber.StatusNotFound).SendString("Not found")
-}
-
-// handleDMPRequest handles requests for DMP applications
-func handleDMPRequest(c fiber.Ctx, app *config.Application, path string, store *session.Store) error {
-	// Get base URL for redirect URI generation
-	baseURL := c.BaseURL()
-
-	handler, err := handlers.NewDMPHandlerFromApp(app, store, baseURL)
-	if err != nil {
-		log.Printf("Failed to create DMP handler: %v", err)
-		return c.Status(fiber.StatusInternalServerError).SendString("Failed to initialize DMP handler")
-	}
-
-	switch {
-	case path == "" || path == "/":
-		if c.Method() == "GET" {
-			return handler.Login(c)
-		} else if c.Method() == "POST" {
-			return handler.ProcessLogin(c)
-		}
-	case path == "callback":
-		return handler.Callback(c)
-	}
-
-	return c.Status(fiber.StatusNotFound).SendString("Not found")
-}
-
-// handleSAMLRequest handles requests for SAML applications
-func handleSAMLRequest(c fiber.Ctx, app *config.Application, path string, store *session.Store) error {
-	// Get base URL for redirect URI generation
-	baseURL := c.BaseURL()
-
-	handler, err := handlers.NewSAMLHandlerFromApp(app, store, baseURL)
-	if err != nil {
-		log.Printf("Failed to create SAML handler: %v", err)
-		return c.Status(fiber.StatusInternalServerError).SendString("Failed to initialize SAML handler")
-	}
-
-	switch {
-	case path == "" || path == "/" || path == "saml" || path == "saml/":
-		return handler.Login(c)
-	case path == "saml/initiate":
-		return handler.InitiateSAML(c)
-	case path == "saml/acs":
-		if c.Method() == "POST" {
-			return handler.ACS(c)
-		}
-	case path == "saml/metadata":
-		return handler.Metadata(c)
-	case path == "saml/slo":
-		return handler.SLO(c)
-	case path == "saml/success":
-		return handler.Success(c)
-	}
-
-	return c.Status(fiber.StatusNotFound).SendString("Not found")
-}
-
-// handleOIDCRequest handles requests for OIDC applications
-func handleOIDCRequest(c fiber.Ctx, app *config.Application, path string, store *session.Store) error {
-	// Get base URL for redirect URI generation
-	baseURL := c.BaseURL()
-
-	handler, err := handlers.NewOIDCHandlerFromApp(app, store, baseURL)
-	if err != nil {
-		log.Printf("Failed to create OIDC handler: %v", err)
-		return c.Status(fiber.StatusInternalServerError).SendString("Failed to initialize OIDC handler")
-	}
-
-	switch {
-	case path == "" || path == "/" || path == "oidc" || path == "oidc/":
-		return handler.Login(c)
-	case path == "oidc/initiate":
-		return handler.InitiateOIDC(c)
-	case path == "oidc/callback":
-		return handler.Callback(c)
-	case path == "oidc/success":
-		return handler.Success(c)
-	case path == "oidc/logout":
-		return handler.Logout(c)
-	}
-
-	return c.Status(fiber.StatusNotFound).SendString("Not found")
-}
-
 // Custom template engine using html/template
 type templateEngine struct{}
 
@@ -0,0 +1,42 @@
+// Adds the CSRF token to state-changing same-origin fetch requests and sends the
+// browser to the admin login when the configuration API reports an expired login
+(function () {
+    const COOKIE_NAME = 'csrf_';
+    const HEADER_NAME = 'X-Csrf-Token';
+    const SAFE_METHODS = ['GET', 'HEAD', 'OPTIONS', 'TRACE'];
+
+    function getCSRFToken() {
+        const match = document.cookie.match(new RegExp('(?:^|; )' + COOKIE_NAME + '=([^;]*)'));
+        return match ? decodeURIComponent(match[1]) : '';
+    }
+
+    function isSameOrigin(url) {
+        return new URL(url, window.location.href).origin === window.location.origin;
+    }
+
+    const originalFetch = window.fetch.bind(window);
+
+    window.fetch = async function (input, init) {
+        init = init || {};
+        const url = input instanceof Request ? input.url : String(input);
+        const method = (init.method || (input instanceof Request ? input.method : 'GET')).toUpperCase();
+
+        if (!SAFE_METHODS.includes(method) && isSameOrigin(url)) {
+            const token = getCSRFToken();
+            if (token) {
+                const headers = new Headers(init.headers || (input instanceof Request ? input.headers : undefined));
+                headers.set(HEADER_NAME, token);
+                init = Object.assign({}, init, { headers: headers });
+            }
+        }
+
+        const response = await originalFetch(input, init);
+
+        // Only admin authentication failures carry WWW-Authenticate; a 401 from a Duo API call does not
+        if (response.status === 401 && isSameOrigin(url) && response.headers.has('WWW-Authenticate')) {
+            window.location.href = '/admin/login?next=' + encodeURIComponent(window.location.pathname + window.location.search);
+        }
+
+        return response;
+    };
+})();
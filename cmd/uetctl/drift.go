@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"user_experience_toolkit/internal/drift"
+)
+
+func runDrift(args []string) {
+	f := newFlags("drift", "", `Compares the applications in config.yaml with their integrations in Duo.
+Exits with 2 when drift remains, 1 on errors.`)
+	tenantID := f.String("tenant", "", "only check the applications of this tenant ID")
+	push := f.Bool("push", false, "push local entity IDs, ACS URLs and redirect URIs to Duo")
+	asJSON := f.Bool("json", false, "print the reports as JSON")
+	cfg := f.load(args, false)
+
+	if *tenantID != "" {
+		if _, err := cfg.GetTenant(*tenantID); err != nil {
+			fatalf("%v", err)
+		}
+	}
+
+	checker := drift.NewChecker(cfg)
+	reports := checker.Check(*tenantID)
+
+	failed := false
+	if *push {
+		for i, report := range reports {
+			if !report.Pushable() {
+				continue
+			}
+			pushed, err := checker.Push(report.ApplicationID)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to push %s: %v\n", report.ApplicationName, err)
+				failed = true
+				continue
+			}
+			reports[i] = pushed
+		}
+	}
+
+	if *asJSON {
+		printJSON(reports)
+	} else {
+		printDriftReports(reports)
+	}
+
+	drifted := false
+	for _, report := range reports {
+		switch report.Status {
+		case drift.StatusError:
+			failed = true
+		case drift.StatusDrift, drift.StatusMissing:
+			drifted = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+	if drifted {
+		os.Exit(2)
+	}
+}
+
+// printDriftReports writes one block per application
+func printDriftReports(reports []drift.Report) {
+	for _, report := range reports {
+		fmt.Printf("%-8s %s (%s, %s)\n", driftStatusLabel(report.Status), report.ApplicationName, report.Type, report.ApplicationID)
+		if report.Pushed {
+			fmt.Println("         pushed local settings to Duo")
+		}
+		if report.Error != "" {
+			fmt.Printf("         %s\n", report.Error)
+		}
+		for _, finding := range report.Findings {
+			fmt.Printf("         %s: %s\n", finding.Field, finding.Message)
+			if finding.Local != "" {
+				fmt.Printf("           local:  %s\n", finding.Local)
+			}
+			if len(finding.Remote) > 0 {
+				fmt.Printf("           duo:    %s\n", strings.Join(finding.Remote, ", "))
+			}
+		}
+	}
+	fmt.Printf("\n%s\n", drift.Summary(reports))
+}
+
+func driftStatusLabel(status string) string {
+	switch status {
+	case drift.StatusInSync:
+		return "OK"
+	case drift.StatusDrift:
+		return "DRIFT"
+	case drift.StatusMissing:
+		return "MISSING"
+	case drift.StatusSkipped:
+		return "SKIPPED"
+	default:
+		return "ERROR"
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"user_experience_toolkit/internal/config"
+	"user_experience_toolkit/internal/crypto"
+
+	"gopkg.in/yaml.v3"
+)
+
+func runEncrypt(args []string) {
+	f := newFlags("encrypt", "", "Turns on encryption_enabled and encrypts every secret with the master key\nof the configured key provider.")
+	cfg := f.load(args, false)
+
+	if err := cfg.SetEncryption(true); err != nil {
+		fatalf("Failed to encrypt secrets: %v", err)
+	}
+
+	fmt.Printf("✅ Encrypted secrets in %s with the %s key provider\n", f.configPath, keyProviderName(cfg))
+	fmt.Println("\nEncrypted fields:")
+	fmt.Println("  - admin_api_secret and pending_admin_api_secret (in tenants)")
+	fmt.Println("  - client_secret and signing_key (in applications)")
+}
+
+func runDecrypt(args []string) {
+	f := newFlags("decrypt", "", "Turns off encryption_enabled and writes every secret in plaintext.")
+	cfg := f.load(args, false)
+
+	if err := cfg.SetEncryption(false); err != nil {
+		fatalf("Failed to decrypt secrets: %v", err)
+	}
+
+	fmt.Printf("✅ Decrypted secrets in %s\n", f.configPath)
+	fmt.Println("\n⚠️  Secrets are now stored in plaintext. Run uetctl encrypt to encrypt them again.")
+}
+
+func runRotateKey(args []string) {
+	f := newFlags("rotate-key", "", `Re-encrypts every secret under a new master key.
+
+With the env key provider the new key is read from UET_NEW_MASTER_KEY. With the
+file, vault and age providers the new key is stored in -key-file, which is created
+when it does not exist yet, and config.yaml is updated to point at it.`)
+	provider := f.String("provider", "", "key provider of the new key (default: the current provider)")
+	keyFile := f.String("key-file", "", "key file of the new key")
+	cfg := f.load(args, false)
+
+	current, err := cfg.Encryption.WithEnv()
+	if err != nil {
+		fatalf("Invalid encryption settings: %v", err)
+	}
+
+	settings := cfg.Encryption
+	settings.KeyProvider = *provider
+	if settings.KeyProvider == "" {
+		settings.KeyProvider = current.ProviderName()
+		if settings.KeyProvider == config.KeyProviderEnv && *keyFile != "" {
+			settings.KeyProvider = config.KeyProviderFile
+		}
+	}
+	settings.KeyFile = *keyFile
+
+	var next *crypto.CryptoManager
+	if settings.ProviderName() == config.KeyProviderEnv {
+		newKey := os.Getenv("UET_NEW_MASTER_KEY")
+		if newKey == "" {
+			fatalf("UET_NEW_MASTER_KEY must be set to the new master key")
+		}
+		next = crypto.NewCryptoManagerWithKey(newKey)
+	} else {
+		if settings.KeyFile == "" {
+			fatalf("-key-file is required for the %s key provider", settings.ProviderName())
+		}
+		if settings.KeyFile == current.KeyFile {
+			fatalf("The new key file must differ from the current key file %s", current.KeyFile)
+		}
+		next = newKeyManager(settings)
+	}
+
+	if err := cfg.RotateKeyTo(settings, next); err != nil {
+		fatalf("Failed to rotate key: %v", err)
+	}
+
+	keyID, err := next.KeyID()
+	if err != nil {
+		fatalf("Failed to read the new key ID: %v", err)
+	}
+	fmt.Printf("✅ Re-encrypted secrets in %s under key %s\n", f.configPath, keyID)
+	if settings.ProviderName() == config.KeyProviderEnv {
+		fmt.Println("\nSet UET_MASTER_KEY to the new key before starting the toolkit.")
+	}
+	for _, name := range []string{"UET_KEY_PROVIDER", "UET_KEY_FILE"} {
+		if os.Getenv(name) != "" {
+			fmt.Printf("\n⚠️  %s is set and overrides config.yaml; update it before starting the toolkit.\n", name)
+		}
+	}
+}
+
+// newKeyManager returns a crypto manager for the key in settings.KeyFile, creating the
+// key first when the file does not exist
+func newKeyManager(settings config.EncryptionConfig) *crypto.CryptoManager {
+	// Environment overrides supply the Vault token; the new key file always wins
+	resolved, err := settings.WithEnv()
+	if err != nil {
+		fatalf("Invalid encryption settings: %v", err)
+	}
+	resolved.KeyProvider, resolved.KeyFile = settings.KeyProvider, settings.KeyFile
+
+	provider, err := resolved.Provider()
+	if err != nil {
+		fatalf("Failed to set up key provider: %v", err)
+	}
+	if _, err := os.Stat(resolved.KeyFile); os.IsNotExist(err) {
+		creator, ok := provider.(crypto.KeyCreator)
+		if !ok {
+			fatalf("The %s key provider cannot create keys", resolved.ProviderName())
+		}
+		if _, err := creator.CreateKey(); err != nil {
+			fatalf("Failed to create key: %v", err)
+		}
+		fmt.Printf("Created new master key in %s\n", resolved.KeyFile)
+	}
+
+	cm, err := crypto.NewCryptoManagerFromProvider(provider)
+	if err != nil {
+		fatalf("Failed to load new key: %v", err)
+	}
+	return cm
+}
+
+func runInitKey(args []string) {
+	f := newFlags("init-key", "", `Creates the master key of the key provider configured in config.yaml (and
+UET_* environment variables) so encryption can be turned on. For the default env
+provider this is .uet_key in the working directory. An existing key is never
+overwritten.`)
+	f.parse(args)
+
+	// The key does not exist yet, so LoadConfig cannot decrypt an encrypted file;
+	// only the encryption settings are read
+	var settings struct {
+		Encryption config.EncryptionConfig `yaml:"encryption"`
+	}
+	data, err := os.ReadFile(f.configPath)
+	if err != nil && !os.IsNotExist(err) {
+		fatalf("Failed to read config: %v", err)
+	}
+	if err := yaml.Unmarshal(data, &settings); err != nil {
+		fatalf("Failed to parse encryption settings: %v", err)
+	}
+
+	resolved, err := settings.Encryption.WithEnv()
+	if err != nil {
+		fatalf("Invalid encryption settings: %v", err)
+	}
+	if resolved.ProviderName() == config.KeyProviderEnv {
+		if _, err := (crypto.EnvKeyProvider{}).CreateKey(); err != nil {
+			fatalf("Failed to create key: %v", err)
+		}
+		fmt.Printf("✅ Created new master key in %s for the env key provider; back it up, or set UET_MASTER_KEY instead\n", crypto.EnvKeyFile)
+		return
+	}
+	if _, err := os.Stat(resolved.KeyFile); err == nil {
+		fatalf("Key file %s already exists", resolved.KeyFile)
+	}
+
+	newKeyManager(resolved)
+	fmt.Printf("✅ Master key is ready for the %s key provider\n", resolved.ProviderName())
+}
+
+// keyProviderName returns the key provider in effect, including environment overrides
+func keyProviderName(cfg *config.Config) string {
+	resolved, err := cfg.Encryption.WithEnv()
+	if err != nil {
+		return cfg.Encryption.ProviderName()
+	}
+	return resolved.ProviderName()
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"user_experience_toolkit/internal/config"
+)
+
+const usage = `Usage: uetctl <command> [options]
+
+Manages config.yaml without the web UI, e.g. from CI.
+
+Configuration:
+  validate              Check tenants, applications and settings
+  encrypt               Turn on encryption and encrypt every secret
+  decrypt               Turn off encryption and write secrets in plaintext
+  rotate-key            Re-encrypt every secret under a new master key
+  init-key              Create the master key of the configured key provider
+  migrate               Upgrade config.yaml to the current schema version
+  drift                 Compare applications with their integrations in Duo
+
+Tenants:
+  tenant list           List tenants
+  tenant add            Add a tenant with its Admin API credentials
+  tenant remove <id>    Remove a tenant and its applications
+
+Applications:
+  app list              List applications
+  app add               Add an application from flags or a YAML/JSON file
+  app create            Create an integration in Duo and add it as an application
+  app remove <id>       Remove an application
+
+Every command takes -config (default: UET_CONFIG_PATH, then ./config.yaml) and
+-v to show log output. Run "uetctl <command> -h" for its options.`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+
+	args := os.Args[2:]
+	switch os.Args[1] {
+	case "validate":
+		runValidate(args)
+	case "encrypt":
+		runEncrypt(args)
+	case "decrypt":
+		runDecrypt(args)
+	case "rotate-key":
+		runRotateKey(args)
+	case "init-key":
+		runInitKey(args)
+	case "migrate":
+		runMigrate(args)
+	case "drift":
+		runDrift(args)
+	case "tenant":
+		runTenant(args)
+	case "app":
+		runApp(args)
+	case "-h", "-help", "--help", "help":
+		fmt.Println(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n%s\n", os.Args[1], usage)
+		os.Exit(1)
+	}
+}
+
+// commandFlags holds the options of one command, including the shared -config and -v
+type commandFlags struct {
+	*flag.FlagSet
+	configPath string
+	verbose    bool
+}
+
+// newFlags creates the flag set of a command. args and summary are shown by -h.
+func newFlags(name, args, summary string) *commandFlags {
+	f := &commandFlags{FlagSet: flag.NewFlagSet(name, flag.ExitOnError)}
+	f.StringVar(&f.configPath, "config", defaultConfigPath(), "config file")
+	f.BoolVar(&f.verbose, "v", false, "show log output")
+	f.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: uetctl %s [options]", name)
+		if args != "" {
+			fmt.Fprintf(os.Stderr, " %s", args)
+		}
+		fmt.Fprintf(os.Stderr, "\n\n%s\n\nOptions:\n", summary)
+		f.PrintDefaults()
+	}
+	return f
+}
+
+// parse parses the command line and silences logging unless -v is set
+func (f *commandFlags) parse(args []string) {
+	// ExitOnError: Parse exits on invalid flags
+	_ = f.Parse(args)
+	if !f.verbose {
+		log.SetOutput(io.Discard)
+	}
+}
+
+// load parses the command line and loads the configuration. Only commands that add to
+// the configuration pass create, since LoadConfig creates missing files.
+func (f *commandFlags) load(args []string, create bool) *config.Config {
+	f.parse(args)
+
+	if !create {
+		if _, err := os.Stat(f.configPath); err != nil {
+			fatalf("Failed to read config: %v", err)
+		}
+	}
+
+	cfg, err := config.LoadConfig(f.configPath)
+	if err != nil {
+		fatalf("Failed to load config: %v", err)
+	}
+	return cfg
+}
+
+// arg returns the single positional argument of a command, or prints its usage
+func (f *commandFlags) arg() string {
+	if f.NArg() != 1 {
+		f.Usage()
+		os.Exit(1)
+	}
+	return f.Arg(0)
+}
+
+func defaultConfigPath() string {
+	if path := os.Getenv("UET_CONFIG_PATH"); path != "" {
+		return path
+	}
+	return "config.yaml"
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func printJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		fatalf("Failed to encode output: %v", err)
+	}
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
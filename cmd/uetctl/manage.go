@@ -0,0 +1,266 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"user_experience_toolkit/internal/config"
+	"user_experience_toolkit/internal/duoadmin"
+	"user_experience_toolkit/internal/provision"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+func runValidate(args []string) {
+	f := newFlags("validate", "", "Checks every tenant and application, the references between them and the\nsession, history, admin and encryption settings. Exits with 1 when problems are found.")
+	cfg := f.load(args, false)
+
+	err := cfg.Validate()
+	if err == nil {
+		fmt.Printf("✅ %s is valid: %d tenants, %d applications\n", f.configPath, len(cfg.GetAllTenants()), len(cfg.GetAllApplications()))
+		return
+	}
+
+	problems := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		problems = joined.Unwrap()
+	}
+	fmt.Fprintf(os.Stderr, "❌ %s has %d problem(s):\n", f.configPath, len(problems))
+	for _, problem := range problems {
+		fmt.Fprintf(os.Stderr, "  - %v\n", problem)
+	}
+	os.Exit(1)
+}
+
+func runTenant(args []string) {
+	if len(args) == 0 {
+		fatalf("Usage: uetctl tenant list|add|remove [options]")
+	}
+
+	switch args[0] {
+	case "list":
+		f := newFlags("tenant list", "", "Lists tenants. Secrets are redacted.")
+		asJSON := f.Bool("json", false, "print the tenants as JSON")
+		cfg := f.load(args[1:], false)
+
+		tenants := cfg.GetAllTenants()
+		if *asJSON {
+			for i := range tenants {
+				tenants[i] = tenants[i].Redacted()
+			}
+			printJSON(tenants)
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tAPI HOSTNAME\tAPPLICATIONS")
+		for _, tenant := range tenants {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", tenant.ID, tenant.Name, tenant.APIHostname, len(cfg.GetApplicationsByTenant(tenant.ID)))
+		}
+		w.Flush()
+
+	case "add":
+		f := newFlags("tenant add", "", "Adds a tenant. The Admin API secret is read from UET_ADMIN_API_SECRET so it stays\nout of shell history.")
+		id := f.String("id", "", "tenant ID (default: a new UUID)")
+		name := f.String("name", "", "tenant name")
+		hostname := f.String("api-hostname", "", "API hostname, e.g. api-xxxxxxxx.duosecurity.com")
+		adminAPIKey := f.String("admin-api-key", "", "Admin API integration key")
+		check := f.Bool("check", true, "validate the credentials with the Duo Admin API first")
+		cfg := f.load(args[1:], true)
+
+		tenant := config.Tenant{
+			ID:             *id,
+			Name:           *name,
+			AdminAPIKey:    *adminAPIKey,
+			AdminAPISecret: os.Getenv("UET_ADMIN_API_SECRET"),
+			APIHostname:    *hostname,
+		}
+		if tenant.ID == "" {
+			tenant.ID = uuid.New().String()
+		}
+		if *check {
+			adminClient := duoadmin.NewClient(tenant.AdminAPIKey, tenant.AdminAPISecret, tenant.APIHostname)
+			if err := adminClient.ValidateCredentials(); err != nil {
+				fatalf("Invalid Admin API credentials or insufficient permissions: %v", err)
+			}
+		}
+		if err := cfg.AddTenant(tenant); err != nil {
+			fatalf("Failed to add tenant: %v", err)
+		}
+		fmt.Printf("✅ Added tenant %s (%s)\n", tenant.Name, tenant.ID)
+
+	case "remove":
+		f := newFlags("tenant remove", "<id>", "Removes a tenant and all of its applications.")
+		cfg := f.load(args[1:], false)
+		id := f.arg()
+
+		apps := len(cfg.GetApplicationsByTenant(id))
+		if err := cfg.DeleteTenant(id); err != nil {
+			fatalf("Failed to remove tenant: %v", err)
+		}
+		fmt.Printf("✅ Removed tenant %s and %d application(s)\n", id, apps)
+
+	default:
+		fatalf("Unknown tenant command: %s (must be one of: list, add, remove)", args[0])
+	}
+}
+
+func runApp(args []string) {
+	if len(args) == 0 {
+		fatalf("Usage: uetctl app list|add|create|remove [options]")
+	}
+
+	switch args[0] {
+	case "list":
+		f := newFlags("app list", "", "Lists applications. Secrets are redacted.")
+		tenantID := f.String("tenant", "", "only list the applications of this tenant ID")
+		asJSON := f.Bool("json", false, "print the applications as JSON")
+		cfg := f.load(args[1:], false)
+
+		apps := cfg.GetAllApplications()
+		if *tenantID != "" {
+			apps = cfg.GetApplicationsByTenant(*tenantID)
+		}
+		if *asJSON {
+			printJSON(config.RedactApplications(apps))
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tTYPE\tENABLED\tTENANT")
+		for _, app := range apps {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%s\n", app.ID, app.Name, app.GetApplicationType(), app.Enabled, app.TenantID)
+		}
+		w.Flush()
+
+	case "add":
+		runAppAdd(args[1:])
+
+	case "create":
+		runAppCreate(args[1:])
+
+	case "remove":
+		f := newFlags("app remove", "<id>", "Removes an application from config.yaml. The integration in Duo is kept.")
+		cfg := f.load(args[1:], false)
+		id := f.arg()
+
+		if err := cfg.DeleteApplication(id); err != nil {
+			fatalf("Failed to remove application: %v", err)
+		}
+		fmt.Printf("✅ Removed application %s\n", id)
+
+	default:
+		fatalf("Unknown app command: %s (must be one of: list, add, create, remove)", args[0])
+	}
+}
+
+// runAppAdd adds an existing Duo integration as an application, from flags or from a
+// YAML or JSON file with the fields of an applications entry
+func runAppAdd(args []string) {
+	f := newFlags("app add", "", `Adds an existing Duo integration as an application. Simple applications can be
+described with flags; SAML and OIDC applications need -file, a YAML or JSON file
+(or - for stdin) with the fields of an applications entry in config.yaml. The
+client secret is read from UET_CLIENT_SECRET when it is not in the file.`)
+	file := f.String("file", "", "YAML or JSON file describing the application")
+	tenantID := f.String("tenant", "", "tenant ID; also supplies the API hostname")
+	name := f.String("name", "", "application name")
+	appType := f.String("type", "", "application type, e.g. websdk, dmp or authapi")
+	clientID := f.String("client-id", "", "client ID (integration key)")
+	hostname := f.String("api-hostname", "", "API hostname (default: the tenant's)")
+	enabled := f.Bool("enabled", true, "enable the application")
+	cfg := f.load(args, true)
+
+	app := config.Application{
+		TenantID:    *tenantID,
+		Name:        *name,
+		Type:        *appType,
+		Enabled:     *enabled,
+		ClientID:    *clientID,
+		APIHostname: *hostname,
+	}
+	if *file != "" {
+		app = readApplication(*file)
+	}
+	if app.ClientSecret == "" {
+		app.ClientSecret = os.Getenv("UET_CLIENT_SECRET")
+	}
+	if app.TenantID != "" {
+		tenant, err := cfg.GetTenant(app.TenantID)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		if app.APIHostname == "" {
+			app.APIHostname = tenant.APIHostname
+		}
+	}
+	if app.ID == "" {
+		app.ID = uuid.New().String()
+	}
+
+	if err := cfg.AddApplication(app); err != nil {
+		fatalf("Failed to add application: %v", err)
+	}
+	fmt.Printf("✅ Added %s application %s (%s)\n", app.GetApplicationType(), app.Name, app.ID)
+}
+
+// readApplication parses an application from a YAML or JSON file, or stdin for "-"
+func readApplication(path string) config.Application {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		fatalf("Failed to read application: %v", err)
+	}
+
+	// JSON is valid YAML, and the yaml and json tags of Application use the same names
+	var app config.Application
+	if err := yaml.Unmarshal(data, &app); err != nil {
+		fatalf("Failed to parse application: %v", err)
+	}
+	return app
+}
+
+// runAppCreate creates the integration through the Duo Admin API, like auto-create in
+// the configuration UI
+func runAppCreate(args []string) {
+	f := newFlags("app create", "", `Creates an integration in Duo with the tenant's Admin API credentials and adds it
+as an application, like auto-create in the configuration UI. The name is prefixed
+with the tenant name.`)
+	tenantID := f.String("tenant", "", "tenant ID")
+	name := f.String("name", "", "application name")
+	appType := f.String("type", "websdk", "application type: websdk, dmp, saml, oidc, authapi or websdkv2")
+	enabled := f.Bool("enabled", true, "enable the integration and the application")
+	baseURL := f.String("base-url", "", "external URL of the toolkit, e.g. https://uet.example.com; required for saml and oidc")
+	scopes := f.String("scopes", "", "OIDC scopes in addition to openid, comma-separated")
+	pkce := f.String("pkce", "", "OIDC PKCE mode: s256 or public")
+	asJSON := f.Bool("json", false, "print the new application as JSON")
+	cfg := f.load(args, false)
+
+	app, err := provision.NewProvisioner(cfg).CreateApplication(provision.Request{
+		Name:     *name,
+		Type:     *appType,
+		Enabled:  *enabled,
+		TenantID: *tenantID,
+		BaseURL:  *baseURL,
+		Scopes:   splitList(*scopes),
+		PKCEMode: *pkce,
+	})
+	if err != nil {
+		if errors.Is(err, provision.ErrInvalidRequest) {
+			f.Usage()
+		}
+		fatalf("Failed to create application: %v", err)
+	}
+
+	if *asJSON {
+		printJSON(app.Redacted())
+		return
+	}
+	fmt.Printf("✅ Created %s application %s (%s)\n", app.GetApplicationType(), app.Name, app.ID)
+}
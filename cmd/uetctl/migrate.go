@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"user_experience_toolkit/internal/config"
+)
+
+func runMigrate(args []string) {
+	f := newFlags("migrate", "", `Upgrades config.yaml to the schema version of this build. The previous file is
+kept as <config>.v<version>.bak. With -dry-run, prints the steps and a diff with
+secrets redacted, and changes nothing.`)
+	dryRun := f.Bool("dry-run", false, "show the changes without writing them")
+	f.parse(args)
+
+	plan, err := config.PlanMigration(f.configPath)
+	if err != nil {
+		fatalf("Failed to plan migration: %v", err)
+	}
+	if !plan.Pending() {
+		fmt.Printf("✅ %s is already at version %d\n", f.configPath, plan.ToVersion)
+		return
+	}
+
+	fmt.Printf("Migrating %s from version %d to %d:\n", f.configPath, plan.FromVersion, plan.ToVersion)
+	for _, step := range plan.Steps {
+		fmt.Printf("  - %s\n", step)
+	}
+
+	if *dryRun {
+		fmt.Println()
+		printDiff(os.Stdout,
+			fmt.Sprintf("%s (version %d)", f.configPath, plan.FromVersion),
+			fmt.Sprintf("%s (version %d)", f.configPath, plan.ToVersion),
+			plan.Before, plan.After)
+		fmt.Println("\nDry run: nothing was written.")
+		return
+	}
+
+	// LoadConfig migrates in memory; saving writes the backup and the new file
+	cfg, err := config.LoadConfig(f.configPath)
+	if err != nil {
+		fatalf("Failed to load config: %v", err)
+	}
+	if err := cfg.Save(); err != nil {
+		fatalf("Failed to save migrated config: %v", err)
+	}
+
+	fmt.Printf("\n✅ Migrated %s; the previous file is in %s\n", f.configPath, config.BackupPath(f.configPath, plan.FromVersion))
+}
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"os"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// dummyPasswordHash is compared against when the username is unknown so that a failed
+// login takes the same time whether or not the account exists
+var dummyPasswordHash = []byte("$2a$10$hCoxKYj3ZTnrly9zr6/Oe.xlSxv1nF5tE8sqSHCYbbLuBJY4G8Ghu")
+
+// AdminConfig protects the configuration UI and the /api/config endpoints.
+// Authentication is disabled while no user and no API token are configured.
+type AdminConfig struct {
+	Users        []AdminUser `yaml:"users,omitempty" json:"users,omitempty"`
+	APITokenHash string      `yaml:"api_token_hash,omitempty" json:"-"`                // bcrypt hash of the static bearer token
+	DuoAppID     string      `yaml:"duo_app_id,omitempty" json:"duo_app_id,omitempty"` // WebSDK application used as a second factor for admin logins
+
+	// apiToken is the plain bearer token from UET_ADMIN_TOKEN; it is never persisted
+	apiToken string
+}
+
+// AdminUser is a local administrator account
+type AdminUser struct {
+	Username     string `yaml:"username" json:"username"`
+	PasswordHash string `yaml:"password_hash" json:"-"` // bcrypt hash, e.g. from `htpasswd -bnBC 12 "" <password>`
+}
+
+// WithEnv returns a copy of the admin configuration with UET_ADMIN_TOKEN and
+// UET_ADMIN_DUO_APP_ID applied on top of the values from config.yaml
+func (a AdminConfig) WithEnv() AdminConfig {
+	if v := os.Getenv("UET_ADMIN_TOKEN"); v != "" {
+		a.apiToken = v
+	}
+	if v := os.Getenv("UET_ADMIN_DUO_APP_ID"); v != "" {
+		a.DuoAppID = v
+	}
+	return a
+}
+
+// Enabled reports whether any admin credential is configured
+func (a AdminConfig) Enabled() bool {
+	return len(a.Users) > 0 || a.HasAPIToken()
+}
+
+// HasAPIToken reports whether a bearer token is accepted for the /api/config endpoints
+func (a AdminConfig) HasAPIToken() bool {
+	return a.APITokenHash != "" || a.apiToken != ""
+}
+
+// DuoEnabled reports whether admin logins require a Duo second factor
+func (a AdminConfig) DuoEnabled() bool {
+	return a.DuoAppID != ""
+}
+
+// Validate checks the admin accounts and, when Duo protection is enabled, that the
+// referenced application exists among apps and is a WebSDK application
+func (a AdminConfig) Validate(apps []Application) error {
+	seen := make(map[string]bool)
+	for _, user := range a.Users {
+		if user.Username == "" {
+			return fmt.Errorf("admin user is missing a username")
+		}
+		if seen[user.Username] {
+			return fmt.Errorf("duplicate admin user: %s", user.Username)
+		}
+		seen[user.Username] = true
+
+		if _, err := bcrypt.Cost([]byte(user.PasswordHash)); err != nil {
+			return fmt.Errorf("admin user %s: password_hash is not a bcrypt hash", user.Username)
+		}
+	}
+
+	if a.APITokenHash != "" {
+		if _, err := bcrypt.Cost([]byte(a.APITokenHash)); err != nil {
+			return fmt.Errorf("admin api_token_hash is not a bcrypt hash")
+		}
+	}
+
+	if a.DuoAppID != "" {
+		if len(a.Users) == 0 {
+			return fmt.Errorf("admin duo_app_id requires at least one admin user")
+		}
+		var app *Application
+		for i := range apps {
+			if apps[i].ID == a.DuoAppID {
+				app = &apps[i]
+				break
+			}
+		}
+		if app == nil {
+			return fmt.Errorf("admin duo_app_id %s does not match any application", a.DuoAppID)
+		}
+		if app.GetApplicationType() != "websdk" {
+			return fmt.Errorf("admin duo_app_id %s must reference a websdk application, not %s", a.DuoAppID, app.GetApplicationType())
+		}
+	}
+
+	return nil
+}
+
+// Authenticate checks a username and password against the configured admin users
+func (a AdminConfig) Authenticate(username, password string) bool {
+	for _, user := range a.Users {
+		if user.Username == username {
+			return bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) == nil
+		}
+	}
+
+	bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+	return false
+}
+
+// VerifyToken checks a bearer token against UET_ADMIN_TOKEN or api_token_hash
+func (a AdminConfig) VerifyToken(token string) bool {
+	if token == "" {
+		return false
+	}
+	if a.apiToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(a.apiToken)) == 1 {
+		return true
+	}
+	if a.APITokenHash != "" && bcrypt.CompareHashAndPassword([]byte(a.APITokenHash), []byte(token)) == nil {
+		return true
+	}
+	return false
+}
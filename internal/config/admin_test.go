@@ -0,0 +1,98 @@
+package config
+
+import (
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func mustHash(t *testing.T, secret string) string {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("failed to hash: %v", err)
+	}
+	return string(hash)
+}
+
+func TestAdminConfigEnabled(t *testing.T) {
+	if (AdminConfig{}).Enabled() {
+		t.Error("Expected empty admin config to be disabled")
+	}
+	if !(AdminConfig{Users: []AdminUser{{Username: "admin"}}}).Enabled() {
+		t.Error("Expected admin config with users to be enabled")
+	}
+	if !(AdminConfig{APITokenHash: "$2a$04$x"}).Enabled() {
+		t.Error("Expected admin config with token hash to be enabled")
+	}
+
+	t.Setenv("UET_ADMIN_TOKEN", "env-token")
+	admin := AdminConfig{}.WithEnv()
+	if !admin.Enabled() || !admin.HasAPIToken() {
+		t.Error("Expected UET_ADMIN_TOKEN to enable admin authentication")
+	}
+}
+
+func TestAdminConfigValidate(t *testing.T) {
+	hash := mustHash(t, "secret")
+	apps := []Application{
+		{ID: "sdk", Type: "websdk"},
+		{ID: "sso", Type: "oidc"},
+	}
+
+	tests := []struct {
+		name    string
+		admin   AdminConfig
+		wantErr bool
+	}{
+		{"empty", AdminConfig{}, false},
+		{"valid user", AdminConfig{Users: []AdminUser{{Username: "admin", PasswordHash: hash}}}, false},
+		{"missing username", AdminConfig{Users: []AdminUser{{PasswordHash: hash}}}, true},
+		{"duplicate user", AdminConfig{Users: []AdminUser{{Username: "admin", PasswordHash: hash}, {Username: "admin", PasswordHash: hash}}}, true},
+		{"plain text password", AdminConfig{Users: []AdminUser{{Username: "admin", PasswordHash: "secret"}}}, true},
+		{"invalid token hash", AdminConfig{APITokenHash: "token"}, true},
+		{"duo websdk app", AdminConfig{Users: []AdminUser{{Username: "admin", PasswordHash: hash}}, DuoAppID: "sdk"}, false},
+		{"duo without users", AdminConfig{APITokenHash: hash, DuoAppID: "sdk"}, true},
+		{"duo unknown app", AdminConfig{Users: []AdminUser{{Username: "admin", PasswordHash: hash}}, DuoAppID: "missing"}, true},
+		{"duo non websdk app", AdminConfig{Users: []AdminUser{{Username: "admin", PasswordHash: hash}}, DuoAppID: "sso"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.admin.Validate(apps)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAdminConfigAuthenticate(t *testing.T) {
+	admin := AdminConfig{Users: []AdminUser{{Username: "admin", PasswordHash: mustHash(t, "secret")}}}
+
+	if !admin.Authenticate("admin", "secret") {
+		t.Error("Expected valid credentials to authenticate")
+	}
+	if admin.Authenticate("admin", "wrong") {
+		t.Error("Expected wrong password to be rejected")
+	}
+	if admin.Authenticate("nobody", "secret") {
+		t.Error("Expected unknown user to be rejected")
+	}
+}
+
+func TestAdminConfigVerifyToken(t *testing.T) {
+	admin := AdminConfig{APITokenHash: mustHash(t, "hashed-token")}
+	if !admin.VerifyToken("hashed-token") {
+		t.Error("Expected token matching api_token_hash to be accepted")
+	}
+	if admin.VerifyToken("other") || admin.VerifyToken("") {
+		t.Error("Expected other tokens to be rejected")
+	}
+
+	t.Setenv("UET_ADMIN_TOKEN", "env-token")
+	admin = admin.WithEnv()
+	if !admin.VerifyToken("env-token") || !admin.VerifyToken("hashed-token") {
+		t.Error("Expected both the env token and the hashed token to be accepted")
+	}
+}
@@ -1,10 +1,12 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"user_experience_toolkit/internal/crypto"
 
@@ -12,6 +14,13 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+// PKCE modes for OIDC applications
+const (
+	PKCEModeOff    = ""       // Authorization code flow authenticated with the client secret
+	PKCEModeS256   = "s256"   // Client secret plus an S256 code challenge
+	PKCEModePublic = "public" // Public client: S256 code challenge, no client secret
+)
+
 // Tenant represents a Duo tenant with Admin API credentials
 type Tenant struct {
 	ID             string `yaml:"id" json:"id"`
@@ -19,6 +28,12 @@ type Tenant struct {
 	AdminAPIKey    string `yaml:"admin_api_key" json:"admin_api_key"`
 	AdminAPISecret string `yaml:"admin_api_secret" json:"admin_api_secret"`
 	APIHostname    string `yaml:"api_hostname" json:"api_hostname"`
+
+	// Admin API credentials staged by a rotation; the active credentials above stay
+	// in use until the rotation is confirmed
+	PendingAdminAPIKey    string `yaml:"pending_admin_api_key,omitempty" json:"pending_admin_api_key,omitempty"`
+	PendingAdminAPISecret string `yaml:"pending_admin_api_secret,omitempty" json:"pending_admin_api_secret,omitempty"`
+	RotationStartedAt     string `yaml:"rotation_started_at,omitempty" json:"rotation_started_at,omitempty"`
 }
 
 // Application represents a single Duo application configuration
@@ -26,7 +41,7 @@ type Application struct {
 	ID           string `yaml:"id" json:"id"`
 	TenantID     string `yaml:"tenant_id,omitempty" json:"tenant_id,omitempty"` // Optional: references tenant
 	Name         string `yaml:"name" json:"name"`
-	Type         string `yaml:"type" json:"type"`                         // "websdk", "dmp", "saml", "oidc"
+	Type         string `yaml:"type" json:"type"`                         // A registered ApplicationType ID, e.g. "websdk" or "saml"
 	IsDMP        bool   `yaml:"is_dmp,omitempty" json:"is_dmp,omitempty"` // Deprecated: for backward compatibility only
 	Enabled      bool   `yaml:"enabled" json:"enabled"`
 	ClientID     string `yaml:"client_id" json:"client_id"`
@@ -43,8 +58,15 @@ type Application struct {
 	// SAML IDP metadata fields (Duo as Identity Provider)
 	IDPEntityID    string `yaml:"idp_entity_id,omitempty" json:"idp_entity_id,omitempty"`
 	IDPSSOURL      string `yaml:"idp_sso_url,omitempty" json:"idp_sso_url,omitempty"`
+	IDPSLOURL      string `yaml:"idp_slo_url,omitempty" json:"idp_slo_url,omitempty"`
 	IDPCertificate string `yaml:"idp_certificate,omitempty" json:"idp_certificate,omitempty"`
 
+	// SAML validation settings
+	StrictSignatureValidation  bool `yaml:"strict_signature_validation,omitempty" json:"strict_signature_validation,omitempty"`   // Validate IdP signatures using IDPCertificate
+	AllowIdPInitiated          bool `yaml:"allow_idp_initiated,omitempty" json:"allow_idp_initiated,omitempty"`                   // Accept unsolicited responses without InResponseTo
+	ClockSkewSeconds           int  `yaml:"clock_skew_seconds,omitempty" json:"clock_skew_seconds,omitempty"`                     // Tolerance for NotBefore/NotOnOrAfter checks
+	RequireEncryptedAssertions bool `yaml:"require_encrypted_assertions,omitempty" json:"require_encrypted_assertions,omitempty"` // Reject responses whose assertion is not encrypted
+
 	// OIDC-specific fields (Relying Party)
 	RedirectURI              string `yaml:"redirect_uri,omitempty" json:"redirect_uri,omitempty"`
 	IDPDiscoveryURL          string `yaml:"idp_discovery_url,omitempty" json:"idp_discovery_url,omitempty"`
@@ -53,16 +75,30 @@ type Application struct {
 	IDPTokenEndpoint         string `yaml:"idp_token_endpoint,omitempty" json:"idp_token_endpoint,omitempty"`
 	IDPUserInfoEndpoint      string `yaml:"idp_userinfo_endpoint,omitempty" json:"idp_userinfo_endpoint,omitempty"`
 	IDPJWKSEndpoint          string `yaml:"idp_jwks_endpoint,omitempty" json:"idp_jwks_endpoint,omitempty"`
+	IDPIntrospectionEndpoint string `yaml:"idp_introspection_endpoint,omitempty" json:"idp_introspection_endpoint,omitempty"`
+
+	// OIDC client settings
+	OIDCScopes []string `yaml:"oidc_scopes,omitempty" json:"oidc_scopes,omitempty"` // Requested scopes; openid is always included
+	PKCEMode   string   `yaml:"pkce_mode,omitempty" json:"pkce_mode,omitempty"`     // "", "s256" or "public"
 }
 
 // Config represents the entire configuration file
 type Config struct {
-	EncryptionEnabled bool          `yaml:"encryption_enabled,omitempty" json:"encryption_enabled,omitempty"`
-	Tenants           []Tenant      `yaml:"tenants,omitempty" json:"tenants,omitempty"`
-	Applications      []Application `yaml:"applications" json:"applications"`
-	mu                sync.RWMutex  `yaml:"-" json:"-"`
-	filepath          string        `yaml:"-" json:"-"`
-	cryptoManager     interface{}   `yaml:"-" json:"-"` // *crypto.CryptoManager (interface to avoid import cycle)
+	Version           int              `yaml:"version" json:"version"` // Schema version, see CurrentVersion
+	EncryptionEnabled bool             `yaml:"encryption_enabled,omitempty" json:"encryption_enabled,omitempty"`
+	Encryption        EncryptionConfig `yaml:"encryption,omitempty" json:"encryption,omitempty"`
+	Session           SessionConfig    `yaml:"session,omitempty" json:"session,omitempty"`
+	History           HistoryConfig    `yaml:"history,omitempty" json:"history,omitempty"`
+	Admin             AdminConfig      `yaml:"admin,omitempty" json:"admin,omitempty"`
+	Tenants           []Tenant         `yaml:"tenants,omitempty" json:"tenants,omitempty"`
+	Applications      []Application    `yaml:"applications" json:"applications"`
+	mu                sync.RWMutex     `yaml:"-" json:"-"`
+	filepath          string           `yaml:"-" json:"-"`
+	cryptoManager     interface{}      `yaml:"-" json:"-"` // *crypto.CryptoManager (interface to avoid import cycle)
+
+	// Original file contents of a migrated configuration, backed up on its next save
+	backup       []byte
+	migratedFrom int
 }
 
 // LoadConfig loads and parses the YAML configuration file
@@ -73,6 +109,7 @@ func LoadConfig(cfgPath string) (*Config, error) {
 		// If file doesn't exist, create an empty config and persist it
 		if os.IsNotExist(err) {
 			config := &Config{
+				Version:      CurrentVersion,
 				filepath:     cfgPath,
 				Applications: []Application{},
 				Tenants:      []Tenant{},
@@ -113,90 +150,170 @@ func LoadConfig(cfgPath string) (*Config, error) {
 		config.Tenants = []Tenant{}
 	}
 
-	// Migrate old IsDMP field to Type field for backward compatibility
-	for i := range config.Applications {
-		if config.Applications[i].Type == "" {
-			if config.Applications[i].IsDMP {
-				config.Applications[i].Type = "dmp"
-			} else {
-				config.Applications[i].Type = "websdk"
-			}
-		}
+	// Upgrade files written by older builds; the original file is backed up before
+	// the migrated configuration is first saved
+	from := config.Version
+	if _, err := config.migrate(); err != nil {
+		return nil, err
+	}
+	if from != config.Version {
+		config.backup, config.migratedFrom = data, from
 	}
 
 	// Initialize crypto manager and decrypt secrets if encryption is enabled
 	if config.EncryptionEnabled {
-		cm, err := crypto.NewCryptoManager()
+		cm, err := config.Encryption.NewCryptoManager()
 		if err != nil {
 			return nil, fmt.Errorf("failed to initialize encryption: %w", err)
 		}
 		config.cryptoManager = cm
 
-		// Decrypt tenant secrets
-		for i := range config.Tenants {
-			if config.Tenants[i].AdminAPISecret != "" {
-				decrypted, err := cm.Decrypt(config.Tenants[i].AdminAPISecret)
-				if err != nil {
-					return nil, fmt.Errorf("failed to decrypt tenant %s admin_api_secret: %w", config.Tenants[i].ID, err)
-				}
-				config.Tenants[i].AdminAPISecret = decrypted
+		if err := config.decryptSecrets(cm); err != nil {
+			return nil, err
+		}
+	}
+
+	return config, nil
+}
+
+// decryptSecrets replaces every encrypted secret with its plaintext. Secrets that are
+// not encrypted are left as they are.
+func (c *Config) decryptSecrets(cm *crypto.CryptoManager) error {
+	// Decrypt tenant secrets
+	for i := range c.Tenants {
+		if c.Tenants[i].AdminAPISecret != "" {
+			decrypted, err := cm.Decrypt(c.Tenants[i].AdminAPISecret)
+			if err != nil {
+				return fmt.Errorf("failed to decrypt tenant %s admin_api_secret: %w", c.Tenants[i].ID, err)
+			}
+			c.Tenants[i].AdminAPISecret = decrypted
+		}
+		if c.Tenants[i].PendingAdminAPISecret != "" {
+			decrypted, err := cm.Decrypt(c.Tenants[i].PendingAdminAPISecret)
+			if err != nil {
+				return fmt.Errorf("failed to decrypt tenant %s pending_admin_api_secret: %w", c.Tenants[i].ID, err)
 			}
+			c.Tenants[i].PendingAdminAPISecret = decrypted
 		}
+	}
 
-		// Decrypt application secrets
-		for i := range config.Applications {
-			if config.Applications[i].ClientSecret != "" {
-				decrypted, err := cm.Decrypt(config.Applications[i].ClientSecret)
-				if err != nil {
-					return nil, fmt.Errorf("failed to decrypt application %s client_secret: %w", config.Applications[i].ID, err)
-				}
-				config.Applications[i].ClientSecret = decrypted
+	// Decrypt application secrets
+	for i := range c.Applications {
+		if c.Applications[i].ClientSecret != "" {
+			decrypted, err := cm.Decrypt(c.Applications[i].ClientSecret)
+			if err != nil {
+				return fmt.Errorf("failed to decrypt application %s client_secret: %w", c.Applications[i].ID, err)
 			}
-			if config.Applications[i].SigningKey != "" {
-				decrypted, err := cm.Decrypt(config.Applications[i].SigningKey)
-				if err != nil {
-					return nil, fmt.Errorf("failed to decrypt application %s signing_key: %w", config.Applications[i].ID, err)
-				}
-				config.Applications[i].SigningKey = decrypted
+			c.Applications[i].ClientSecret = decrypted
+		}
+		if c.Applications[i].SigningKey != "" {
+			decrypted, err := cm.Decrypt(c.Applications[i].SigningKey)
+			if err != nil {
+				return fmt.Errorf("failed to decrypt application %s signing_key: %w", c.Applications[i].ID, err)
 			}
+			c.Applications[i].SigningKey = decrypted
 		}
 	}
 
-	return config, nil
+	return nil
+}
+
+// hasEncryptedSecrets reports whether any secret is still in its encrypted form, which
+// happens when fields were encrypted while encryption_enabled was off
+func (c *Config) hasEncryptedSecrets() bool {
+	for _, t := range c.Tenants {
+		if crypto.IsEncrypted(t.AdminAPISecret) || crypto.IsEncrypted(t.PendingAdminAPISecret) {
+			return true
+		}
+	}
+	for _, app := range c.Applications {
+		if crypto.IsEncrypted(app.ClientSecret) || crypto.IsEncrypted(app.SigningKey) {
+			return true
+		}
+	}
+	return false
 }
 
 // Save writes the configuration back to the file
 func (c *Config) Save() error {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	// Create a copy for saving (to encrypt secrets without modifying in-memory config)
-	configToSave := &Config{
+	return c.save()
+}
+
+// save is the single persistence path for the configuration. It encrypts secrets
+// when encryption is enabled and replaces the file atomically. The caller must hold
+// c.mu for writing.
+func (c *Config) save() error {
+	data, err := c.marshal()
+	if err != nil {
+		return err
+	}
+
+	if err := c.backupBeforeMigratedSave(); err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(c.filepath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write config file: %v", err)
+	}
+
+	return nil
+}
+
+// snapshot copies the persisted fields of the configuration, stamped with
+// CurrentVersion since the in-memory configuration always has the current schema
+func (c *Config) snapshot() *Config {
+	out := &Config{
+		Version:           CurrentVersion,
 		EncryptionEnabled: c.EncryptionEnabled,
+		Encryption:        c.Encryption,
+		Session:           c.Session,
+		History:           c.History,
+		Admin:             c.Admin,
 		Tenants:           make([]Tenant, len(c.Tenants)),
 		Applications:      make([]Application, len(c.Applications)),
-		filepath:          c.filepath,
 	}
 
 	// Deep copy tenants
-	copy(configToSave.Tenants, c.Tenants)
+	copy(out.Tenants, c.Tenants)
 
 	// Deep copy applications
-	copy(configToSave.Applications, c.Applications)
+	copy(out.Applications, c.Applications)
+
+	return out
+}
+
+// marshal renders the configuration as it is stored on disk, with secrets encrypted
+// when encryption is enabled. The in-memory configuration is left untouched.
+func (c *Config) marshal() ([]byte, error) {
+	// Create a copy for saving (to encrypt secrets without modifying in-memory config)
+	configToSave := c.snapshot()
 
 	// Encrypt secrets if encryption is enabled
-	if c.EncryptionEnabled && c.cryptoManager != nil {
-		cm := c.cryptoManager.(*crypto.CryptoManager)
+	if c.EncryptionEnabled {
+		cm, ok := c.cryptoManager.(*crypto.CryptoManager)
+		if !ok || cm == nil {
+			return nil, fmt.Errorf("encryption is enabled but no crypto manager is configured")
+		}
 
 		// Encrypt tenant secrets
 		for i := range configToSave.Tenants {
 			if configToSave.Tenants[i].AdminAPISecret != "" {
 				encrypted, err := cm.Encrypt(configToSave.Tenants[i].AdminAPISecret)
 				if err != nil {
-					return fmt.Errorf("failed to encrypt tenant %s admin_api_secret: %w", configToSave.Tenants[i].ID, err)
+					return nil, fmt.Errorf("failed to encrypt tenant %s admin_api_secret: %w", configToSave.Tenants[i].ID, err)
 				}
 				configToSave.Tenants[i].AdminAPISecret = encrypted
 			}
+			if configToSave.Tenants[i].PendingAdminAPISecret != "" {
+				encrypted, err := cm.Encrypt(configToSave.Tenants[i].PendingAdminAPISecret)
+				if err != nil {
+					return nil, fmt.Errorf("failed to encrypt tenant %s pending_admin_api_secret: %w", configToSave.Tenants[i].ID, err)
+				}
+				configToSave.Tenants[i].PendingAdminAPISecret = encrypted
+			}
 		}
 
 		// Encrypt application secrets
@@ -204,14 +321,14 @@ func (c *Config) Save() error {
 			if configToSave.Applications[i].ClientSecret != "" {
 				encrypted, err := cm.Encrypt(configToSave.Applications[i].ClientSecret)
 				if err != nil {
-					return fmt.Errorf("failed to encrypt application %s client_secret: %w", configToSave.Applications[i].ID, err)
+					return nil, fmt.Errorf("failed to encrypt application %s client_secret: %w", configToSave.Applications[i].ID, err)
 				}
 				configToSave.Applications[i].ClientSecret = encrypted
 			}
 			if configToSave.Applications[i].SigningKey != "" {
 				encrypted, err := cm.Encrypt(configToSave.Applications[i].SigningKey)
 				if err != nil {
-					return fmt.Errorf("failed to encrypt application %s signing_key: %w", configToSave.Applications[i].ID, err)
+					return nil, fmt.Errorf("failed to encrypt application %s signing_key: %w", configToSave.Applications[i].ID, err)
 				}
 				configToSave.Applications[i].SigningKey = encrypted
 			}
@@ -220,17 +337,187 @@ func (c *Config) Save() error {
 
 	data, err := yaml.Marshal(configToSave)
 	if err != nil {
-		return fmt.Errorf("failed to marshal config: %v", err)
+		return nil, fmt.Errorf("failed to marshal config: %v", err)
 	}
 
-	err = os.WriteFile(c.filepath, data, 0644)
+	return data, nil
+}
+
+// writeFileAtomic writes data to a temporary file in the target directory and renames
+// it over path, so readers never observe a partially written file
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
 	if err != nil {
-		return fmt.Errorf("failed to write config file: %v", err)
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	// Remove the temporary file unless it was renamed into place
+	renamed := false
+	defer func() {
+		if !renamed {
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	renamed = true
+	return nil
+}
+
+// RotateKey re-encrypts every secret under a new master key and saves the file.
+// The configuration must have encryption enabled; on failure the previous key stays
+// in use and the file is left unchanged.
+func (c *Config) RotateKey(next *crypto.CryptoManager) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.rotateKey(c.Encryption, next)
+}
+
+// RotateKeyTo works like RotateKey and also records where the new master key lives, for
+// rotations that move the key to another provider or key file
+func (c *Config) RotateKeyTo(settings EncryptionConfig, next *crypto.CryptoManager) error {
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.rotateKey(settings, next)
+}
+
+func (c *Config) rotateKey(settings EncryptionConfig, next *crypto.CryptoManager) error {
+	if !c.EncryptionEnabled {
+		return fmt.Errorf("encryption is not enabled in this configuration")
+	}
+	if next == nil {
+		return fmt.Errorf("a new crypto manager is required")
+	}
+
+	previous, previousSettings := c.cryptoManager, c.Encryption
+	c.cryptoManager, c.Encryption = next, settings
+	if err := c.save(); err != nil {
+		c.cryptoManager, c.Encryption = previous, previousSettings
+		return err
+	}
+
+	return nil
+}
+
+// SetEncryption turns encryption of secrets at rest on or off and rewrites the file.
+// The master key comes from the configured key provider. Secrets that were encrypted
+// while encryption_enabled was off are decrypted first, so they are never encrypted twice.
+func (c *Config) SetEncryption(enabled bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var cm *crypto.CryptoManager
+	if current, ok := c.cryptoManager.(*crypto.CryptoManager); ok && current != nil {
+		cm = current
+	} else if enabled || c.hasEncryptedSecrets() {
+		var err error
+		cm, err = c.Encryption.NewCryptoManager()
+		if err != nil {
+			return fmt.Errorf("failed to initialize encryption: %w", err)
+		}
+	}
+	if cm != nil {
+		if err := c.decryptSecrets(cm); err != nil {
+			return err
+		}
+	}
+
+	previousEnabled, previous := c.EncryptionEnabled, c.cryptoManager
+	c.EncryptionEnabled = enabled
+	if enabled {
+		c.cryptoManager = cm
+	} else {
+		c.cryptoManager = nil
+	}
+	if err := c.save(); err != nil {
+		c.EncryptionEnabled, c.cryptoManager = previousEnabled, previous
+		return err
 	}
 
 	return nil
 }
 
+// Validate checks every tenant and application, the references between them and the
+// session, history, admin and encryption settings. All problems are returned joined
+// into one error.
+func (c *Config) Validate() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var errs []error
+
+	tenantIDs := make(map[string]bool)
+	for _, tenant := range c.Tenants {
+		if tenant.ID == "" {
+			errs = append(errs, fmt.Errorf("tenant %q: id is required", tenant.Name))
+		} else if tenantIDs[tenant.ID] {
+			errs = append(errs, fmt.Errorf("tenant %s: duplicate id", tenant.ID))
+		}
+		tenantIDs[tenant.ID] = true
+
+		if err := validateTenant(&tenant); err != nil {
+			errs = append(errs, fmt.Errorf("tenant %s: %w", tenant.ID, err))
+		}
+	}
+
+	appIDs := make(map[string]bool)
+	for _, app := range c.Applications {
+		if app.ID == "" {
+			errs = append(errs, fmt.Errorf("application %q: id is required", app.Name))
+		} else if appIDs[app.ID] {
+			errs = append(errs, fmt.Errorf("application %s: duplicate id", app.ID))
+		}
+		appIDs[app.ID] = true
+
+		if err := validateApplication(&app); err != nil {
+			errs = append(errs, fmt.Errorf("application %s: %w", app.ID, err))
+		}
+		if app.TenantID != "" && !tenantIDs[app.TenantID] {
+			errs = append(errs, fmt.Errorf("application %s: tenant %s does not exist", app.ID, app.TenantID))
+		}
+	}
+
+	if err := c.Session.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.History.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.Admin.Validate(c.Applications); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.Encryption.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
 // GetApplication retrieves an application by ID
 func (c *Config) GetApplication(id string) (*Application, error) {
 	c.mu.RLock()
@@ -260,6 +547,16 @@ func (c *Config) GetEnabledApplications() []Application {
 	return enabled
 }
 
+// GetAllApplications returns all applications
+func (c *Config) GetAllApplications() []Application {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	apps := make([]Application, len(c.Applications))
+	copy(apps, c.Applications)
+	return apps
+}
+
 // AddApplication adds a new application to the configuration
 func (c *Config) AddApplication(app Application) error {
 	c.mu.Lock()
@@ -274,6 +571,9 @@ func (c *Config) AddApplication(app Application) error {
 	if err := validateApplication(&app); err != nil {
 		return err
 	}
+	if app.ClientSecret == RedactedSecret || app.SigningKey == RedactedSecret {
+		return fmt.Errorf("secrets of a new application cannot be the redacted placeholder")
+	}
 
 	// Check for duplicate ID
 	for _, existing := range c.Applications {
@@ -291,15 +591,20 @@ func (c *Config) UpdateApplication(id string, updatedApp Application) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	// Validate the application
-	if err := validateApplication(&updatedApp); err != nil {
-		return err
-	}
-
 	for i := range c.Applications {
 		if c.Applications[i].ID == id {
 			// Preserve the original ID
 			updatedApp.ID = id
+
+			// Secrets sent back as the redacted placeholder keep their stored value
+			updatedApp.ClientSecret = keepRedacted(updatedApp.ClientSecret, c.Applications[i].ClientSecret)
+			updatedApp.SigningKey = keepRedacted(updatedApp.SigningKey, c.Applications[i].SigningKey)
+
+			// Validate the application
+			if err := validateApplication(&updatedApp); err != nil {
+				return err
+			}
+
 			c.Applications[i] = updatedApp
 			return c.save()
 		}
@@ -323,21 +628,6 @@ func (c *Config) DeleteApplication(id string) error {
 	return fmt.Errorf("application with id '%s' not found", id)
 }
 
-// save is an internal method that saves without locking (assumes lock is held)
-func (c *Config) save() error {
-	data, err := yaml.Marshal(c)
-	if err != nil {
-		return fmt.Errorf("failed to marshal config: %v", err)
-	}
-
-	err = os.WriteFile(c.filepath, data, 0644)
-	if err != nil {
-		return fmt.Errorf("failed to write config file: %v", err)
-	}
-
-	return nil
-}
-
 // validateApplication validates an application configuration
 func validateApplication(app *Application) error {
 	if app.Name == "" {
@@ -345,48 +635,18 @@ func validateApplication(app *Application) error {
 	}
 
 	// Validate type
-	validTypes := []string{"websdk", "dmp", "saml", "oidc"}
 	if app.Type == "" {
 		return fmt.Errorf("application type is required")
 	}
-	isValidType := false
-	for _, validType := range validTypes {
-		if app.Type == validType {
-			isValidType = true
-			break
-		}
-	}
-	if !isValidType {
-		return fmt.Errorf("invalid application type: %s (must be one of: websdk, dmp, saml, oidc)", app.Type)
+	appType, ok := LookupApplicationType(app.Type)
+	if !ok {
+		return fmt.Errorf("invalid application type: %s (must be one of: %s)", app.Type, applicationTypeIDs())
 	}
 
 	// Type-specific validation
-	if app.Type == "saml" {
-		if app.EntityID == "" {
-			return fmt.Errorf("entity_id is required for SAML applications")
-		}
-		if app.ACSURL == "" {
-			return fmt.Errorf("acs_url is required for SAML applications")
-		}
-		// ClientID and ClientSecret are not required for SAML
-	} else if app.Type == "oidc" {
-		// For OIDC - require client credentials and redirect URI
-		if app.ClientID == "" {
-			return fmt.Errorf("client_id is required for OIDC applications")
-		}
-		if app.ClientSecret == "" {
-			return fmt.Errorf("client_secret is required for OIDC applications")
-		}
-		if app.RedirectURI == "" {
-			return fmt.Errorf("redirect_uri is required for OIDC applications")
-		}
-	} else {
-		// For websdk, dmp - require client credentials
-		if app.ClientID == "" {
-			return fmt.Errorf("client_id is required")
-		}
-		if app.ClientSecret == "" {
-			return fmt.Errorf("client_secret is required")
+	if appType.Validate != nil {
+		if err := appType.Validate(app); err != nil {
+			return err
 		}
 	}
 
@@ -397,7 +657,7 @@ func validateApplication(app *Application) error {
 	return nil
 }
 
-// GetApplicationType returns the application type (websdk, dmp, saml, oidc)
+// GetApplicationType returns the application type, e.g. websdk, dmp, saml or oidc
 func (a *Application) GetApplicationType() string {
 	if a.Type != "" {
 		return a.Type
@@ -409,6 +669,30 @@ func (a *Application) GetApplicationType() string {
 	return "websdk"
 }
 
+// OIDCScopeList returns the scopes to request for an OIDC application: openid first,
+// followed by the configured scopes without duplicates
+func (a *Application) OIDCScopeList() []string {
+	scopes := []string{"openid"}
+	for _, scope := range a.OIDCScopes {
+		duplicate := false
+		for _, existing := range scopes {
+			if existing == scope {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// UsesPKCE reports whether the OIDC authorization request carries a code challenge
+func (a *Application) UsesPKCE() bool {
+	return a.PKCEMode == PKCEModeS256 || a.PKCEMode == PKCEModePublic
+}
+
 // IsConfigured checks if the configuration has at least one enabled application
 func (c *Config) IsConfigured() bool {
 	c.mu.RLock()
@@ -465,6 +749,111 @@ func (c *Config) GetTenant(id string) (*Tenant, error) {
 	return nil, fmt.Errorf("tenant with id '%s' not found", id)
 }
 
+// UpdateTenant replaces the name, hostname and Admin API credentials of a tenant.
+// An admin_api_secret sent as the redacted placeholder keeps the stored secret, and a
+// pending credential rotation is left untouched.
+func (c *Config) UpdateTenant(id string, updated Tenant) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i := range c.Tenants {
+		if c.Tenants[i].ID == id {
+			existing := c.Tenants[i]
+
+			updated.ID = id
+			updated.AdminAPISecret = keepRedacted(updated.AdminAPISecret, existing.AdminAPISecret)
+			updated.PendingAdminAPIKey = existing.PendingAdminAPIKey
+			updated.PendingAdminAPISecret = existing.PendingAdminAPISecret
+			updated.RotationStartedAt = existing.RotationStartedAt
+
+			if err := validateTenant(&updated); err != nil {
+				return err
+			}
+
+			c.Tenants[i] = updated
+			return c.save()
+		}
+	}
+
+	return fmt.Errorf("tenant with id '%s' not found", id)
+}
+
+// StageTenantCredentials stores new Admin API credentials as a pending rotation.
+// The active credentials keep being used until ConfirmTenantRotation is called.
+func (c *Config) StageTenantCredentials(id, adminAPIKey, adminAPISecret string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if adminAPIKey == "" || adminAPISecret == "" {
+		return fmt.Errorf("admin_api_key and admin_api_secret are required")
+	}
+
+	for i := range c.Tenants {
+		if c.Tenants[i].ID == id {
+			if adminAPIKey == c.Tenants[i].AdminAPIKey && adminAPISecret == c.Tenants[i].AdminAPISecret {
+				return fmt.Errorf("new credentials are identical to the active credentials")
+			}
+			c.Tenants[i].PendingAdminAPIKey = adminAPIKey
+			c.Tenants[i].PendingAdminAPISecret = adminAPISecret
+			c.Tenants[i].RotationStartedAt = time.Now().UTC().Format(time.RFC3339)
+			return c.save()
+		}
+	}
+
+	return fmt.Errorf("tenant with id '%s' not found", id)
+}
+
+// ConfirmTenantRotation promotes the pending Admin API credentials of a tenant to
+// active and discards the old ones
+func (c *Config) ConfirmTenantRotation(id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i := range c.Tenants {
+		if c.Tenants[i].ID == id {
+			tenant := &c.Tenants[i]
+			if !tenant.RotationPending() {
+				return fmt.Errorf("tenant '%s' has no pending credential rotation", tenant.Name)
+			}
+			tenant.AdminAPIKey = tenant.PendingAdminAPIKey
+			tenant.AdminAPISecret = tenant.PendingAdminAPISecret
+			tenant.clearRotation()
+			return c.save()
+		}
+	}
+
+	return fmt.Errorf("tenant with id '%s' not found", id)
+}
+
+// CancelTenantRotation discards the pending Admin API credentials of a tenant
+func (c *Config) CancelTenantRotation(id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i := range c.Tenants {
+		if c.Tenants[i].ID == id {
+			if !c.Tenants[i].RotationPending() {
+				return fmt.Errorf("tenant '%s' has no pending credential rotation", c.Tenants[i].Name)
+			}
+			c.Tenants[i].clearRotation()
+			return c.save()
+		}
+	}
+
+	return fmt.Errorf("tenant with id '%s' not found", id)
+}
+
+// RotationPending reports whether new Admin API credentials are staged for the tenant
+func (t *Tenant) RotationPending() bool {
+	return t.PendingAdminAPIKey != "" && t.PendingAdminAPISecret != ""
+}
+
+func (t *Tenant) clearRotation() {
+	t.PendingAdminAPIKey = ""
+	t.PendingAdminAPISecret = ""
+	t.RotationStartedAt = ""
+}
+
 // GetAllTenants returns all tenants
 func (c *Config) GetAllTenants() []Tenant {
 	c.mu.RLock()
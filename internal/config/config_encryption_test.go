@@ -331,8 +331,9 @@ func TestEncryptionWithoutMasterKey(t *testing.T) {
 	tmpDir := t.TempDir()
 	configPath := filepath.Join(tmpDir, "config.yaml")
 
-	// Make sure no master key in env
+	// Make sure there is no master key in env or in the working directory
 	os.Unsetenv("UET_MASTER_KEY")
+	t.Chdir(tmpDir)
 
 	initialContent := `
 encryption_enabled: true
@@ -349,17 +350,312 @@ applications:
 		t.Fatalf("Failed to write test config: %v", err)
 	}
 
-	// Should auto-generate key file
+	// A missing key is an error; no key file is generated
+	if _, err := LoadConfig(configPath); err == nil {
+		t.Fatal("LoadConfig() without a master key should fail")
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, ".uet_key")); !os.IsNotExist(err) {
+		t.Error("LoadConfig() should not generate a key file")
+	}
+}
+
+func TestMutatorsPersistEncryptedSecrets(t *testing.T) {
+	os.Setenv("UET_MASTER_KEY", "test-master-key-for-testing-12345")
+	defer os.Unsetenv("UET_MASTER_KEY")
+
+	initialContent := `
+encryption_enabled: true
+tenants:
+  - id: "tenant-1"
+    name: "Tenant One"
+    admin_api_key: "admin_key"
+    admin_api_secret: "tenant-secret-plain"
+    api_hostname: "api-test.duosecurity.com"
+  - id: "tenant-2"
+    name: "Tenant Two"
+    admin_api_key: "admin_key_2"
+    admin_api_secret: "tenant-two-secret-plain"
+    api_hostname: "api-two.duosecurity.com"
+applications:
+  - id: "app-1"
+    tenant_id: "tenant-1"
+    name: "App One"
+    type: "websdk"
+    enabled: true
+    client_id: "client_one"
+    client_secret: "app-secret-plain"
+    api_hostname: "api-test.duosecurity.com"
+  - id: "app-2"
+    name: "App Two"
+    type: "websdk"
+    enabled: true
+    client_id: "client_two"
+    client_secret: "app-two-secret-plain"
+    api_hostname: "api-test.duosecurity.com"
+`
+
+	tests := []struct {
+		name   string
+		mutate func(cfg *Config) error
+	}{
+		{
+			name: "AddApplication",
+			mutate: func(cfg *Config) error {
+				return cfg.AddApplication(Application{
+					Name:         "New App",
+					Type:         "websdk",
+					Enabled:      true,
+					ClientID:     "new_client",
+					ClientSecret: "new-app-secret-plain",
+					APIHostname:  "api-test.duosecurity.com",
+				})
+			},
+		},
+		{
+			name: "UpdateApplication",
+			mutate: func(cfg *Config) error {
+				app := *mustGetApplication(t, cfg, "app-1")
+				app.Name = "App One Renamed"
+				return cfg.UpdateApplication("app-1", app)
+			},
+		},
+		{
+			name:   "DeleteApplication",
+			mutate: func(cfg *Config) error { return cfg.DeleteApplication("app-2") },
+		},
+		{
+			name: "AddTenant",
+			mutate: func(cfg *Config) error {
+				return cfg.AddTenant(Tenant{
+					Name:           "Tenant Three",
+					AdminAPIKey:    "admin_key_3",
+					AdminAPISecret: "tenant-three-secret-plain",
+					APIHostname:    "api-three.duosecurity.com",
+				})
+			},
+		},
+		{
+			name: "UpdateTenant",
+			mutate: func(cfg *Config) error {
+				return cfg.UpdateTenant("tenant-1", Tenant{
+					Name:           "Tenant One Renamed",
+					AdminAPIKey:    "admin_key",
+					AdminAPISecret: RedactedSecret,
+					APIHostname:    "api-test.duosecurity.com",
+				})
+			},
+		},
+		{
+			name: "StageTenantCredentials",
+			mutate: func(cfg *Config) error {
+				return cfg.StageTenantCredentials("tenant-1", "admin_key_new", "pending-secret-plain")
+			},
+		},
+		{
+			name: "ConfirmTenantRotation",
+			mutate: func(cfg *Config) error {
+				if err := cfg.StageTenantCredentials("tenant-1", "admin_key_new", "pending-secret-plain"); err != nil {
+					return err
+				}
+				return cfg.ConfirmTenantRotation("tenant-1")
+			},
+		},
+		{
+			name: "CancelTenantRotation",
+			mutate: func(cfg *Config) error {
+				if err := cfg.StageTenantCredentials("tenant-1", "admin_key_new", "pending-secret-plain"); err != nil {
+					return err
+				}
+				return cfg.CancelTenantRotation("tenant-1")
+			},
+		},
+		{
+			name:   "DeleteTenant",
+			mutate: func(cfg *Config) error { return cfg.DeleteTenant("tenant-2") },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(configPath, []byte(initialContent), 0644); err != nil {
+				t.Fatalf("Failed to write test config: %v", err)
+			}
+
+			cfg, err := LoadConfig(configPath)
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+
+			if err := tt.mutate(cfg); err != nil {
+				t.Fatalf("%s() error = %v", tt.name, err)
+			}
+
+			data, err := os.ReadFile(configPath)
+			if err != nil {
+				t.Fatalf("Failed to read config: %v", err)
+			}
+			if strings.Contains(string(data), "-plain") {
+				t.Errorf("%s wrote a plaintext secret to disk:\n%s", tt.name, data)
+			}
+			if !strings.Contains(string(data), crypto.EncryptedFieldPrefix) {
+				t.Errorf("%s did not write encrypted secrets", tt.name)
+			}
+
+			info, err := os.Stat(configPath)
+			if err != nil {
+				t.Fatalf("Failed to stat config: %v", err)
+			}
+			if perm := info.Mode().Perm(); perm != 0600 {
+				t.Errorf("Config file permissions = %o, want 600", perm)
+			}
+
+			// Secrets must survive a reload, and the in-memory copy must stay plaintext
+			cfg2, err := LoadConfig(configPath)
+			if err != nil {
+				t.Fatalf("LoadConfig() after %s error = %v", tt.name, err)
+			}
+			for _, tenant := range cfg2.Tenants {
+				if crypto.IsEncrypted(tenant.AdminAPISecret) || !strings.HasSuffix(tenant.AdminAPISecret, "-plain") {
+					t.Errorf("Tenant %s secret after reload = %q", tenant.ID, tenant.AdminAPISecret)
+				}
+			}
+			for _, app := range cfg.Applications {
+				if crypto.IsEncrypted(app.ClientSecret) {
+					t.Errorf("In-memory secret of application %s was encrypted", app.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestSaveWithoutCryptoManagerFails(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	cfg := &Config{
+		EncryptionEnabled: true,
+		filepath:          configPath,
+		Applications: []Application{
+			{ID: "app-1", Name: "App", Type: "websdk", ClientID: "client", ClientSecret: "secret", APIHostname: "api-test.duosecurity.com"},
+		},
+	}
+
+	if err := cfg.Save(); err == nil {
+		t.Fatal("Save() should fail when encryption is enabled without a crypto manager")
+	}
+	if _, err := os.Stat(configPath); !os.IsNotExist(err) {
+		t.Error("Save() should not write the config file when encryption fails")
+	}
+}
+
+func TestSaveLeavesNoTempFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg, err := LoadConfig(filepath.Join(tmpDir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if err := cfg.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "config.yaml" {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("Config directory contains %v, want only config.yaml", names)
+	}
+}
+
+func mustGetApplication(t *testing.T, cfg *Config, id string) *Application {
+	t.Helper()
+	app, err := cfg.GetApplication(id)
+	if err != nil {
+		t.Fatalf("GetApplication(%s) error = %v", id, err)
+	}
+	return app
+}
+
+func TestRotateKey(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+
+	os.Setenv("UET_MASTER_KEY", "old-master-key")
+	defer os.Unsetenv("UET_MASTER_KEY")
+
+	initialContent := `
+encryption_enabled: true
+tenants:
+  - id: "tenant-1"
+    name: "Tenant One"
+    admin_api_key: "admin_key"
+    admin_api_secret: "tenant-secret"
+    api_hostname: "api-test.duosecurity.com"
+applications:
+  - id: "app-1"
+    name: "App One"
+    type: "websdk"
+    enabled: true
+    client_id: "client_one"
+    client_secret: "app-secret"
+    api_hostname: "api-test.duosecurity.com"
+`
+	if err := os.WriteFile(configPath, []byte(initialContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
 	cfg, err := LoadConfig(configPath)
 	if err != nil {
-		t.Fatalf("LoadConfig() without explicit key error = %v", err)
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if err := cfg.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	oldKeyID, err := cfg.cryptoManager.(*crypto.CryptoManager).KeyID()
+	if err != nil {
+		t.Fatalf("KeyID() error = %v", err)
+	}
+
+	next := crypto.NewCryptoManagerWithKey("new-master-key")
+	if err := cfg.RotateKey(next); err != nil {
+		t.Fatalf("RotateKey() error = %v", err)
+	}
+
+	data, _ := os.ReadFile(configPath)
+	if strings.Contains(string(data), ","+oldKeyID+",") {
+		t.Error("Config file still contains fields encrypted with the old key")
+	}
+	nextKeyID, err := next.KeyID()
+	if err != nil {
+		t.Fatalf("KeyID() error = %v", err)
+	}
+	if !strings.Contains(string(data), ","+nextKeyID+",") {
+		t.Error("Config file should contain fields encrypted with the new key")
 	}
 
-	// Should still work
-	if cfg.Applications[0].ClientSecret != "my-secret" {
-		t.Error("Should decrypt with auto-generated key")
+	// The old key no longer opens the file
+	if _, err := LoadConfig(configPath); err == nil {
+		t.Error("LoadConfig() with the old key should fail after rotation")
 	}
 
-	// Clean up auto-generated key
-	os.Remove(".uet_key")
+	os.Setenv("UET_MASTER_KEY", "new-master-key")
+	cfg2, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig() with the new key error = %v", err)
+	}
+	if cfg2.Tenants[0].AdminAPISecret != "tenant-secret" || cfg2.Applications[0].ClientSecret != "app-secret" {
+		t.Error("Secrets did not survive key rotation")
+	}
+}
+
+func TestRotateKeyRequiresEncryption(t *testing.T) {
+	cfg, err := LoadConfig(filepath.Join(t.TempDir(), "config.yaml"))
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if err := cfg.RotateKey(crypto.NewCryptoManagerWithKey("new-master-key")); err == nil {
+		t.Error("RotateKey() should fail when encryption is disabled")
+	}
 }
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/google/uuid"
@@ -662,6 +663,101 @@ func TestValidateApplication(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "saml negative clock skew",
+			app: &Application{
+				Name:             "Test SAML",
+				Type:             "saml",
+				EntityID:         "http://example.com",
+				ACSURL:           "http://example.com/acs",
+				APIHostname:      "api-test.duosecurity.com",
+				ClockSkewSeconds: -5,
+			},
+			wantErr: true,
+		},
+		{
+			name: "oidc public client without secret",
+			app: &Application{
+				Name:        "Test OIDC",
+				Type:        "oidc",
+				ClientID:    "test",
+				RedirectURI: "http://example.com/callback",
+				APIHostname: "api-test.duosecurity.com",
+				PKCEMode:    PKCEModePublic,
+			},
+			wantErr: false,
+		},
+		{
+			name: "oidc s256 without secret",
+			app: &Application{
+				Name:        "Test OIDC",
+				Type:        "oidc",
+				ClientID:    "test",
+				RedirectURI: "http://example.com/callback",
+				APIHostname: "api-test.duosecurity.com",
+				PKCEMode:    PKCEModeS256,
+			},
+			wantErr: true,
+		},
+		{
+			name: "oidc invalid pkce mode",
+			app: &Application{
+				Name:         "Test OIDC",
+				Type:         "oidc",
+				ClientID:     "test",
+				ClientSecret: "test",
+				RedirectURI:  "http://example.com/callback",
+				APIHostname:  "api-test.duosecurity.com",
+				PKCEMode:     "plain",
+			},
+			wantErr: true,
+		},
+		{
+			name: "oidc scope with whitespace",
+			app: &Application{
+				Name:         "Test OIDC",
+				Type:         "oidc",
+				ClientID:     "test",
+				ClientSecret: "test",
+				RedirectURI:  "http://example.com/callback",
+				APIHostname:  "api-test.duosecurity.com",
+				OIDCScopes:   []string{"profile email"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid authapi app",
+			app: &Application{
+				Name:         "Test Auth API",
+				Type:         "authapi",
+				ClientID:     "test",
+				ClientSecret: "test",
+				APIHostname:  "api-test.duosecurity.com",
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid websdkv2 app",
+			app: &Application{
+				Name:         "Test Web SDK v2",
+				Type:         "websdkv2",
+				ClientID:     "DIXXXXXXXXXXXXXXXXXX",
+				ClientSecret: "deadbeefdeadbeefdeadbeefdeadbeefdeadbeef",
+				APIHostname:  "api-test.duosecurity.com",
+			},
+			wantErr: false,
+		},
+		{
+			name: "websdkv2 short secret key",
+			app: &Application{
+				Name:         "Test Web SDK v2",
+				Type:         "websdkv2",
+				ClientID:     "DIXXXXXXXXXXXXXXXXXX",
+				ClientSecret: "test",
+				APIHostname:  "api-test.duosecurity.com",
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -674,6 +770,51 @@ func TestValidateApplication(t *testing.T) {
 	}
 }
 
+func TestApplicationTypeRegistry(t *testing.T) {
+	for _, id := range []string{"websdk", "dmp", "saml", "oidc", "authapi", "websdkv2"} {
+		appType, ok := LookupApplicationType(id)
+		if !ok {
+			t.Errorf("LookupApplicationType(%q) not found", id)
+			continue
+		}
+		if appType.Validate == nil || appType.IntegrationType == "" {
+			t.Errorf("application type %q is missing Validate or IntegrationType", id)
+		}
+	}
+
+	if err := RegisterApplicationType(ApplicationType{ID: "saml"}); err == nil {
+		t.Error("RegisterApplicationType() should reject a duplicate ID")
+	}
+
+	err := validateApplication(&Application{Name: "Test", Type: "invalid", APIHostname: "api-test.duosecurity.com"})
+	if err == nil || !strings.Contains(err.Error(), "authapi") {
+		t.Errorf("validateApplication() error = %v, want the registered types listed", err)
+	}
+
+	app := &Application{Type: "websdkv2"}
+	if got := app.TypeLabel(); got != "SDK v2" {
+		t.Errorf("TypeLabel() = %q, want SDK v2", got)
+	}
+}
+
+func TestOIDCScopeList(t *testing.T) {
+	app := &Application{OIDCScopes: []string{"email", "openid", "profile", "email"}}
+	got := app.OIDCScopeList()
+	want := []string{"openid", "email", "profile"}
+	if len(got) != len(want) {
+		t.Fatalf("OIDCScopeList() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("OIDCScopeList()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	if scopes := (&Application{}).OIDCScopeList(); len(scopes) != 1 || scopes[0] != "openid" {
+		t.Errorf("OIDCScopeList() with no scopes = %v, want [openid]", scopes)
+	}
+}
+
 func TestValidateTenant(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -737,3 +878,117 @@ func TestValidateTenant(t *testing.T) {
 		})
 	}
 }
+
+func TestUpdateTenant(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+tenants:
+  - id: "tenant1"
+    name: "Test Tenant"
+    admin_api_key: "old_key"
+    admin_api_secret: "old_secret"
+    api_hostname: "api-test.duosecurity.com"
+    pending_admin_api_key: "new_key"
+    pending_admin_api_secret: "new_secret"
+    rotation_started_at: "2026-01-01T00:00:00Z"
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	err = cfg.UpdateTenant("tenant1", Tenant{
+		Name:           "Renamed",
+		AdminAPIKey:    "old_key",
+		AdminAPISecret: RedactedSecret,
+		APIHostname:    "api-test.duosecurity.com",
+	})
+	if err != nil {
+		t.Fatalf("UpdateTenant() error = %v", err)
+	}
+
+	tenant, _ := cfg.GetTenant("tenant1")
+	if tenant.Name != "Renamed" {
+		t.Errorf("UpdateTenant() name = %v, want Renamed", tenant.Name)
+	}
+	if tenant.AdminAPISecret != "old_secret" {
+		t.Errorf("UpdateTenant() replaced the stored secret with %q", tenant.AdminAPISecret)
+	}
+	if !tenant.RotationPending() || tenant.PendingAdminAPISecret != "new_secret" {
+		t.Error("UpdateTenant() dropped the pending rotation")
+	}
+
+	if err := cfg.UpdateTenant("tenant1", Tenant{Name: "Renamed", AdminAPIKey: "old_key"}); err == nil {
+		t.Error("UpdateTenant() should reject a tenant without secret or hostname")
+	}
+	if err := cfg.UpdateTenant("missing", *tenant); err == nil {
+		t.Error("UpdateTenant() should fail for an unknown tenant")
+	}
+}
+
+func TestTenantRotation(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+tenants:
+  - id: "tenant1"
+    name: "Test Tenant"
+    admin_api_key: "old_key"
+    admin_api_secret: "old_secret"
+    api_hostname: "api-test.duosecurity.com"
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	if err := cfg.StageTenantCredentials("tenant1", "old_key", "old_secret"); err == nil {
+		t.Error("StageTenantCredentials() should reject the active credentials")
+	}
+	if err := cfg.ConfirmTenantRotation("tenant1"); err == nil {
+		t.Error("ConfirmTenantRotation() should fail without a pending rotation")
+	}
+
+	// Cancelling keeps the active credentials
+	if err := cfg.StageTenantCredentials("tenant1", "old_key", "new_secret"); err != nil {
+		t.Fatalf("StageTenantCredentials() error = %v", err)
+	}
+	tenant, _ := cfg.GetTenant("tenant1")
+	if tenant.AdminAPISecret != "old_secret" || tenant.RotationStartedAt == "" {
+		t.Errorf("StageTenantCredentials() tenant = %+v", tenant)
+	}
+	if err := cfg.CancelTenantRotation("tenant1"); err != nil {
+		t.Fatalf("CancelTenantRotation() error = %v", err)
+	}
+	tenant, _ = cfg.GetTenant("tenant1")
+	if tenant.RotationPending() || tenant.RotationStartedAt != "" || tenant.AdminAPISecret != "old_secret" {
+		t.Errorf("CancelTenantRotation() tenant = %+v", tenant)
+	}
+
+	// Confirming promotes the pending credentials and survives a reload
+	if err := cfg.StageTenantCredentials("tenant1", "new_key", "new_secret"); err != nil {
+		t.Fatalf("StageTenantCredentials() error = %v", err)
+	}
+	if err := cfg.ConfirmTenantRotation("tenant1"); err != nil {
+		t.Fatalf("ConfirmTenantRotation() error = %v", err)
+	}
+
+	reloaded, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig() after rotation error = %v", err)
+	}
+	tenant, _ = reloaded.GetTenant("tenant1")
+	if tenant.AdminAPIKey != "new_key" || tenant.AdminAPISecret != "new_secret" {
+		t.Errorf("ConfirmTenantRotation() credentials = %s/%s, want new_key/new_secret", tenant.AdminAPIKey, tenant.AdminAPISecret)
+	}
+	if tenant.RotationPending() || tenant.RotationStartedAt != "" {
+		t.Error("ConfirmTenantRotation() left the rotation pending")
+	}
+}
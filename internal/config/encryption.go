@@ -0,0 +1,146 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"user_experience_toolkit/internal/crypto"
+)
+
+// Master key providers
+const (
+	KeyProviderEnv   = "env"   // UET_MASTER_KEY, else .uet_key in the working directory (default)
+	KeyProviderFile  = "file"  // Key file at key_file; never created implicitly
+	KeyProviderVault = "vault" // Data key in key_file, wrapped by a Vault transit-compatible service
+	KeyProviderAge   = "age"   // Key in key_file, encrypted to age X25519 recipients
+)
+
+// EncryptionConfig selects where the master key for encrypted secrets comes from.
+// Every field can be overridden with a UET_* environment variable. The Vault token is
+// only read from the environment so it never ends up in config.yaml.
+type EncryptionConfig struct {
+	KeyProvider     string   `yaml:"key_provider,omitempty" json:"key_provider,omitempty"`           // "env", "file", "vault" or "age"
+	KeyFile         string   `yaml:"key_file,omitempty" json:"key_file,omitempty"`                   // Raw, wrapped or age-encrypted key, depending on the provider
+	VaultAddress    string   `yaml:"vault_address,omitempty" json:"vault_address,omitempty"`         // Transit server, e.g. https://vault:8200
+	VaultMount      string   `yaml:"vault_mount,omitempty" json:"vault_mount,omitempty"`             // Transit mount path, default "transit"
+	VaultKeyName    string   `yaml:"vault_key_name,omitempty" json:"vault_key_name,omitempty"`       // Transit key that wraps the master key
+	AgeIdentityFile string   `yaml:"age_identity_file,omitempty" json:"age_identity_file,omitempty"` // AGE-SECRET-KEY-1... file that opens key_file
+	AgeRecipients   []string `yaml:"age_recipients,omitempty" json:"age_recipients,omitempty"`       // age1... recipients a new key is encrypted to
+
+	vaultToken string
+}
+
+// WithEnv returns a copy of the encryption configuration with environment variables
+// applied on top of the values from config.yaml
+func (e EncryptionConfig) WithEnv() (EncryptionConfig, error) {
+	if v := os.Getenv("UET_KEY_PROVIDER"); v != "" {
+		e.KeyProvider = v
+	}
+	if v := os.Getenv("UET_KEY_FILE"); v != "" {
+		e.KeyFile = v
+	}
+	if v := firstEnv("UET_VAULT_ADDR", "VAULT_ADDR"); v != "" {
+		e.VaultAddress = v
+	}
+	if v := os.Getenv("UET_VAULT_MOUNT"); v != "" {
+		e.VaultMount = v
+	}
+	if v := os.Getenv("UET_VAULT_KEY_NAME"); v != "" {
+		e.VaultKeyName = v
+	}
+	if v := os.Getenv("UET_AGE_IDENTITY_FILE"); v != "" {
+		e.AgeIdentityFile = v
+	}
+	if v := os.Getenv("UET_AGE_RECIPIENTS"); v != "" {
+		e.AgeRecipients = strings.Split(v, ",")
+	}
+	e.vaultToken = firstEnv("UET_VAULT_TOKEN", "VAULT_TOKEN")
+
+	return e, e.Validate()
+}
+
+// Validate checks that the selected provider has the settings it needs
+func (e EncryptionConfig) Validate() error {
+	switch e.ProviderName() {
+	case KeyProviderEnv:
+	case KeyProviderFile:
+		if e.KeyFile == "" {
+			return fmt.Errorf("encryption key_file is required for the file key provider")
+		}
+	case KeyProviderVault:
+		if e.KeyFile == "" || e.VaultAddress == "" || e.VaultKeyName == "" {
+			return fmt.Errorf("encryption key_file, vault_address and vault_key_name are required for the vault key provider")
+		}
+	case KeyProviderAge:
+		if e.KeyFile == "" || e.AgeIdentityFile == "" {
+			return fmt.Errorf("encryption key_file and age_identity_file are required for the age key provider")
+		}
+	default:
+		return fmt.Errorf("invalid encryption key_provider: %s (must be one of: env, file, vault, age)", e.KeyProvider)
+	}
+	return nil
+}
+
+// ProviderName returns the configured key provider, defaulting to env
+func (e EncryptionConfig) ProviderName() string {
+	if e.KeyProvider == "" {
+		return KeyProviderEnv
+	}
+	return strings.ToLower(e.KeyProvider)
+}
+
+// Provider builds the configured key provider. Call WithEnv first so the Vault token
+// and any overrides are picked up.
+func (e EncryptionConfig) Provider() (crypto.KeyProvider, error) {
+	if err := e.Validate(); err != nil {
+		return nil, err
+	}
+
+	switch e.ProviderName() {
+	case KeyProviderFile:
+		return crypto.FileKeyProvider{Path: e.KeyFile}, nil
+	case KeyProviderVault:
+		if e.vaultToken == "" {
+			return nil, fmt.Errorf("UET_VAULT_TOKEN (or VAULT_TOKEN) is required for the vault key provider")
+		}
+		return crypto.VaultTransitKeyProvider{
+			Address:        e.VaultAddress,
+			Token:          e.vaultToken,
+			Mount:          e.VaultMount,
+			KeyName:        e.VaultKeyName,
+			WrappedKeyFile: e.KeyFile,
+		}, nil
+	case KeyProviderAge:
+		return crypto.AgeKeyProvider{
+			KeyFile:      e.KeyFile,
+			IdentityFile: e.AgeIdentityFile,
+			Recipients:   e.AgeRecipients,
+		}, nil
+	default:
+		return crypto.EnvKeyProvider{}, nil
+	}
+}
+
+// NewCryptoManager returns a crypto manager using the key of the configured provider,
+// with environment overrides applied
+func (e EncryptionConfig) NewCryptoManager() (*crypto.CryptoManager, error) {
+	resolved, err := e.WithEnv()
+	if err != nil {
+		return nil, err
+	}
+	provider, err := resolved.Provider()
+	if err != nil {
+		return nil, err
+	}
+	return crypto.NewCryptoManagerFromProvider(provider)
+}
+
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
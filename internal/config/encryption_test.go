@@ -0,0 +1,120 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"user_experience_toolkit/internal/crypto"
+)
+
+func TestEncryptionConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     EncryptionConfig
+		wantErr bool
+	}{
+		{"default env provider", EncryptionConfig{}, false},
+		{"file provider", EncryptionConfig{KeyProvider: "file", KeyFile: "/keys/master.key"}, false},
+		{"file provider without path", EncryptionConfig{KeyProvider: "file"}, true},
+		{"vault provider", EncryptionConfig{KeyProvider: "vault", KeyFile: "master.key.wrapped", VaultAddress: "http://127.0.0.1:8200", VaultKeyName: "uet"}, false},
+		{"vault provider without key name", EncryptionConfig{KeyProvider: "vault", KeyFile: "master.key.wrapped", VaultAddress: "http://127.0.0.1:8200"}, true},
+		{"age provider", EncryptionConfig{KeyProvider: "age", KeyFile: "master.key.age", AgeIdentityFile: "identity.txt"}, false},
+		{"age provider without identity", EncryptionConfig{KeyProvider: "age", KeyFile: "master.key.age"}, true},
+		{"unknown provider", EncryptionConfig{KeyProvider: "kms"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEncryptionConfigWithEnv(t *testing.T) {
+	t.Setenv("UET_KEY_PROVIDER", "vault")
+	t.Setenv("UET_KEY_FILE", "/keys/master.key.wrapped")
+	t.Setenv("VAULT_ADDR", "http://127.0.0.1:8200")
+	t.Setenv("UET_VAULT_KEY_NAME", "uet")
+	t.Setenv("VAULT_TOKEN", "s.token")
+
+	cfg, err := EncryptionConfig{KeyProvider: "file"}.WithEnv()
+	if err != nil {
+		t.Fatalf("WithEnv() error = %v", err)
+	}
+	if cfg.ProviderName() != KeyProviderVault || cfg.KeyFile != "/keys/master.key.wrapped" || cfg.VaultAddress != "http://127.0.0.1:8200" {
+		t.Errorf("WithEnv() = %+v", cfg)
+	}
+
+	provider, err := cfg.Provider()
+	if err != nil {
+		t.Fatalf("Provider() error = %v", err)
+	}
+	vault, ok := provider.(crypto.VaultTransitKeyProvider)
+	if !ok {
+		t.Fatalf("Provider() = %T, want crypto.VaultTransitKeyProvider", provider)
+	}
+	if vault.Token != "s.token" {
+		t.Errorf("Vault token = %q, want it read from VAULT_TOKEN", vault.Token)
+	}
+}
+
+func TestLoadConfigWithFileKeyProvider(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	keyPath := filepath.Join(tmpDir, "master.key")
+
+	content := `
+encryption_enabled: true
+encryption:
+  key_provider: file
+  key_file: "` + keyPath + `"
+applications:
+  - id: "test-app"
+    name: "Test App"
+    type: "websdk"
+    enabled: true
+    client_id: "test_client"
+    client_secret: "my-secret"
+    api_hostname: "api-test.duosecurity.com"
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	// The key file is not created implicitly
+	if _, err := LoadConfig(configPath); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("LoadConfig() with a missing key file error = %v, want does not exist", err)
+	}
+
+	if _, err := (crypto.FileKeyProvider{Path: keyPath}).CreateKey(); err != nil {
+		t.Fatalf("CreateKey() error = %v", err)
+	}
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if err := cfg.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	data, _ := os.ReadFile(configPath)
+	if strings.Contains(string(data), "my-secret") {
+		t.Error("Config file should not contain the plaintext secret")
+	}
+	if !strings.Contains(string(data), "key_provider: file") {
+		t.Error("Save() should keep the encryption settings")
+	}
+
+	cfg2, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig() after save error = %v", err)
+	}
+	if cfg2.Applications[0].ClientSecret != "my-secret" {
+		t.Errorf("Decrypted secret = %s, want my-secret", cfg2.Applications[0].ClientSecret)
+	}
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+const (
+	// DefaultHistoryFile is the bbolt database of past login attempts, kept next to
+	// config.yaml so it shares its volume in Docker
+	DefaultHistoryFile = "history.db"
+
+	// DefaultHistoryMaxEntries is how many attempts are kept before the oldest are dropped
+	DefaultHistoryMaxEntries = 1000
+)
+
+// HistoryConfig controls where the authentication history is stored. Every field can
+// be overridden with a UET_HISTORY_* environment variable.
+type HistoryConfig struct {
+	Disabled   bool   `yaml:"disabled,omitempty" json:"disabled,omitempty"`       // Do not record login attempts
+	FilePath   string `yaml:"file_path,omitempty" json:"file_path,omitempty"`     // bbolt database, default history.db next to config.yaml
+	MaxEntries int    `yaml:"max_entries,omitempty" json:"max_entries,omitempty"` // Attempts kept, default 1000
+}
+
+// WithEnv returns a copy of the history configuration with UET_HISTORY_* environment
+// variables applied on top of the values from config.yaml
+func (h HistoryConfig) WithEnv() (HistoryConfig, error) {
+	if v := os.Getenv("UET_HISTORY_DISABLED"); v != "" {
+		disabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return h, fmt.Errorf("invalid UET_HISTORY_DISABLED: %q", v)
+		}
+		h.Disabled = disabled
+	}
+	if v := os.Getenv("UET_HISTORY_FILE"); v != "" {
+		h.FilePath = v
+	}
+	if v := os.Getenv("UET_HISTORY_MAX_ENTRIES"); v != "" {
+		maxEntries, err := strconv.Atoi(v)
+		if err != nil {
+			return h, fmt.Errorf("invalid UET_HISTORY_MAX_ENTRIES: %q", v)
+		}
+		h.MaxEntries = maxEntries
+	}
+
+	return h, h.Validate()
+}
+
+// Validate checks the history configuration
+func (h HistoryConfig) Validate() error {
+	if h.MaxEntries < 0 {
+		return fmt.Errorf("history max_entries must not be negative")
+	}
+	return nil
+}
+
+// Path returns the history database, defaulting to DefaultHistoryFile in the
+// directory of the config file at configPath
+func (h HistoryConfig) Path(configPath string) string {
+	if h.FilePath != "" {
+		return h.FilePath
+	}
+	return filepath.Join(filepath.Dir(configPath), DefaultHistoryFile)
+}
+
+// Limit returns how many attempts are kept, defaulting to DefaultHistoryMaxEntries
+func (h HistoryConfig) Limit() int {
+	if h.MaxEntries == 0 {
+		return DefaultHistoryMaxEntries
+	}
+	return h.MaxEntries
+}
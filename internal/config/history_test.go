@@ -0,0 +1,45 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestHistoryConfigDefaults(t *testing.T) {
+	cfg := HistoryConfig{}
+	if got, want := cfg.Path("/app/config/config.yaml"), filepath.Join("/app/config", DefaultHistoryFile); got != want {
+		t.Errorf("Path() = %s, want %s", got, want)
+	}
+	if cfg.Limit() != DefaultHistoryMaxEntries {
+		t.Errorf("Limit() = %d, want %d", cfg.Limit(), DefaultHistoryMaxEntries)
+	}
+}
+
+func TestHistoryConfigWithEnv(t *testing.T) {
+	t.Setenv("UET_HISTORY_DISABLED", "true")
+	t.Setenv("UET_HISTORY_FILE", "/data/history.db")
+	t.Setenv("UET_HISTORY_MAX_ENTRIES", "50")
+
+	cfg, err := HistoryConfig{MaxEntries: 10}.WithEnv()
+	if err != nil {
+		t.Fatalf("WithEnv() error = %v", err)
+	}
+	if !cfg.Disabled || cfg.Path("config.yaml") != "/data/history.db" || cfg.Limit() != 50 {
+		t.Errorf("WithEnv() = %+v", cfg)
+	}
+}
+
+func TestHistoryConfigWithEnvInvalid(t *testing.T) {
+	tests := map[string]string{
+		"UET_HISTORY_DISABLED":    "maybe",
+		"UET_HISTORY_MAX_ENTRIES": "-1",
+	}
+	for key, value := range tests {
+		t.Run(key, func(t *testing.T) {
+			t.Setenv(key, value)
+			if _, err := (HistoryConfig{}).WithEnv(); err == nil {
+				t.Errorf("WithEnv() should reject %s=%s", key, value)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentVersion is the config.yaml schema version written by this build. Files
+// without a version field are version 0.
+const CurrentVersion = 1
+
+// migration upgrades a parsed configuration by one schema version. Steps run before
+// secrets are decrypted, so they must not touch secret values.
+type migration struct {
+	version     int // version the step produces
+	description string
+	apply       func(c *Config) error
+}
+
+// migrations lists every step in order. To change the schema, append a step for
+// CurrentVersion+1 and bump CurrentVersion; never edit or reorder released steps.
+var migrations = []migration{
+	{version: 1, description: "set application type from the deprecated is_dmp flag", apply: migrateIsDMP},
+}
+
+// migrateIsDMP sets the type of applications written before types existed and drops
+// the is_dmp flag
+func migrateIsDMP(c *Config) error {
+	for i := range c.Applications {
+		if c.Applications[i].Type == "" {
+			if c.Applications[i].IsDMP {
+				c.Applications[i].Type = "dmp"
+			} else {
+				c.Applications[i].Type = "websdk"
+			}
+		}
+		c.Applications[i].IsDMP = false
+	}
+	return nil
+}
+
+// migrate brings the configuration to CurrentVersion and returns the descriptions of
+// the steps that ran. Files from a newer build are rejected, since saving them would
+// drop the settings this build does not know.
+func (c *Config) migrate() ([]string, error) {
+	if c.Version > CurrentVersion {
+		return nil, fmt.Errorf("config version %d is newer than this build supports (%d)", c.Version, CurrentVersion)
+	}
+
+	var applied []string
+	for _, step := range migrations {
+		if step.version <= c.Version {
+			continue
+		}
+		if err := step.apply(c); err != nil {
+			return applied, fmt.Errorf("migration to version %d (%s) failed: %w", step.version, step.description, err)
+		}
+		c.Version = step.version
+		applied = append(applied, step.description)
+	}
+	c.Version = CurrentVersion
+
+	return applied, nil
+}
+
+// BackupPath returns where the file at path is backed up before it is first saved
+// after a migration from version
+func BackupPath(path string, version int) string {
+	return fmt.Sprintf("%s.v%d.bak", path, version)
+}
+
+// backupBeforeMigratedSave copies the original file next to it the first time a
+// migrated configuration is saved. The caller must hold c.mu.
+func (c *Config) backupBeforeMigratedSave() error {
+	if c.backup == nil {
+		return nil
+	}
+	path := BackupPath(c.filepath, c.migratedFrom)
+	if err := writeFileAtomic(path, c.backup, 0600); err != nil {
+		return fmt.Errorf("failed to back up config file before migration: %v", err)
+	}
+	c.backup = nil
+	return nil
+}
+
+// MigrationPlan describes the migration LoadConfig would apply to a file
+type MigrationPlan struct {
+	FromVersion int
+	ToVersion   int
+	Steps       []string // descriptions of the steps, in order
+	Before      []byte   // the file as it is on disk, with secrets redacted
+	After       []byte   // the migrated file as it will be written, with secrets redacted
+}
+
+// PlanMigration reads a configuration file and reports the migration steps it needs
+// without changing the file. Secrets are redacted in Before and After, and the
+// master key is not needed.
+func PlanMigration(path string) (*MigrationPlan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	after := &Config{}
+	if err := yaml.Unmarshal(data, after); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %v", err)
+	}
+
+	plan := &MigrationPlan{FromVersion: after.Version}
+	if plan.Steps, err = after.migrate(); err != nil {
+		return nil, err
+	}
+	plan.ToVersion = after.Version
+
+	if plan.Before, err = redactYAMLSecrets(data); err != nil {
+		return nil, err
+	}
+	if plan.After, err = after.redactedYAML(); err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+// Pending reports whether the plan changes the file
+func (p *MigrationPlan) Pending() bool {
+	return p.FromVersion != p.ToVersion
+}
+
+// redactedYAML renders the configuration with every secret replaced by RedactedSecret
+func (c *Config) redactedYAML() ([]byte, error) {
+	out := c.snapshot()
+	out.Version = c.Version
+	for i := range out.Tenants {
+		out.Tenants[i] = out.Tenants[i].Redacted()
+	}
+	out.Applications = RedactApplications(out.Applications)
+	return yaml.Marshal(out)
+}
+
+// secretKeys are the YAML keys whose values redactYAMLSecrets replaces
+var secretKeys = map[string]bool{
+	"admin_api_secret":         true,
+	"pending_admin_api_secret": true,
+	"client_secret":            true,
+	"signing_key":              true,
+}
+
+// redactYAMLSecrets returns the file with the value of every non-empty secret replaced
+// by RedactedSecret. Everything else, including comments and layout, is kept as it is.
+// A redacted value runs to the end of its line, together with the more indented lines
+// that continue it, such as the body of a PEM block scalar.
+func redactYAMLSecrets(data []byte) ([]byte, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %v", err)
+	}
+
+	lines := strings.SplitAfter(string(data), "\n")
+	dropped := make(map[int]bool)
+	var walk func(n *yaml.Node)
+	walk = func(n *yaml.Node) {
+		if n.Kind != yaml.MappingNode {
+			for _, child := range n.Content {
+				walk(child)
+			}
+			return
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			if !secretKeys[key.Value] || value.Kind != yaml.ScalarNode || value.Value == "" {
+				walk(value)
+				continue
+			}
+
+			first := value.Line - 1
+			line := lines[first]
+			newline := line[len(strings.TrimRight(line, "\r\n")):]
+			lines[first] = line[:value.Column-1] + RedactedSecret + newline
+
+			// Blank lines only belong to the value when an indented line follows them
+			var blanks []int
+			for j := first + 1; j < len(lines); j++ {
+				text := strings.TrimRight(lines[j], "\r\n")
+				if strings.TrimSpace(text) == "" {
+					blanks = append(blanks, j)
+					continue
+				}
+				if len(text)-len(strings.TrimLeft(text, " ")) < key.Column {
+					break
+				}
+				for _, blank := range blanks {
+					dropped[blank] = true
+				}
+				blanks = nil
+				dropped[j] = true
+			}
+		}
+	}
+	walk(&root)
+
+	var out strings.Builder
+	for i, line := range lines {
+		if !dropped[i] {
+			out.WriteString(line)
+		}
+	}
+	return []byte(out.String()), nil
+}
@@ -0,0 +1,46 @@
+package config
+
+// RedactedSecret replaces secret values in API responses. Sending it back in an
+// update keeps the secret that is already stored.
+const RedactedSecret = "[REDACTED]"
+
+// redact returns RedactedSecret for a non-empty secret
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return RedactedSecret
+}
+
+// keepRedacted returns the stored secret when the submitted value is the placeholder
+func keepRedacted(submitted, stored string) string {
+	if submitted == RedactedSecret {
+		return stored
+	}
+	return submitted
+}
+
+// Redacted returns a copy of the application with client_secret and signing_key
+// replaced by RedactedSecret
+func (a Application) Redacted() Application {
+	a.ClientSecret = redact(a.ClientSecret)
+	a.SigningKey = redact(a.SigningKey)
+	return a
+}
+
+// Redacted returns a copy of the tenant with admin_api_secret and a pending rotation
+// secret replaced by RedactedSecret
+func (t Tenant) Redacted() Tenant {
+	t.AdminAPISecret = redact(t.AdminAPISecret)
+	t.PendingAdminAPISecret = redact(t.PendingAdminAPISecret)
+	return t
+}
+
+// RedactApplications returns redacted copies of apps
+func RedactApplications(apps []Application) []Application {
+	redacted := make([]Application, len(apps))
+	for i, app := range apps {
+		redacted[i] = app.Redacted()
+	}
+	return redacted
+}
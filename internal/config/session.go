@@ -0,0 +1,130 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Session storage backends
+const (
+	SessionBackendMemory = "memory" // In-process; sessions are lost on restart (default)
+	SessionBackendFile   = "file"   // Local bbolt database; survives restarts of a single instance
+	SessionBackendRedis  = "redis"  // Redis-compatible server; shared between replicas
+)
+
+const (
+	// DefaultSessionTTL is how long an idle session is kept
+	DefaultSessionTTL = 30 * time.Minute
+
+	// DefaultSessionFile is the bbolt database used by the file backend when no path is set
+	DefaultSessionFile = "sessions.db"
+)
+
+// SessionConfig controls where sessions are stored and how the session cookie is set.
+// Every field can be overridden with a UET_SESSION_* environment variable.
+type SessionConfig struct {
+	Backend        string `yaml:"backend,omitempty" json:"backend,omitempty"`                   // "memory", "file" or "redis"
+	FilePath       string `yaml:"file_path,omitempty" json:"file_path,omitempty"`               // bbolt database for the file backend
+	RedisURL       string `yaml:"redis_url,omitempty" json:"redis_url,omitempty"`               // redis:// or rediss:// URL for the redis backend
+	TTL            string `yaml:"ttl,omitempty" json:"ttl,omitempty"`                           // Idle timeout as a Go duration, e.g. "30m"
+	CookieSecure   bool   `yaml:"cookie_secure,omitempty" json:"cookie_secure,omitempty"`       // Only send the cookie over HTTPS
+	CookieSameSite string `yaml:"cookie_same_site,omitempty" json:"cookie_same_site,omitempty"` // "Lax" (default), "Strict" or "None"
+	CookieDomain   string `yaml:"cookie_domain,omitempty" json:"cookie_domain,omitempty"`       // Empty for a host-only cookie
+}
+
+// WithEnv returns a copy of the session configuration with UET_SESSION_* environment
+// variables applied on top of the values from config.yaml
+func (s SessionConfig) WithEnv() (SessionConfig, error) {
+	if v := os.Getenv("UET_SESSION_BACKEND"); v != "" {
+		s.Backend = v
+	}
+	if v := os.Getenv("UET_SESSION_FILE"); v != "" {
+		s.FilePath = v
+	}
+	if v := os.Getenv("UET_SESSION_REDIS_URL"); v != "" {
+		s.RedisURL = v
+	}
+	if v := os.Getenv("UET_SESSION_TTL"); v != "" {
+		s.TTL = v
+	}
+	if v := os.Getenv("UET_SESSION_COOKIE_SECURE"); v != "" {
+		secure, err := strconv.ParseBool(v)
+		if err != nil {
+			return s, fmt.Errorf("invalid UET_SESSION_COOKIE_SECURE: %q", v)
+		}
+		s.CookieSecure = secure
+	}
+	if v := os.Getenv("UET_SESSION_COOKIE_SAMESITE"); v != "" {
+		s.CookieSameSite = v
+	}
+	if v := os.Getenv("UET_SESSION_COOKIE_DOMAIN"); v != "" {
+		s.CookieDomain = v
+	}
+
+	return s, s.Validate()
+}
+
+// Validate checks the session configuration
+func (s SessionConfig) Validate() error {
+	switch s.BackendName() {
+	case SessionBackendMemory, SessionBackendFile:
+	case SessionBackendRedis:
+		if s.RedisURL == "" {
+			return fmt.Errorf("session redis_url is required for the redis backend")
+		}
+	default:
+		return fmt.Errorf("invalid session backend: %s (must be one of: memory, file, redis)", s.Backend)
+	}
+
+	if _, err := s.IdleTimeout(); err != nil {
+		return err
+	}
+
+	switch strings.ToLower(s.CookieSameSite) {
+	case "", "lax", "strict":
+	case "none":
+		// Browsers drop SameSite=None cookies that are not Secure
+		if !s.CookieSecure {
+			return fmt.Errorf("session cookie_same_site None requires cookie_secure")
+		}
+	default:
+		return fmt.Errorf("invalid session cookie_same_site: %s (must be one of: Lax, Strict, None)", s.CookieSameSite)
+	}
+
+	return nil
+}
+
+// BackendName returns the configured backend, defaulting to memory
+func (s SessionConfig) BackendName() string {
+	if s.Backend == "" {
+		return SessionBackendMemory
+	}
+	return strings.ToLower(s.Backend)
+}
+
+// IdleTimeout returns the session TTL, defaulting to DefaultSessionTTL
+func (s SessionConfig) IdleTimeout() (time.Duration, error) {
+	if s.TTL == "" {
+		return DefaultSessionTTL, nil
+	}
+	ttl, err := time.ParseDuration(s.TTL)
+	if err != nil || ttl <= 0 {
+		return 0, fmt.Errorf("invalid session ttl: %q (must be a positive duration such as 30m)", s.TTL)
+	}
+	return ttl, nil
+}
+
+// SameSite returns the SameSite cookie attribute in the form expected by Fiber
+func (s SessionConfig) SameSite() string {
+	switch strings.ToLower(s.CookieSameSite) {
+	case "strict":
+		return "Strict"
+	case "none":
+		return "None"
+	default:
+		return "Lax"
+	}
+}
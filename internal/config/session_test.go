@@ -0,0 +1,84 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSessionConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     SessionConfig
+		wantErr bool
+	}{
+		{"defaults", SessionConfig{}, false},
+		{"file backend", SessionConfig{Backend: "file", FilePath: "/tmp/sessions.db"}, false},
+		{"redis backend", SessionConfig{Backend: "redis", RedisURL: "redis://localhost:6379/0"}, false},
+		{"redis without url", SessionConfig{Backend: "redis"}, true},
+		{"unknown backend", SessionConfig{Backend: "memcached"}, true},
+		{"valid ttl", SessionConfig{TTL: "2h"}, false},
+		{"invalid ttl", SessionConfig{TTL: "soon"}, true},
+		{"negative ttl", SessionConfig{TTL: "-5m"}, true},
+		{"samesite strict", SessionConfig{CookieSameSite: "Strict"}, false},
+		{"samesite none with secure", SessionConfig{CookieSameSite: "None", CookieSecure: true}, false},
+		{"samesite none without secure", SessionConfig{CookieSameSite: "None"}, true},
+		{"invalid samesite", SessionConfig{CookieSameSite: "sometimes"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSessionConfigWithEnv(t *testing.T) {
+	t.Setenv("UET_SESSION_BACKEND", "file")
+	t.Setenv("UET_SESSION_FILE", "/data/sessions.db")
+	t.Setenv("UET_SESSION_TTL", "1h")
+	t.Setenv("UET_SESSION_COOKIE_SECURE", "true")
+	t.Setenv("UET_SESSION_COOKIE_SAMESITE", "none")
+	t.Setenv("UET_SESSION_COOKIE_DOMAIN", "uet.example.com")
+
+	cfg, err := SessionConfig{Backend: "memory", TTL: "10m"}.WithEnv()
+	if err != nil {
+		t.Fatalf("WithEnv() error = %v", err)
+	}
+
+	if cfg.BackendName() != SessionBackendFile {
+		t.Errorf("BackendName() = %s, want %s", cfg.BackendName(), SessionBackendFile)
+	}
+	if cfg.FilePath != "/data/sessions.db" {
+		t.Errorf("FilePath = %s, want /data/sessions.db", cfg.FilePath)
+	}
+	if ttl, _ := cfg.IdleTimeout(); ttl != time.Hour {
+		t.Errorf("IdleTimeout() = %s, want 1h", ttl)
+	}
+	if !cfg.CookieSecure || cfg.SameSite() != "None" || cfg.CookieDomain != "uet.example.com" {
+		t.Errorf("cookie settings = secure %v, samesite %s, domain %s", cfg.CookieSecure, cfg.SameSite(), cfg.CookieDomain)
+	}
+}
+
+func TestSessionConfigWithEnvInvalidBool(t *testing.T) {
+	t.Setenv("UET_SESSION_COOKIE_SECURE", "maybe")
+
+	if _, err := (SessionConfig{}).WithEnv(); err == nil {
+		t.Error("WithEnv() should reject an invalid UET_SESSION_COOKIE_SECURE")
+	}
+}
+
+func TestSessionConfigDefaults(t *testing.T) {
+	cfg := SessionConfig{}
+	if cfg.BackendName() != SessionBackendMemory {
+		t.Errorf("BackendName() = %s, want %s", cfg.BackendName(), SessionBackendMemory)
+	}
+	if ttl, _ := cfg.IdleTimeout(); ttl != DefaultSessionTTL {
+		t.Errorf("IdleTimeout() = %s, want %s", ttl, DefaultSessionTTL)
+	}
+	if cfg.SameSite() != "Lax" {
+		t.Errorf("SameSite() = %s, want Lax", cfg.SameSite())
+	}
+}
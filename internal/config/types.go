@@ -0,0 +1,151 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ApplicationType describes one kind of demo application. Adding a flow means
+// registering its type here and its routes with handlers.RegisterFlow.
+type ApplicationType struct {
+	ID              string // value of Application.Type
+	Label           string // short label for tags, e.g. "SAML"
+	Name            string // descriptive name for forms, e.g. "SAML 2.0 (Single Sign-On)"
+	IntegrationType string // Duo Admin API integration type the application is created as
+
+	// Validate checks the type-specific fields of an application
+	Validate func(app *Application) error
+}
+
+var (
+	typesMu          sync.RWMutex
+	applicationTypes = []ApplicationType{
+		{ID: "websdk", Label: "SDK", Name: "WebSDK (Universal Prompt)", IntegrationType: "websdk", Validate: validateClientCredentials},
+		{ID: "dmp", Label: "DMP", Name: "DMP (Device Management Portal)", IntegrationType: "device-management-portal", Validate: validateClientCredentials},
+		{ID: "saml", Label: "SAML", Name: "SAML 2.0 (Single Sign-On)", IntegrationType: "sso-generic", Validate: validateSAML},
+		{ID: "oidc", Label: "OIDC", Name: "OIDC (OpenID Connect)", IntegrationType: "sso-oidc-generic", Validate: validateOIDC},
+		{ID: "authapi", Label: "Auth API", Name: "Auth API (preauth and auth)", IntegrationType: "authapi", Validate: validateClientCredentials},
+		{ID: "websdkv2", Label: "SDK v2", Name: "Web SDK v2 (legacy iframe)", IntegrationType: "websdk", Validate: validateWebSDKV2},
+	}
+)
+
+// RegisterApplicationType adds a new application type. It fails if the ID is already taken.
+func RegisterApplicationType(t ApplicationType) error {
+	if t.ID == "" {
+		return fmt.Errorf("application type ID is required")
+	}
+
+	typesMu.Lock()
+	defer typesMu.Unlock()
+
+	for _, existing := range applicationTypes {
+		if existing.ID == t.ID {
+			return fmt.Errorf("application type %s is already registered", t.ID)
+		}
+	}
+	applicationTypes = append(applicationTypes, t)
+	return nil
+}
+
+// LookupApplicationType returns the registered type with the given ID
+func LookupApplicationType(id string) (ApplicationType, bool) {
+	typesMu.RLock()
+	defer typesMu.RUnlock()
+
+	for _, t := range applicationTypes {
+		if t.ID == id {
+			return t, true
+		}
+	}
+	return ApplicationType{}, false
+}
+
+// ApplicationTypes returns all registered types in registration order
+func ApplicationTypes() []ApplicationType {
+	typesMu.RLock()
+	defer typesMu.RUnlock()
+
+	return append([]ApplicationType(nil), applicationTypes...)
+}
+
+// applicationTypeIDs lists the registered type IDs for error messages
+func applicationTypeIDs() string {
+	var ids []string
+	for _, t := range ApplicationTypes() {
+		ids = append(ids, t.ID)
+	}
+	return strings.Join(ids, ", ")
+}
+
+// TypeLabel returns the short label of the application's type
+func (a *Application) TypeLabel() string {
+	if t, ok := LookupApplicationType(a.GetApplicationType()); ok {
+		return t.Label
+	}
+	return strings.ToUpper(a.GetApplicationType())
+}
+
+// validateClientCredentials requires the integration key and secret used by the
+// WebSDK, DMP and Auth API flows
+func validateClientCredentials(app *Application) error {
+	if app.ClientID == "" {
+		return fmt.Errorf("client_id is required")
+	}
+	if app.ClientSecret == "" {
+		return fmt.Errorf("client_secret is required")
+	}
+	return nil
+}
+
+func validateSAML(app *Application) error {
+	if app.EntityID == "" {
+		return fmt.Errorf("entity_id is required for SAML applications")
+	}
+	if app.ACSURL == "" {
+		return fmt.Errorf("acs_url is required for SAML applications")
+	}
+	if app.ClockSkewSeconds < 0 {
+		return fmt.Errorf("clock_skew_seconds must not be negative")
+	}
+	// ClientID and ClientSecret are not required for SAML
+	return nil
+}
+
+func validateOIDC(app *Application) error {
+	// For OIDC - require client credentials and redirect URI
+	if app.ClientID == "" {
+		return fmt.Errorf("client_id is required for OIDC applications")
+	}
+	if app.ClientSecret == "" && app.PKCEMode != PKCEModePublic {
+		return fmt.Errorf("client_secret is required for OIDC applications")
+	}
+	if app.RedirectURI == "" {
+		return fmt.Errorf("redirect_uri is required for OIDC applications")
+	}
+	switch app.PKCEMode {
+	case PKCEModeOff, PKCEModeS256, PKCEModePublic:
+	default:
+		return fmt.Errorf("invalid pkce_mode: %s (must be one of: s256, public, or empty)", app.PKCEMode)
+	}
+	for _, scope := range app.OIDCScopes {
+		if scope == "" || strings.ContainsAny(scope, " \t\n") {
+			return fmt.Errorf("invalid OIDC scope: %q", scope)
+		}
+	}
+	return nil
+}
+
+// validateWebSDKV2 checks the key lengths the Web SDK v2 signatures require
+func validateWebSDKV2(app *Application) error {
+	if err := validateClientCredentials(app); err != nil {
+		return err
+	}
+	if len(app.ClientID) != 20 {
+		return fmt.Errorf("client_id must be a 20 character integration key for Web SDK v2 applications")
+	}
+	if len(app.ClientSecret) != 40 {
+		return fmt.Errorf("client_secret must be a 40 character secret key for Web SDK v2 applications")
+	}
+	return nil
+}
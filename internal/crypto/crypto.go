@@ -3,14 +3,15 @@ package crypto
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"io"
-	"os"
-	"regexp"
 	"strings"
+	"sync"
 
 	"golang.org/x/crypto/pbkdf2"
 )
@@ -18,73 +19,123 @@ import (
 // EncryptedFieldPrefix identifies encrypted fields in YAML
 const EncryptedFieldPrefix = "ENC[AES256_GCM,"
 
-var encryptedFieldRegex = regexp.MustCompile(`ENC\[AES256_GCM,([^,]+),([^\]]+)\]`)
+// envelopeVersion marks fields written in the versioned envelope format
+const envelopeVersion = "v2"
+
+// legacySalt is the fixed salt used by the original ENC[AES256_GCM,<nonce>,<ciphertext>]
+// format. It is only used to read fields written before the versioned envelope.
+var legacySalt = []byte("uet-salt")
+
+// saltSize is the length in bytes of the random salt generated for a config file
+const saltSize = 16
 
 // CryptoManager handles encryption/decryption of sensitive configuration fields
 type CryptoManager struct {
-	masterKey []byte
+	secret    []byte // raw master key material
+	masterKey []byte // key derived with the legacy fixed salt
+
+	mu      sync.Mutex
+	salt    []byte            // salt used for new encryptions
+	derived map[string][]byte // derived keys by base64 salt
 }
 
 // NewCryptoManager creates a new crypto manager with a master key
 // The master key can come from:
 // 1. Environment variable: UET_MASTER_KEY
-// 2. Key file: .uet_key (in app directory)
-// 3. Generated on first run and saved to .uet_key
+// 2. Key file: .uet_key (in app directory), created by uetctl init-key
+//
+// Use NewCryptoManagerFromProvider to read the key from an explicit location instead.
 func NewCryptoManager() (*CryptoManager, error) {
-	// Try environment variable first
-	if key := os.Getenv("UET_MASTER_KEY"); key != "" {
-		derivedKey := deriveKey([]byte(key), []byte("uet-salt"))
-		return &CryptoManager{masterKey: derivedKey}, nil
-	}
+	return NewCryptoManagerFromProvider(EnvKeyProvider{})
+}
 
-	// Try key file
-	keyPath := ".uet_key"
-	if data, err := os.ReadFile(keyPath); err == nil {
-		derivedKey := deriveKey(data, []byte("uet-salt"))
-		return &CryptoManager{masterKey: derivedKey}, nil
-	}
+// NewCryptoManagerWithKey creates a crypto manager with a specific key (for testing)
+func NewCryptoManagerWithKey(password string) *CryptoManager {
+	return newCryptoManager([]byte(password))
+}
 
-	// Generate new key and save
-	key := make([]byte, 32)
-	if _, err := rand.Read(key); err != nil {
-		return nil, fmt.Errorf("failed to generate key: %w", err)
+func newCryptoManager(secret []byte) *CryptoManager {
+	return &CryptoManager{
+		secret:    secret,
+		masterKey: deriveKey(secret, legacySalt),
+		derived:   make(map[string][]byte),
 	}
+}
+
+// deriveKey derives a 32-byte encryption key from a password using PBKDF2
+func deriveKey(password, salt []byte) []byte {
+	return pbkdf2.Key(password, salt, 100000, 32, sha256.New)
+}
 
-	// Save to file with restricted permissions
-	if err := os.WriteFile(keyPath, key, 0600); err != nil {
-		return nil, fmt.Errorf("failed to save key file: %w", err)
+// KeyID returns the identifier of the master key under the manager's salt, recorded in
+// every field it encrypts. The ID is computed from the salted key, so the same master
+// key has unrelated IDs in files with different salts.
+func (cm *CryptoManager) KeyID() (string, error) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	salt, err := cm.currentSalt()
+	if err != nil {
+		return "", err
 	}
+	return keyID(cm.keyForSalt(salt)), nil
+}
 
-	derivedKey := deriveKey(key, []byte("uet-salt"))
-	return &CryptoManager{masterKey: derivedKey}, nil
+// keyID returns the identifier of a derived key: a truncated HMAC of a fixed label, so
+// the ID reveals nothing about the key itself
+func keyID(key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("uet-key-id"))
+	return hex.EncodeToString(mac.Sum(nil)[:4])
 }
 
-// NewCryptoManagerWithKey creates a crypto manager with a specific key (for testing)
-func NewCryptoManagerWithKey(password string) *CryptoManager {
-	derivedKey := deriveKey([]byte(password), []byte("uet-salt"))
-	return &CryptoManager{masterKey: derivedKey}
+// currentSalt returns the salt used for new encryptions, generating it on first use.
+// The caller must hold cm.mu.
+func (cm *CryptoManager) currentSalt() ([]byte, error) {
+	if cm.salt == nil {
+		salt := make([]byte, saltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, fmt.Errorf("failed to generate salt: %w", err)
+		}
+		cm.salt = salt
+	}
+	return cm.salt, nil
 }
 
-// deriveKey derives a 32-byte encryption key from a password using PBKDF2
-func deriveKey(password, salt []byte) []byte {
-	return pbkdf2.Key(password, salt, 100000, 32, sha256.New)
+// keyForSalt returns the key derived for a salt, deriving and caching it on first use.
+// The caller must hold cm.mu.
+func (cm *CryptoManager) keyForSalt(salt []byte) []byte {
+	id := base64.StdEncoding.EncodeToString(salt)
+	if key, ok := cm.derived[id]; ok {
+		return key
+	}
+	key := deriveKey(cm.secret, salt)
+	cm.derived[id] = key
+	return key
 }
 
 // Encrypt encrypts plaintext and returns a formatted encrypted string
-// Format: ENC[AES256_GCM,<nonce>,<ciphertext>]
+// Format: ENC[AES256_GCM,v2,<key id>,<salt>,<nonce>,<ciphertext>]
+//
+// All fields encrypted by one manager share a salt: the salt of the first versioned
+// field it decrypted, or a random salt generated on the first encryption.
 func (cm *CryptoManager) Encrypt(plaintext string) (string, error) {
 	if plaintext == "" {
 		return "", nil
 	}
 
-	block, err := aes.NewCipher(cm.masterKey)
+	cm.mu.Lock()
+	salt, err := cm.currentSalt()
 	if err != nil {
-		return "", fmt.Errorf("failed to create cipher: %w", err)
+		cm.mu.Unlock()
+		return "", err
 	}
+	key := cm.keyForSalt(salt)
+	cm.mu.Unlock()
 
-	gcm, err := cipher.NewGCM(block)
+	gcm, err := newGCM(key)
 	if err != nil {
-		return "", fmt.Errorf("failed to create GCM: %w", err)
+		return "", err
 	}
 
 	// Generate nonce
@@ -96,14 +147,17 @@ func (cm *CryptoManager) Encrypt(plaintext string) (string, error) {
 	// Encrypt
 	ciphertext := gcm.Seal(nil, nonce, []byte(plaintext), nil)
 
-	// Format: ENC[AES256_GCM,base64(nonce),base64(ciphertext)]
-	nonceB64 := base64.StdEncoding.EncodeToString(nonce)
-	ciphertextB64 := base64.StdEncoding.EncodeToString(ciphertext)
-
-	return fmt.Sprintf("ENC[AES256_GCM,%s,%s]", nonceB64, ciphertextB64), nil
+	return fmt.Sprintf("%s%s,%s,%s,%s,%s]",
+		EncryptedFieldPrefix,
+		envelopeVersion,
+		keyID(key),
+		base64.StdEncoding.EncodeToString(salt),
+		base64.StdEncoding.EncodeToString(nonce),
+		base64.StdEncoding.EncodeToString(ciphertext),
+	), nil
 }
 
-// Decrypt decrypts an encrypted field
+// Decrypt decrypts an encrypted field in either the versioned or the legacy format
 // Returns the original string if not encrypted
 func (cm *CryptoManager) Decrypt(encryptedField string) (string, error) {
 	// If not encrypted, return as-is
@@ -111,43 +165,115 @@ func (cm *CryptoManager) Decrypt(encryptedField string) (string, error) {
 		return encryptedField, nil
 	}
 
-	// Parse encrypted field: ENC[AES256_GCM,<nonce>,<ciphertext>]
-	matches := encryptedFieldRegex.FindStringSubmatch(encryptedField)
-	if len(matches) != 3 {
-		return "", fmt.Errorf("invalid encrypted field format")
+	env, err := parseEnvelope(encryptedField)
+	if err != nil {
+		return "", err
 	}
 
-	nonceB64 := matches[1]
-	ciphertextB64 := matches[2]
+	var key []byte
+	if env.version == "" {
+		key = cm.masterKey
+	} else {
+		cm.mu.Lock()
+		key = cm.keyForSalt(env.salt)
+		if id := keyID(key); env.keyID != id {
+			cm.mu.Unlock()
+			return "", fmt.Errorf("field was encrypted with key %s, but the current master key is %s", env.keyID, id)
+		}
+		if cm.salt == nil {
+			cm.salt = env.salt
+		}
+		cm.mu.Unlock()
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := gcm.Open(nil, env.nonce, env.ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt: %w", err)
+	}
+
+	return string(plaintext), nil
+}
+
+// FieldKeyID returns the key ID recorded in an encrypted field. Fields in the legacy
+// format carry no key ID and return an empty string.
+func FieldKeyID(encryptedField string) (string, error) {
+	env, err := parseEnvelope(encryptedField)
+	if err != nil {
+		return "", err
+	}
+	return env.keyID, nil
+}
+
+// envelope is a parsed encrypted field
+type envelope struct {
+	version    string // empty for the legacy format
+	keyID      string
+	salt       []byte
+	nonce      []byte
+	ciphertext []byte
+}
+
+// parseEnvelope parses ENC[AES256_GCM,v2,<key id>,<salt>,<nonce>,<ciphertext>] and the
+// legacy ENC[AES256_GCM,<nonce>,<ciphertext>]
+func parseEnvelope(field string) (*envelope, error) {
+	if !strings.HasPrefix(field, EncryptedFieldPrefix) || !strings.HasSuffix(field, "]") {
+		return nil, fmt.Errorf("invalid encrypted field format")
+	}
+	parts := strings.Split(strings.TrimSuffix(strings.TrimPrefix(field, EncryptedFieldPrefix), "]"), ",")
+
+	env := &envelope{}
+	var nonceB64, ciphertextB64 string
+	switch {
+	case len(parts) == 2:
+		nonceB64, ciphertextB64 = parts[0], parts[1]
+	case len(parts) == 5 && parts[0] == envelopeVersion:
+		env.version = parts[0]
+		env.keyID = parts[1]
+		salt, err := base64.StdEncoding.DecodeString(parts[2])
+		if err != nil || len(salt) == 0 {
+			return nil, fmt.Errorf("failed to decode salt")
+		}
+		env.salt = salt
+		nonceB64, ciphertextB64 = parts[3], parts[4]
+	case len(parts) > 0 && strings.HasPrefix(parts[0], "v"):
+		return nil, fmt.Errorf("unsupported encrypted field version %s", parts[0])
+	default:
+		return nil, fmt.Errorf("invalid encrypted field format")
+	}
 
 	// Decode base64
 	nonce, err := base64.StdEncoding.DecodeString(nonceB64)
 	if err != nil {
-		return "", fmt.Errorf("failed to decode nonce: %w", err)
+		return nil, fmt.Errorf("failed to decode nonce: %w", err)
 	}
 
 	ciphertext, err := base64.StdEncoding.DecodeString(ciphertextB64)
 	if err != nil {
-		return "", fmt.Errorf("failed to decode ciphertext: %w", err)
+		return nil, fmt.Errorf("failed to decode ciphertext: %w", err)
 	}
 
-	// Decrypt
-	block, err := aes.NewCipher(cm.masterKey)
-	if err != nil {
-		return "", fmt.Errorf("failed to create cipher: %w", err)
-	}
+	env.nonce = nonce
+	env.ciphertext = ciphertext
+	return env, nil
+}
 
-	gcm, err := cipher.NewGCM(block)
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
 	if err != nil {
-		return "", fmt.Errorf("failed to create GCM: %w", err)
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
 	}
 
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	gcm, err := cipher.NewGCM(block)
 	if err != nil {
-		return "", fmt.Errorf("failed to decrypt: %w", err)
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
 	}
 
-	return string(plaintext), nil
+	return gcm, nil
 }
 
 // IsEncrypted checks if a field is encrypted
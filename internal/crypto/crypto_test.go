@@ -1,6 +1,8 @@
 package crypto
 
 import (
+	"crypto/rand"
+	"encoding/base64"
 	"strings"
 	"testing"
 )
@@ -217,3 +219,149 @@ func TestEncryptionDeterminism(t *testing.T) {
 		t.Error("Both encrypted values should decrypt to original plaintext")
 	}
 }
+
+// encryptLegacy produces a field in the original ENC[AES256_GCM,<nonce>,<ciphertext>]
+// format, derived with the fixed salt
+func encryptLegacy(t *testing.T, password, plaintext string) string {
+	t.Helper()
+	gcm, err := newGCM(deriveKey([]byte(password), legacySalt))
+	if err != nil {
+		t.Fatalf("newGCM() error = %v", err)
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		t.Fatalf("rand.Read() error = %v", err)
+	}
+	ciphertext := gcm.Seal(nil, nonce, []byte(plaintext), nil)
+	return "ENC[AES256_GCM," + base64.StdEncoding.EncodeToString(nonce) + "," + base64.StdEncoding.EncodeToString(ciphertext) + "]"
+}
+
+func TestDecryptLegacyFormat(t *testing.T) {
+	cm := NewCryptoManagerWithKey("test-password")
+
+	legacy := encryptLegacy(t, "test-password", "legacy-secret")
+	decrypted, err := cm.Decrypt(legacy)
+	if err != nil {
+		t.Fatalf("Decrypt() of legacy field error = %v", err)
+	}
+	if decrypted != "legacy-secret" {
+		t.Errorf("Decrypt() = %v, want legacy-secret", decrypted)
+	}
+
+	keyID, err := FieldKeyID(legacy)
+	if err != nil {
+		t.Fatalf("FieldKeyID() error = %v", err)
+	}
+	if keyID != "" {
+		t.Errorf("FieldKeyID() of legacy field = %q, want empty", keyID)
+	}
+}
+
+func TestVersionedEnvelope(t *testing.T) {
+	cm := NewCryptoManagerWithKey("test-password")
+
+	encrypted, err := cm.Encrypt("my-secret")
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+
+	parts := strings.Split(strings.TrimSuffix(strings.TrimPrefix(encrypted, EncryptedFieldPrefix), "]"), ",")
+	if len(parts) != 5 || parts[0] != "v2" {
+		t.Fatalf("Encrypt() = %s, want ENC[AES256_GCM,v2,<kid>,<salt>,<nonce>,<ciphertext>]", encrypted)
+	}
+	if parts[1] != mustKeyID(t, cm) {
+		t.Errorf("Envelope key ID = %s, want %s", parts[1], mustKeyID(t, cm))
+	}
+
+	keyID, err := FieldKeyID(encrypted)
+	if err != nil {
+		t.Fatalf("FieldKeyID() error = %v", err)
+	}
+	if keyID != parts[1] {
+		t.Errorf("FieldKeyID() = %s, want %s", keyID, parts[1])
+	}
+
+	// Fields encrypted by one manager share its salt
+	second, _ := cm.Encrypt("other-secret")
+	if strings.Split(second, ",")[3] != parts[2] {
+		t.Error("Fields encrypted by the same manager should share a salt")
+	}
+
+	// Another manager with the same key uses a different random salt, and so a
+	// different key ID, but can decrypt
+	other := NewCryptoManagerWithKey("test-password")
+	otherEncrypted, _ := other.Encrypt("my-secret")
+	if strings.Split(otherEncrypted, ",")[3] == parts[2] {
+		t.Error("Independent managers should generate independent salts")
+	}
+	if mustKeyID(t, other) == parts[1] {
+		t.Error("The key ID should depend on the salt, not only on the master key")
+	}
+	decrypted, err := other.Decrypt(encrypted)
+	if err != nil {
+		t.Fatalf("Decrypt() with a fresh manager error = %v", err)
+	}
+	if decrypted != "my-secret" {
+		t.Errorf("Decrypt() = %v, want my-secret", decrypted)
+	}
+}
+
+func TestDecryptAdoptsSalt(t *testing.T) {
+	writer := NewCryptoManagerWithKey("test-password")
+	encrypted, _ := writer.Encrypt("my-secret")
+
+	reader := NewCryptoManagerWithKey("test-password")
+	if _, err := reader.Decrypt(encrypted); err != nil {
+		t.Fatalf("Decrypt() error = %v", err)
+	}
+
+	reencrypted, _ := reader.Encrypt("my-secret")
+	if strings.Split(reencrypted, ",")[3] != strings.Split(encrypted, ",")[3] {
+		t.Error("A manager should keep the salt of the file it decrypted")
+	}
+	if mustKeyID(t, reader) != mustKeyID(t, writer) {
+		t.Error("Managers with the same key and salt should report the same key ID")
+	}
+}
+
+func TestDecryptWrongKeyID(t *testing.T) {
+	cm1 := NewCryptoManagerWithKey("password1")
+	cm2 := NewCryptoManagerWithKey("password2")
+
+	encrypted, _ := cm1.Encrypt("secret-data")
+	_, err := cm2.Decrypt(encrypted)
+	if err == nil {
+		t.Fatal("Decrypt() with a different key should fail")
+	}
+	if !strings.Contains(err.Error(), mustKeyID(t, cm1)) {
+		t.Errorf("Decrypt() error = %v, want it to name key %s", err, mustKeyID(t, cm1))
+	}
+}
+
+func mustKeyID(t *testing.T, cm *CryptoManager) string {
+	t.Helper()
+	id, err := cm.KeyID()
+	if err != nil {
+		t.Fatalf("KeyID() error = %v", err)
+	}
+	return id
+}
+
+func TestDecryptInvalidEnvelope(t *testing.T) {
+	cm := NewCryptoManagerWithKey("test-password")
+
+	tests := []string{
+		"ENC[AES256_GCM,abc]",
+		"ENC[AES256_GCM,v9,kid,c2FsdA==,bm9uY2U=,Y3Q=]",
+		"ENC[AES256_GCM,v2,kid,!!!,bm9uY2U=,Y3Q=]",
+		"ENC[AES256_GCM,abc,def",
+	}
+
+	for _, field := range tests {
+		t.Run(field, func(t *testing.T) {
+			if _, err := cm.Decrypt(field); err == nil {
+				t.Errorf("Decrypt(%s) should fail", field)
+			}
+		})
+	}
+}
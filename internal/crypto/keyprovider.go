@@ -0,0 +1,401 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+)
+
+// masterKeySize is the length in bytes of generated master keys
+const masterKeySize = 32
+
+// EnvKeyFile is the key file in the working directory read by EnvKeyProvider when
+// UET_MASTER_KEY is not set
+const EnvKeyFile = ".uet_key"
+
+// KeyProvider supplies the master key material that field encryption keys are derived from
+type KeyProvider interface {
+	// Name describes the provider and where the key lives, for logs and errors
+	Name() string
+	// MasterKey returns the master key material, or an error when it is unavailable
+	MasterKey() ([]byte, error)
+}
+
+// KeyCreator is implemented by providers that can generate and store a new master key.
+// CreateKey fails if a key already exists, so an existing key is never overwritten.
+type KeyCreator interface {
+	CreateKey() ([]byte, error)
+}
+
+// NewCryptoManagerFromProvider creates a crypto manager with the master key of a provider
+func NewCryptoManagerFromProvider(p KeyProvider) (*CryptoManager, error) {
+	key, err := p.MasterKey()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", p.Name(), err)
+	}
+	if len(key) == 0 {
+		return nil, fmt.Errorf("%s: master key is empty", p.Name())
+	}
+	return newCryptoManager(key), nil
+}
+
+// EnvKeyProvider reads the master key from UET_MASTER_KEY and otherwise from the .uet_key
+// file in the working directory. A missing key is an error; CreateKey (uetctl init-key)
+// generates the file. This is the behaviour of NewCryptoManager and the default when no
+// provider is configured.
+type EnvKeyProvider struct{}
+
+// Name describes the provider
+func (EnvKeyProvider) Name() string {
+	return "env key provider (UET_MASTER_KEY or .uet_key)"
+}
+
+// MasterKey returns UET_MASTER_KEY, or the contents of .uet_key
+func (EnvKeyProvider) MasterKey() ([]byte, error) {
+	// Try environment variable first
+	if key := os.Getenv("UET_MASTER_KEY"); key != "" {
+		return []byte(key), nil
+	}
+
+	// Try key file
+	data, err := os.ReadFile(EnvKeyFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no master key found: set UET_MASTER_KEY, or create %s with uetctl init-key", EnvKeyFile)
+		}
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	return data, nil
+}
+
+// CreateKey writes a new random key to .uet_key
+func (EnvKeyProvider) CreateKey() ([]byte, error) {
+	if os.Getenv("UET_MASTER_KEY") != "" {
+		return nil, fmt.Errorf("UET_MASTER_KEY is set and takes precedence over %s", EnvKeyFile)
+	}
+	return writeNewKeyFile(EnvKeyFile)
+}
+
+// FileKeyProvider reads the master key from an explicit file. The file's bytes are used
+// as-is, and a missing file is an error rather than being created.
+type FileKeyProvider struct {
+	Path string
+}
+
+// Name describes the provider
+func (p FileKeyProvider) Name() string {
+	return fmt.Sprintf("file key provider (%s)", p.Path)
+}
+
+// MasterKey returns the contents of the key file
+func (p FileKeyProvider) MasterKey() ([]byte, error) {
+	if p.Path == "" {
+		return nil, fmt.Errorf("key file path is required")
+	}
+	data, err := os.ReadFile(p.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("key file %s does not exist", p.Path)
+		}
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	return data, nil
+}
+
+// CreateKey writes a new random key to the key file
+func (p FileKeyProvider) CreateKey() ([]byte, error) {
+	if p.Path == "" {
+		return nil, fmt.Errorf("key file path is required")
+	}
+	return writeNewKeyFile(p.Path)
+}
+
+// VaultTransitKeyProvider keeps the master key as a data key wrapped by a transit
+// secrets engine: HashiCorp Vault, OpenBao, or any local stand-in serving the same
+// /v1/<mount>/decrypt/<key> and /v1/<mount>/datakey/plaintext/<key> endpoints. Only the
+// wrapped key is stored on disk; it is unwrapped on every start.
+type VaultTransitKeyProvider struct {
+	Address        string // e.g. https://vault.example.com:8200
+	Token          string // sent as X-Vault-Token
+	Mount          string // transit mount path, default "transit"
+	KeyName        string // transit key that wraps the data key
+	WrappedKeyFile string // file holding the vault:v1:... ciphertext
+
+	Client *http.Client // optional; defaults to a client with a 10s timeout
+}
+
+// Name describes the provider
+func (p VaultTransitKeyProvider) Name() string {
+	return fmt.Sprintf("vault transit key provider (%s, key %s)", p.Address, p.KeyName)
+}
+
+// MasterKey unwraps the stored data key through the transit decrypt endpoint
+func (p VaultTransitKeyProvider) MasterKey() ([]byte, error) {
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	wrapped, err := os.ReadFile(p.WrappedKeyFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("wrapped key file %s does not exist", p.WrappedKeyFile)
+		}
+		return nil, fmt.Errorf("failed to read wrapped key file: %w", err)
+	}
+
+	var resp struct {
+		Plaintext string `json:"plaintext"`
+	}
+	body := map[string]string{"ciphertext": strings.TrimSpace(string(wrapped))}
+	if err := p.call("decrypt", body, &resp); err != nil {
+		return nil, err
+	}
+	return decodeTransitPlaintext(resp.Plaintext)
+}
+
+// CreateKey asks the transit engine for a new data key and stores its wrapped form
+func (p VaultTransitKeyProvider) CreateKey() ([]byte, error) {
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(p.WrappedKeyFile); err == nil {
+		return nil, fmt.Errorf("wrapped key file %s already exists", p.WrappedKeyFile)
+	}
+
+	var resp struct {
+		Plaintext  string `json:"plaintext"`
+		Ciphertext string `json:"ciphertext"`
+	}
+	if err := p.call("datakey/plaintext", map[string]int{"bits": masterKeySize * 8}, &resp); err != nil {
+		return nil, err
+	}
+	key, err := decodeTransitPlaintext(resp.Plaintext)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Ciphertext == "" {
+		return nil, fmt.Errorf("transit response has no ciphertext")
+	}
+
+	if err := writeKeyFile(p.WrappedKeyFile, []byte(resp.Ciphertext+"\n")); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (p VaultTransitKeyProvider) validate() error {
+	switch {
+	case p.Address == "":
+		return fmt.Errorf("vault address is required")
+	case p.Token == "":
+		return fmt.Errorf("vault token is required")
+	case p.KeyName == "":
+		return fmt.Errorf("vault transit key name is required")
+	case p.WrappedKeyFile == "":
+		return fmt.Errorf("wrapped key file path is required")
+	}
+	return nil
+}
+
+// call POSTs a JSON body to a transit endpoint and decodes the "data" object of the reply
+func (p VaultTransitKeyProvider) call(endpoint string, body interface{}, data interface{}) error {
+	mount := strings.Trim(p.Mount, "/")
+	if mount == "" {
+		mount = "transit"
+	}
+	url := fmt.Sprintf("%s/v1/%s/%s/%s", strings.TrimRight(p.Address, "/"), mount, endpoint, p.KeyName)
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("invalid vault request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", p.Token)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := p.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("vault request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read vault response: %w", err)
+	}
+
+	var envelope struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []string        `json:"errors"`
+	}
+	if err := json.Unmarshal(raw, &envelope); err != nil {
+		return fmt.Errorf("vault returned status %d with an invalid body", resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if len(envelope.Errors) > 0 {
+			return fmt.Errorf("vault returned status %d: %s", resp.StatusCode, strings.Join(envelope.Errors, "; "))
+		}
+		return fmt.Errorf("vault returned status %d", resp.StatusCode)
+	}
+	if len(envelope.Data) == 0 {
+		return fmt.Errorf("vault response has no data")
+	}
+	return json.Unmarshal(envelope.Data, data)
+}
+
+func decodeTransitPlaintext(plaintext string) ([]byte, error) {
+	if plaintext == "" {
+		return nil, fmt.Errorf("transit response has no plaintext")
+	}
+	key, err := base64.StdEncoding.DecodeString(plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode transit plaintext: %w", err)
+	}
+	return key, nil
+}
+
+// AgeKeyProvider keeps the master key in a file encrypted to one or more age X25519
+// recipients. The identity file holds the AGE-SECRET-KEY-1... line that opens it.
+type AgeKeyProvider struct {
+	KeyFile      string   // age-encrypted master key
+	IdentityFile string   // age identities used to decrypt KeyFile
+	Recipients   []string // age1... recipients a new key is encrypted to
+}
+
+// Name describes the provider
+func (p AgeKeyProvider) Name() string {
+	return fmt.Sprintf("age key provider (%s)", p.KeyFile)
+}
+
+// MasterKey decrypts the key file with the identity file
+func (p AgeKeyProvider) MasterKey() ([]byte, error) {
+	if p.KeyFile == "" {
+		return nil, fmt.Errorf("age key file path is required")
+	}
+	if p.IdentityFile == "" {
+		return nil, fmt.Errorf("age identity file path is required")
+	}
+
+	data, err := os.ReadFile(p.KeyFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("age key file %s does not exist", p.KeyFile)
+		}
+		return nil, fmt.Errorf("failed to read age key file: %w", err)
+	}
+	identityData, err := os.ReadFile(p.IdentityFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read age identity file: %w", err)
+	}
+	identities, err := age.ParseIdentities(bytes.NewReader(identityData))
+	if err != nil {
+		return nil, fmt.Errorf("invalid age identity file %s: %w", p.IdentityFile, err)
+	}
+
+	r, err := age.Decrypt(bytes.NewReader(data), identities...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt age key file: %w", err)
+	}
+	key, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt age key file: %w", err)
+	}
+	return key, nil
+}
+
+// CreateKey generates a new master key and writes it encrypted to the recipients
+func (p AgeKeyProvider) CreateKey() ([]byte, error) {
+	if p.KeyFile == "" {
+		return nil, fmt.Errorf("age key file path is required")
+	}
+	if len(p.Recipients) == 0 {
+		return nil, fmt.Errorf("at least one age recipient is required")
+	}
+	if _, err := os.Stat(p.KeyFile); err == nil {
+		return nil, fmt.Errorf("age key file %s already exists", p.KeyFile)
+	}
+
+	recipients := make([]age.Recipient, 0, len(p.Recipients))
+	for _, r := range p.Recipients {
+		recipient, err := age.ParseX25519Recipient(strings.TrimSpace(r))
+		if err != nil {
+			return nil, fmt.Errorf("invalid age recipient: %w", err)
+		}
+		recipients = append(recipients, recipient)
+	}
+
+	key := make([]byte, masterKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	var encrypted bytes.Buffer
+	w, err := age.Encrypt(&encrypted, recipients...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt key: %w", err)
+	}
+	if _, err := w.Write(key); err != nil {
+		return nil, fmt.Errorf("failed to encrypt key: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encrypt key: %w", err)
+	}
+	if err := writeKeyFile(p.KeyFile, encrypted.Bytes()); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// writeNewKeyFile generates a random master key and saves it to path
+func writeNewKeyFile(path string) ([]byte, error) {
+	key := make([]byte, masterKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	if err := writeKeyFile(path, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// writeKeyFile creates path with restricted permissions, failing if it already exists
+func writeKeyFile(path string, data []byte) error {
+	if dir := filepath.Dir(path); dir != "" && dir != "." {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return fmt.Errorf("failed to create key directory: %w", err)
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		if os.IsExist(err) {
+			return fmt.Errorf("key file %s already exists", path)
+		}
+		return fmt.Errorf("failed to save key file: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(path)
+		return fmt.Errorf("failed to save key file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return fmt.Errorf("failed to save key file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+)
+
+func TestFileKeyProvider(t *testing.T) {
+	keyPath := filepath.Join(t.TempDir(), "keys", "master.key")
+	p := FileKeyProvider{Path: keyPath}
+
+	// A missing key file is an error, never created implicitly
+	if _, err := NewCryptoManagerFromProvider(p); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("NewCryptoManagerFromProvider() with missing key error = %v, want does not exist", err)
+	}
+	if _, err := os.Stat(keyPath); !os.IsNotExist(err) {
+		t.Fatal("MasterKey() should not create the key file")
+	}
+
+	key, err := p.CreateKey()
+	if err != nil {
+		t.Fatalf("CreateKey() error = %v", err)
+	}
+	info, err := os.Stat(keyPath)
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Key file permissions = %o, want 600", info.Mode().Perm())
+	}
+
+	got, err := p.MasterKey()
+	if err != nil {
+		t.Fatalf("MasterKey() error = %v", err)
+	}
+	if !bytes.Equal(got, key) {
+		t.Error("MasterKey() does not match the created key")
+	}
+
+	if _, err := p.CreateKey(); err == nil {
+		t.Error("CreateKey() should refuse to overwrite an existing key")
+	}
+}
+
+func TestEnvKeyProvider(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv("UET_MASTER_KEY", "")
+	p := EnvKeyProvider{}
+
+	// Without UET_MASTER_KEY or .uet_key there is no key, and none is generated
+	if _, err := p.MasterKey(); err == nil || !strings.Contains(err.Error(), "uetctl init-key") {
+		t.Fatalf("MasterKey() without a key error = %v, want a hint to uetctl init-key", err)
+	}
+	if _, err := os.Stat(EnvKeyFile); !os.IsNotExist(err) {
+		t.Fatal("MasterKey() should not create the key file")
+	}
+
+	key, err := p.CreateKey()
+	if err != nil {
+		t.Fatalf("CreateKey() error = %v", err)
+	}
+	got, err := p.MasterKey()
+	if err != nil {
+		t.Fatalf("MasterKey() error = %v", err)
+	}
+	if !bytes.Equal(got, key) {
+		t.Error("MasterKey() does not match the created key")
+	}
+	if _, err := p.CreateKey(); err == nil {
+		t.Error("CreateKey() should refuse to overwrite an existing key")
+	}
+
+	// UET_MASTER_KEY wins over the key file
+	t.Setenv("UET_MASTER_KEY", "env-key")
+	if got, _ := p.MasterKey(); string(got) != "env-key" {
+		t.Errorf("MasterKey() = %q, want UET_MASTER_KEY", got)
+	}
+}
+
+func TestEmptyKeyRejected(t *testing.T) {
+	keyPath := filepath.Join(t.TempDir(), "empty.key")
+	if err := os.WriteFile(keyPath, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewCryptoManagerFromProvider(FileKeyProvider{Path: keyPath}); err == nil {
+		t.Error("NewCryptoManagerFromProvider() should reject an empty key")
+	}
+}
+
+// fakeTransit serves the transit datakey and decrypt endpoints, wrapping keys by
+// base64-encoding them behind a vault:v1: prefix
+func fakeTransit(t *testing.T, token string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("X-Vault-Token") != token {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v1/transit/datakey/plaintext/uet":
+			plaintext := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32))
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": map[string]string{"plaintext": plaintext, "ciphertext": "vault:v1:" + plaintext},
+			})
+		case "/v1/transit/decrypt/uet":
+			var body struct {
+				Ciphertext string `json:"ciphertext"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			if !strings.HasPrefix(body.Ciphertext, "vault:v1:") {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"errors":["invalid ciphertext"]}`))
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": map[string]string{"plaintext": strings.TrimPrefix(body.Ciphertext, "vault:v1:")},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+}
+
+func TestVaultTransitKeyProvider(t *testing.T) {
+	server := fakeTransit(t, "s.token")
+	defer server.Close()
+
+	wrappedPath := filepath.Join(t.TempDir(), "master.key.wrapped")
+	p := VaultTransitKeyProvider{
+		Address:        server.URL,
+		Token:          "s.token",
+		KeyName:        "uet",
+		WrappedKeyFile: wrappedPath,
+	}
+
+	if _, err := p.MasterKey(); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("MasterKey() before CreateKey() error = %v, want does not exist", err)
+	}
+
+	key, err := p.CreateKey()
+	if err != nil {
+		t.Fatalf("CreateKey() error = %v", err)
+	}
+	if !bytes.Equal(key, bytes.Repeat([]byte{7}, 32)) {
+		t.Error("CreateKey() did not return the transit plaintext")
+	}
+
+	wrapped, _ := os.ReadFile(wrappedPath)
+	if !strings.HasPrefix(string(wrapped), "vault:v1:") {
+		t.Errorf("Wrapped key file = %q, want the transit ciphertext", wrapped)
+	}
+
+	got, err := p.MasterKey()
+	if err != nil {
+		t.Fatalf("MasterKey() error = %v", err)
+	}
+	if !bytes.Equal(got, key) {
+		t.Error("MasterKey() does not match the created key")
+	}
+
+	p.Token = "wrong"
+	if _, err := p.MasterKey(); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("MasterKey() with a bad token error = %v, want permission denied", err)
+	}
+}
+
+func TestAgeKeyProvider(t *testing.T) {
+	dir := t.TempDir()
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("GenerateX25519Identity() error = %v", err)
+	}
+	identityPath := filepath.Join(dir, "identity.txt")
+	identityFile := "# created: test\n# public key: " + identity.Recipient().String() + "\n" + identity.String() + "\n"
+	if err := os.WriteFile(identityPath, []byte(identityFile), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	p := AgeKeyProvider{
+		KeyFile:      filepath.Join(dir, "master.key.age"),
+		IdentityFile: identityPath,
+		Recipients:   []string{identity.Recipient().String()},
+	}
+
+	if _, err := p.MasterKey(); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("MasterKey() before CreateKey() error = %v, want does not exist", err)
+	}
+
+	key, err := p.CreateKey()
+	if err != nil {
+		t.Fatalf("CreateKey() error = %v", err)
+	}
+	got, err := p.MasterKey()
+	if err != nil {
+		t.Fatalf("MasterKey() error = %v", err)
+	}
+	if !bytes.Equal(got, key) {
+		t.Error("MasterKey() does not match the created key")
+	}
+	if _, err := p.CreateKey(); err == nil {
+		t.Error("CreateKey() should refuse to overwrite an existing key")
+	}
+
+	// An identity the key was not encrypted to cannot open it
+	other, _ := age.GenerateX25519Identity()
+	otherPath := filepath.Join(dir, "other.txt")
+	if err := os.WriteFile(otherPath, []byte(other.String()+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	p.IdentityFile = otherPath
+	if _, err := p.MasterKey(); err == nil {
+		t.Error("MasterKey() with a non-matching identity should fail")
+	}
+
+	p.KeyFile = filepath.Join(dir, "invalid.key.age")
+	p.Recipients = []string{"age1invalid"}
+	if _, err := p.CreateKey(); err == nil {
+		t.Error("CreateKey() should reject an invalid recipient")
+	}
+}
@@ -0,0 +1,329 @@
+// Package drift compares the applications in config.yaml with the integrations they
+// point to in the Duo tenant and pushes local settings back to Duo
+package drift
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"user_experience_toolkit/internal/config"
+	"user_experience_toolkit/internal/duoadmin"
+)
+
+// Status of one application after a check
+const (
+	StatusInSync  = "in_sync"
+	StatusDrift   = "drift"
+	StatusMissing = "missing" // the integration no longer exists in Duo
+	StatusSkipped = "skipped" // not linked to a tenant or no integration key
+	StatusError   = "error"   // the Admin API could not be queried
+)
+
+// Fields that can drift
+const (
+	FieldType         = "type"
+	FieldClientSecret = "client_secret"
+	FieldEntityID     = "entity_id"
+	FieldACSURL       = "acs_url"
+	FieldRedirectURI  = "redirect_uri"
+)
+
+// AdminAPI is the part of duoadmin.Client used to check and push integrations
+type AdminAPI interface {
+	GetIntegration(integrationKey string) (*duoadmin.Integration, error)
+	GetSAMLIntegration(integrationKey string) (*duoadmin.SAMLIntegration, error)
+	GetOIDCIntegration(integrationKey string) (*duoadmin.OIDCIntegration, error)
+	UpdateSSOConfig(integrationKey, section string, changes map[string]interface{}) error
+}
+
+// ClientFactory returns an Admin API client for a tenant
+type ClientFactory func(tenant config.Tenant) AdminAPI
+
+// NewAdminClient creates a duoadmin.Client with the tenant's Admin API credentials
+func NewAdminClient(tenant config.Tenant) AdminAPI {
+	return duoadmin.NewClient(tenant.AdminAPIKey, tenant.AdminAPISecret, tenant.APIHostname)
+}
+
+// Finding is one setting that differs between config.yaml and Duo. Secret values are
+// never included.
+type Finding struct {
+	Field    string   `json:"field"`
+	Local    string   `json:"local,omitempty"`
+	Remote   []string `json:"remote,omitempty"`
+	Pushable bool     `json:"pushable"` // whether Push can fix it by sending the local value
+	Message  string   `json:"message"`
+}
+
+// Report is the drift of one application
+type Report struct {
+	ApplicationID   string    `json:"application_id"`
+	ApplicationName string    `json:"application_name"`
+	Type            string    `json:"type"`
+	TenantID        string    `json:"tenant_id,omitempty"`
+	IntegrationKey  string    `json:"integration_key,omitempty"`
+	Status          string    `json:"status"`
+	Findings        []Finding `json:"findings,omitempty"`
+	Error           string    `json:"error,omitempty"`
+	Pushed          bool      `json:"pushed,omitempty"`
+}
+
+// Pushable reports whether Push would change anything in Duo for this application
+func (r *Report) Pushable() bool {
+	for _, finding := range r.Findings {
+		if finding.Pushable {
+			return true
+		}
+	}
+	return false
+}
+
+// has reports whether field drifted
+func (r *Report) has(field string) bool {
+	for _, finding := range r.Findings {
+		if finding.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// remote returns the Duo values of a drifted field
+func (r *Report) remote(field string) []string {
+	for _, finding := range r.Findings {
+		if finding.Field == field {
+			return slices.Clone(finding.Remote)
+		}
+	}
+	return nil
+}
+
+// Checker compares applications with their Duo integrations
+type Checker struct {
+	Config    *config.Config
+	NewClient ClientFactory
+}
+
+// NewChecker creates a checker that talks to Duo with each tenant's Admin API credentials
+func NewChecker(cfg *config.Config) *Checker {
+	return &Checker{Config: cfg, NewClient: NewAdminClient}
+}
+
+// Check reports the drift of every application, or only of the applications of
+// tenantID when it is not empty
+func (c *Checker) Check(tenantID string) []Report {
+	clients := make(map[string]AdminAPI)
+	reports := []Report{}
+	for _, app := range c.Config.GetAllApplications() {
+		if tenantID != "" && app.TenantID != tenantID {
+			continue
+		}
+		reports = append(reports, c.check(app, clients))
+	}
+	return reports
+}
+
+// CheckApplication reports the drift of one application
+func (c *Checker) CheckApplication(id string) (Report, error) {
+	app, err := c.Config.GetApplication(id)
+	if err != nil {
+		return Report{}, err
+	}
+	return c.check(*app, make(map[string]AdminAPI)), nil
+}
+
+// Push sends the local entity ID, ACS URL and redirect URI of an application to Duo
+// and returns the report after the push
+func (c *Checker) Push(id string) (Report, error) {
+	app, err := c.Config.GetApplication(id)
+	if err != nil {
+		return Report{}, err
+	}
+
+	clients := make(map[string]AdminAPI)
+	report := c.check(*app, clients)
+	if !report.Pushable() {
+		return report, nil
+	}
+
+	// Other ACS URLs and redirect URIs registered in Duo are kept
+	client := clients[app.TenantID]
+	integrationKey := app.ClientID
+	switch app.GetApplicationType() {
+	case "saml":
+		changes := map[string]interface{}{"entity_id": app.EntityID}
+		if report.has(FieldACSURL) {
+			acsURLs := []map[string]interface{}{}
+			for _, acsURL := range report.remote(FieldACSURL) {
+				acsURLs = append(acsURLs, map[string]interface{}{"url": acsURL})
+			}
+			changes["acs_urls"] = append(acsURLs, map[string]interface{}{"url": app.ACSURL})
+		}
+		err = client.UpdateSSOConfig(integrationKey, "saml_config", changes)
+	case "oidc":
+		err = client.UpdateSSOConfig(integrationKey, "oidc_config", map[string]interface{}{
+			"redirect_uris": append(report.remote(FieldRedirectURI), app.RedirectURI),
+		})
+	default:
+		return report, fmt.Errorf("nothing can be pushed for %s applications", app.GetApplicationType())
+	}
+	if err != nil {
+		return report, fmt.Errorf("failed to push %s to Duo: %w", app.Name, err)
+	}
+
+	log.Printf("[Drift] Pushed local settings of application %s to integration %s", app.ID, integrationKey)
+
+	report = c.check(*app, clients)
+	report.Pushed = true
+	return report, nil
+}
+
+// check compares one application with its integration. clients caches one Admin API
+// client per tenant.
+func (c *Checker) check(app config.Application, clients map[string]AdminAPI) Report {
+	report := Report{
+		ApplicationID:   app.ID,
+		ApplicationName: app.Name,
+		Type:            app.GetApplicationType(),
+		TenantID:        app.TenantID,
+		IntegrationKey:  app.ClientID,
+	}
+
+	if app.TenantID == "" || app.ClientID == "" {
+		report.Status = StatusSkipped
+		report.Error = "application is not linked to a tenant integration"
+		return report
+	}
+	tenant, err := c.Config.GetTenant(app.TenantID)
+	if err != nil {
+		report.Status = StatusSkipped
+		report.Error = err.Error()
+		return report
+	}
+
+	client, ok := clients[tenant.ID]
+	if !ok {
+		client = c.NewClient(*tenant)
+		clients[tenant.ID] = client
+	}
+
+	if err := compare(&report, app, client); err != nil {
+		if errors.Is(err, duoadmin.ErrIntegrationNotFound) {
+			report.Status = StatusMissing
+			report.Error = fmt.Sprintf("integration %s does not exist in the Duo tenant", app.ClientID)
+			return report
+		}
+		report.Status = StatusError
+		report.Error = err.Error()
+		return report
+	}
+
+	report.Status = StatusInSync
+	if len(report.Findings) > 0 {
+		report.Status = StatusDrift
+	}
+	return report
+}
+
+// compare adds a finding to report for every setting that differs
+func compare(report *Report, app config.Application, client AdminAPI) error {
+	integration, err := client.GetIntegration(app.ClientID)
+	if err != nil {
+		return err
+	}
+
+	// The registry records the Duo integration type each application type is created as
+	appType := app.GetApplicationType()
+	registered, _ := config.LookupApplicationType(appType)
+	if want := registered.IntegrationType; integration.Type != want {
+		report.Findings = append(report.Findings, Finding{
+			Field:   FieldType,
+			Local:   want,
+			Remote:  []string{integration.Type},
+			Message: fmt.Sprintf("integration is of type %s, expected %s", integration.Type, want),
+		})
+		return nil
+	}
+
+	switch appType {
+	case "websdk", "dmp", "authapi", "websdkv2":
+		if integration.SecretKey != "" && integration.SecretKey != app.ClientSecret {
+			report.Findings = append(report.Findings, secretFinding())
+		}
+
+	case "saml":
+		samlIntegration, err := client.GetSAMLIntegration(app.ClientID)
+		if err != nil {
+			return err
+		}
+		samlConfig := samlIntegration.SSO.SAMLConfig
+		if samlConfig.EntityID != app.EntityID {
+			report.Findings = append(report.Findings, Finding{
+				Field:    FieldEntityID,
+				Local:    app.EntityID,
+				Remote:   []string{samlConfig.EntityID},
+				Pushable: true,
+				Message:  "the SP entity ID in Duo differs from entity_id",
+			})
+		}
+		if acsURLs := samlIntegration.ACSURLs(); !slices.Contains(acsURLs, app.ACSURL) {
+			report.Findings = append(report.Findings, Finding{
+				Field:    FieldACSURL,
+				Local:    app.ACSURL,
+				Remote:   acsURLs,
+				Pushable: true,
+				Message:  "acs_url is not one of the ACS URLs configured in Duo",
+			})
+		}
+
+	case "oidc":
+		oidcIntegration, err := client.GetOIDCIntegration(app.ClientID)
+		if err != nil {
+			return err
+		}
+		redirectURIs := oidcIntegration.SSO.OIDCConfig.RedirectURIs
+		if !slices.Contains(redirectURIs, app.RedirectURI) {
+			report.Findings = append(report.Findings, Finding{
+				Field:    FieldRedirectURI,
+				Local:    app.RedirectURI,
+				Remote:   redirectURIs,
+				Pushable: true,
+				Message:  "redirect_uri is not registered in Duo",
+			})
+		}
+		if secret := oidcIntegration.SSO.IDPMetadata.ClientSecret; secret != "" && secret != app.ClientSecret {
+			report.Findings = append(report.Findings, secretFinding())
+		}
+	}
+
+	return nil
+}
+
+// secretFinding reports a secret that was rotated in the Duo Admin Panel. It cannot be
+// pushed because Duo generates the secret.
+func secretFinding() Finding {
+	return Finding{
+		Field:   FieldClientSecret,
+		Message: "the secret in Duo was rotated; copy the new secret into client_secret",
+	}
+}
+
+// Summary counts the reports per status, e.g. "2 in sync, 1 drift"
+func Summary(reports []Report) string {
+	counts := make(map[string]int)
+	for _, report := range reports {
+		counts[report.Status]++
+	}
+
+	var parts []string
+	for _, status := range []string{StatusInSync, StatusDrift, StatusMissing, StatusSkipped, StatusError} {
+		if counts[status] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[status], strings.ReplaceAll(status, "_", " ")))
+		}
+	}
+	if len(parts) == 0 {
+		return "no applications"
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,249 @@
+package drift
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"user_experience_toolkit/internal/config"
+	"user_experience_toolkit/internal/duoadmin"
+)
+
+// fakeAdminAPI serves integrations from memory and records SSO updates
+type fakeAdminAPI struct {
+	integrations map[string]duoadmin.Integration
+	sso          map[string]string // integration key to v3 JSON
+	updates      map[string]map[string]interface{}
+}
+
+func (f *fakeAdminAPI) GetIntegration(key string) (*duoadmin.Integration, error) {
+	integration, ok := f.integrations[key]
+	if !ok {
+		return nil, duoadmin.ErrIntegrationNotFound
+	}
+	return &integration, nil
+}
+
+func (f *fakeAdminAPI) GetSAMLIntegration(key string) (*duoadmin.SAMLIntegration, error) {
+	var integration duoadmin.SAMLIntegration
+	if err := json.Unmarshal([]byte(f.sso[key]), &integration); err != nil {
+		return nil, err
+	}
+	return &integration, nil
+}
+
+func (f *fakeAdminAPI) GetOIDCIntegration(key string) (*duoadmin.OIDCIntegration, error) {
+	var integration duoadmin.OIDCIntegration
+	if err := json.Unmarshal([]byte(f.sso[key]), &integration); err != nil {
+		return nil, err
+	}
+	return &integration, nil
+}
+
+func (f *fakeAdminAPI) UpdateSSOConfig(key, section string, changes map[string]interface{}) error {
+	f.updates[key] = changes
+	// Apply the change so the check after a push sees the new values
+	switch section {
+	case "oidc_config":
+		uris, _ := json.Marshal(changes["redirect_uris"])
+		f.sso[key] = `{"sso": {"oidc_config": {"redirect_uris": ` + string(uris) + `}}}`
+	case "saml_config":
+		acs, _ := json.Marshal(changes["acs_urls"])
+		f.sso[key] = `{"sso": {"saml_config": {"entity_id": "` + changes["entity_id"].(string) + `", "acs_urls": ` + string(acs) + `}}}`
+	}
+	return nil
+}
+
+func newTestChecker(t *testing.T) (*Checker, *fakeAdminAPI) {
+	t.Helper()
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+tenants:
+  - id: "tenant1"
+    name: "Test Tenant"
+    admin_api_key: "key"
+    admin_api_secret: "secret"
+    api_hostname: "api-test.duosecurity.com"
+applications:
+  - id: "websdk-ok"
+    tenant_id: "tenant1"
+    name: "WebSDK"
+    type: "websdk"
+    client_id: "DIWEBSDK"
+    client_secret: "websdk_secret"
+    api_hostname: "api-test.duosecurity.com"
+  - id: "websdk-rotated"
+    tenant_id: "tenant1"
+    name: "Rotated"
+    type: "websdk"
+    client_id: "DIROTATED"
+    client_secret: "old_secret"
+    api_hostname: "api-test.duosecurity.com"
+  - id: "dmp-missing"
+    tenant_id: "tenant1"
+    name: "Gone"
+    type: "dmp"
+    client_id: "DIGONE"
+    client_secret: "secret"
+    api_hostname: "api-test.duosecurity.com"
+  - id: "saml-drift"
+    tenant_id: "tenant1"
+    name: "SAML"
+    type: "saml"
+    client_id: "DISAML"
+    api_hostname: "api-test.duosecurity.com"
+    entity_id: "https://uet.example.com/app/saml-drift/saml"
+    acs_url: "https://uet.example.com/app/saml-drift/saml/acs"
+  - id: "oidc-drift"
+    tenant_id: "tenant1"
+    name: "OIDC"
+    type: "oidc"
+    client_id: "DIOIDC"
+    client_secret: "oidc_secret"
+    api_hostname: "api-test.duosecurity.com"
+    redirect_uri: "https://uet.example.com/app/oidc-drift/oidc/callback"
+  - id: "manual"
+    name: "Manual"
+    type: "websdk"
+    client_id: "DIMANUAL"
+    client_secret: "secret"
+    api_hostname: "api-test.duosecurity.com"
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	fake := &fakeAdminAPI{
+		integrations: map[string]duoadmin.Integration{
+			"DIWEBSDK":  {IntegrationKey: "DIWEBSDK", Type: "websdk", SecretKey: "websdk_secret"},
+			"DIROTATED": {IntegrationKey: "DIROTATED", Type: "websdk", SecretKey: "new_secret"},
+			"DISAML":    {IntegrationKey: "DISAML", Type: "sso-generic"},
+			"DIOIDC":    {IntegrationKey: "DIOIDC", Type: "sso-oidc-generic"},
+		},
+		sso: map[string]string{
+			"DISAML": `{"sso": {"saml_config": {"entity_id": "https://uet.example.com/app/saml-drift/saml", "acs_urls": [{"url": "https://old.example.com/acs"}]}}}`,
+			"DIOIDC": `{"sso": {"idp_metadata": {"client_secret": "oidc_secret"}, "oidc_config": {"redirect_uris": ["https://other.example.com/callback"]}}}`,
+		},
+		updates: make(map[string]map[string]interface{}),
+	}
+
+	checker := &Checker{
+		Config:    cfg,
+		NewClient: func(config.Tenant) AdminAPI { return fake },
+	}
+	return checker, fake
+}
+
+func TestCheck(t *testing.T) {
+	checker, _ := newTestChecker(t)
+
+	reports := checker.Check("")
+	byID := make(map[string]Report)
+	for _, report := range reports {
+		byID[report.ApplicationID] = report
+	}
+
+	tests := []struct {
+		id         string
+		wantStatus string
+		wantFields []string
+	}{
+		{"websdk-ok", StatusInSync, nil},
+		{"websdk-rotated", StatusDrift, []string{FieldClientSecret}},
+		{"dmp-missing", StatusMissing, nil},
+		{"saml-drift", StatusDrift, []string{FieldACSURL}},
+		{"oidc-drift", StatusDrift, []string{FieldRedirectURI}},
+		{"manual", StatusSkipped, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			report, ok := byID[tt.id]
+			if !ok {
+				t.Fatalf("no report for %s", tt.id)
+			}
+			if report.Status != tt.wantStatus {
+				t.Errorf("Status = %s, want %s (%s)", report.Status, tt.wantStatus, report.Error)
+			}
+			var fields []string
+			for _, finding := range report.Findings {
+				fields = append(fields, finding.Field)
+			}
+			if !slices.Equal(fields, tt.wantFields) {
+				t.Errorf("Findings = %v, want %v", fields, tt.wantFields)
+			}
+		})
+	}
+
+	if got := len(checker.Check("tenant1")); got != 5 {
+		t.Errorf("Check(tenant1) returned %d reports, want 5", got)
+	}
+}
+
+func TestCheckNeverReportsSecrets(t *testing.T) {
+	checker, _ := newTestChecker(t)
+
+	report, err := checker.CheckApplication("websdk-rotated")
+	if err != nil {
+		t.Fatalf("CheckApplication() error = %v", err)
+	}
+	data, _ := json.Marshal(report)
+	for _, secret := range []string{"old_secret", "new_secret"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("report contains secret %q: %s", secret, data)
+		}
+	}
+	if report.Pushable() {
+		t.Error("a rotated secret should not be pushable")
+	}
+}
+
+func TestPush(t *testing.T) {
+	checker, fake := newTestChecker(t)
+
+	report, err := checker.Push("oidc-drift")
+	if err != nil {
+		t.Fatalf("Push() error = %v", err)
+	}
+	if !report.Pushed || report.Status != StatusInSync {
+		t.Errorf("Push() report = %+v, want pushed and in sync", report)
+	}
+	uris, _ := fake.updates["DIOIDC"]["redirect_uris"].([]string)
+	want := []string{"https://other.example.com/callback", "https://uet.example.com/app/oidc-drift/oidc/callback"}
+	if !slices.Equal(uris, want) {
+		t.Errorf("pushed redirect_uris = %v, want %v", uris, want)
+	}
+
+	report, err = checker.Push("saml-drift")
+	if err != nil {
+		t.Fatalf("Push() error = %v", err)
+	}
+	if report.Status != StatusInSync {
+		t.Errorf("Push() SAML status = %s, findings %+v", report.Status, report.Findings)
+	}
+
+	report, err = checker.Push("websdk-rotated")
+	if err != nil {
+		t.Fatalf("Push() error = %v", err)
+	}
+	if report.Pushed {
+		t.Error("Push() should not push a rotated secret")
+	}
+}
+
+func TestSummary(t *testing.T) {
+	reports := []Report{{Status: StatusInSync}, {Status: StatusInSync}, {Status: StatusDrift}}
+	if got := Summary(reports); got != "2 in sync, 1 drift" {
+		t.Errorf("Summary() = %q", got)
+	}
+	if got := Summary(nil); got != "no applications" {
+		t.Errorf("Summary(nil) = %q", got)
+	}
+}
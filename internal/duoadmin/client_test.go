@@ -1,6 +1,7 @@
 package duoadmin
 
 import (
+	"encoding/json"
 	"testing"
 )
 
@@ -40,6 +41,8 @@ func TestNewClient_MultipleInstances(t *testing.T) {
 // - CreateIntegration
 // - CreateSAMLIntegration
 // - CreateOIDCIntegration
+// - DeleteIntegration
+// - ListIntegrations, GetIntegration, GetSAMLIntegration, GetOIDCIntegration
 //
 // These are integration tests and would require:
 // 1. Mocking the HTTP client/server
@@ -181,3 +184,87 @@ func TestOIDCIntegrationStruct(t *testing.T) {
 		t.Errorf("OIDCIntegration ClientID = %v, want test_client_id", oidcIntegration.SSO.IDPMetadata.ClientID)
 	}
 }
+
+func TestDeleteIntegrationRequiresKey(t *testing.T) {
+	client := NewClient("key", "secret", "api-test.duosecurity.com")
+	if err := client.DeleteIntegration(""); err == nil {
+		t.Error("DeleteIntegration() should reject an empty integration key")
+	}
+}
+
+func TestSAMLIntegrationACSURLs(t *testing.T) {
+	var integration SAMLIntegration
+	body := `{"sso": {"saml_config": {"acs_urls": [{"url": "https://sp.example.com/acs", "binding": null}, "https://sp.example.com/acs2"]}}}`
+	if err := json.Unmarshal([]byte(body), &integration); err != nil {
+		t.Fatalf("failed to parse integration: %v", err)
+	}
+
+	urls := integration.ACSURLs()
+	if len(urls) != 2 || urls[0] != "https://sp.example.com/acs" || urls[1] != "https://sp.example.com/acs2" {
+		t.Errorf("ACSURLs() = %v", urls)
+	}
+}
+
+func TestApplyOIDCUpdate(t *testing.T) {
+	var oidcConfig map[string]interface{}
+	body := `{
+		"redirect_uris": ["https://old.example.com/callback"],
+		"scopes": [{"name": "openid", "claims": []}, {"name": "email", "claims": ["email", "custom"]}, {"name": "phone", "claims": ["phone_number"]}],
+		"grant_types": {
+			"authorization_code": {"access_token_lifespan": 3600, "allow_pkce_only": false, "refresh_token": {"refresh_token_chain_lifespan": 100, "refresh_token_single_lifespan": 10}},
+			"client_credentials": {"access_token_lifespan": 60}
+		}
+	}`
+	if err := json.Unmarshal([]byte(body), &oidcConfig); err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+
+	applyOIDCUpdate(oidcConfig, UpdateOIDCIntegrationParams{
+		RedirectURIs:        []string{"https://new.example.com/callback"},
+		Scopes:              []string{"email", "profile", "email"},
+		AccessTokenLifespan: 600,
+		AllowPKCEOnly:       true,
+	})
+
+	var got struct {
+		RedirectURIs []string `json:"redirect_uris"`
+		Scopes       []struct {
+			Name   string   `json:"name"`
+			Claims []string `json:"claims"`
+		} `json:"scopes"`
+		GrantTypes map[string]map[string]interface{} `json:"grant_types"`
+	}
+	data, _ := json.Marshal(oidcConfig)
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("failed to parse updated config: %v", err)
+	}
+
+	if len(got.RedirectURIs) != 1 || got.RedirectURIs[0] != "https://new.example.com/callback" {
+		t.Errorf("redirect_uris = %v", got.RedirectURIs)
+	}
+
+	var names []string
+	for _, scope := range got.Scopes {
+		names = append(names, scope.Name)
+	}
+	if len(names) != 3 || names[0] != "openid" || names[1] != "email" || names[2] != "profile" {
+		t.Errorf("scopes = %v, want [openid email profile]", names)
+	}
+	if len(got.Scopes) == 3 && len(got.Scopes[1].Claims) != 2 {
+		t.Errorf("email claims = %v, want the claims configured in Duo", got.Scopes[1].Claims)
+	}
+	if len(got.Scopes) == 3 && len(got.Scopes[2].Claims) == 0 {
+		t.Error("profile should get the standard claims")
+	}
+
+	authCode := got.GrantTypes["authorization_code"]
+	if authCode["access_token_lifespan"] != float64(600) || authCode["allow_pkce_only"] != true {
+		t.Errorf("authorization_code = %v", authCode)
+	}
+	if _, ok := authCode["refresh_token"]; ok {
+		t.Error("refresh_token should be removed when refresh tokens are disabled")
+	}
+	if _, ok := got.GrantTypes["client_credentials"]; !ok {
+		t.Error("other grant types should be kept")
+	}
+}
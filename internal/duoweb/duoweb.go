@@ -0,0 +1,134 @@
+// Package duoweb signs requests for and verifies responses from the legacy Duo Web SDK v2
+// iframe (Duo-Web-v2.js)
+package duoweb
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Signature prefixes and lifetimes used by the Web SDK v2 protocol
+const (
+	duoPrefix  = "TX"
+	appPrefix  = "APP"
+	authPrefix = "AUTH"
+
+	duoExpire = 300  // seconds the iframe request is valid
+	appExpire = 3600 // seconds the application signature is valid
+)
+
+// Key lengths required by the protocol
+const (
+	IntegrationKeyLength    = 20
+	SecretKeyLength         = 40
+	MinApplicationKeyLength = 40
+)
+
+// ErrInvalidResponse is returned for a sig_response that is malformed, expired,
+// signed with other keys or issued for another integration
+var ErrInvalidResponse = errors.New("invalid Duo Web SDK v2 response")
+
+// now is replaced in tests
+var now = time.Now
+
+// SignRequest returns the sig_request for username to pass to the iframe as
+// data-sig-request. akey is a secret of the application, not known to Duo.
+func SignRequest(ikey, skey, akey, username string) (string, error) {
+	if username == "" {
+		return "", fmt.Errorf("username is required")
+	}
+	if strings.Contains(username, "|") {
+		return "", fmt.Errorf("username must not contain |")
+	}
+	if len(ikey) != IntegrationKeyLength {
+		return "", fmt.Errorf("integration key must be %d characters", IntegrationKeyLength)
+	}
+	if len(skey) != SecretKeyLength {
+		return "", fmt.Errorf("secret key must be %d characters", SecretKeyLength)
+	}
+	if len(akey) < MinApplicationKeyLength {
+		return "", fmt.Errorf("application key must be at least %d characters", MinApplicationKeyLength)
+	}
+
+	duoSig := signValues(skey, username, ikey, duoPrefix, duoExpire)
+	appSig := signValues(akey, username, ikey, appPrefix, appExpire)
+	return duoSig + ":" + appSig, nil
+}
+
+// VerifyResponse checks the sig_response posted back by the iframe and returns the
+// authenticated username
+func VerifyResponse(ikey, skey, akey, sigResponse string) (string, error) {
+	authSig, appSig, ok := strings.Cut(sigResponse, ":")
+	if !ok {
+		return "", ErrInvalidResponse
+	}
+
+	authUser, err := parseValues(skey, authSig, authPrefix, ikey)
+	if err != nil {
+		return "", err
+	}
+	appUser, err := parseValues(akey, appSig, appPrefix, ikey)
+	if err != nil {
+		return "", err
+	}
+	if authUser != appUser {
+		return "", ErrInvalidResponse
+	}
+	return authUser, nil
+}
+
+// signValues builds prefix|base64(username|ikey|expiry)|hmac
+func signValues(key, username, ikey, prefix string, expire int64) string {
+	expiry := now().Unix() + expire
+	values := fmt.Sprintf("%s|%s|%d", username, ikey, expiry)
+	cookie := prefix + "|" + base64.StdEncoding.EncodeToString([]byte(values))
+	return cookie + "|" + hmacSHA1(key, cookie)
+}
+
+// parseValues verifies one signed value and returns its username
+func parseValues(key, value, prefix, ikey string) (string, error) {
+	parts := strings.Split(value, "|")
+	if len(parts) != 3 {
+		return "", ErrInvalidResponse
+	}
+	valuePrefix, encoded, signature := parts[0], parts[1], parts[2]
+
+	if !hmac.Equal([]byte(hmacSHA1(key, valuePrefix+"|"+encoded)), []byte(signature)) {
+		return "", ErrInvalidResponse
+	}
+	if valuePrefix != prefix {
+		return "", ErrInvalidResponse
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", ErrInvalidResponse
+	}
+	fields := strings.Split(string(decoded), "|")
+	if len(fields) != 3 {
+		return "", ErrInvalidResponse
+	}
+	username, valueIkey, expiry := fields[0], fields[1], fields[2]
+
+	if valueIkey != ikey {
+		return "", ErrInvalidResponse
+	}
+	expires, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || now().Unix() >= expires {
+		return "", fmt.Errorf("%w: expired", ErrInvalidResponse)
+	}
+	return username, nil
+}
+
+func hmacSHA1(key, message string) string {
+	mac := hmac.New(sha1.New, []byte(key))
+	mac.Write([]byte(message))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,102 @@
+package duoweb
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testIkey = "DIXXXXXXXXXXXXXXXXXX"
+	testSkey = "deadbeefdeadbeefdeadbeefdeadbeefdeadbeef"
+	testAkey = "useacustomerprovidedapplicationsecretkey"
+)
+
+// duoResponse signs an AUTH value the way Duo does after a successful authentication
+func duoResponse(t *testing.T, username, sigRequest string) string {
+	t.Helper()
+	_, appSig, ok := strings.Cut(sigRequest, ":")
+	if !ok {
+		t.Fatalf("sig_request %q has no application signature", sigRequest)
+	}
+	return signValues(testSkey, username, testIkey, authPrefix, duoExpire) + ":" + appSig
+}
+
+func TestSignRequest(t *testing.T) {
+	sigRequest, err := SignRequest(testIkey, testSkey, testAkey, "alice")
+	if err != nil {
+		t.Fatalf("SignRequest() error = %v", err)
+	}
+	duoSig, appSig, ok := strings.Cut(sigRequest, ":")
+	if !ok || !strings.HasPrefix(duoSig, "TX|") || !strings.HasPrefix(appSig, "APP|") {
+		t.Errorf("SignRequest() = %q, want TX|...:APP|...", sigRequest)
+	}
+
+	tests := []struct {
+		name                       string
+		ikey, skey, akey, username string
+	}{
+		{"empty username", testIkey, testSkey, testAkey, ""},
+		{"username with separator", testIkey, testSkey, testAkey, "al|ice"},
+		{"short integration key", "DIXXX", testSkey, testAkey, "alice"},
+		{"short secret key", testIkey, "secret", testAkey, "alice"},
+		{"short application key", testIkey, testSkey, "short", "alice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := SignRequest(tt.ikey, tt.skey, tt.akey, tt.username); err == nil {
+				t.Error("SignRequest() should fail")
+			}
+		})
+	}
+}
+
+func TestVerifyResponse(t *testing.T) {
+	sigRequest, err := SignRequest(testIkey, testSkey, testAkey, "alice")
+	if err != nil {
+		t.Fatalf("SignRequest() error = %v", err)
+	}
+
+	username, err := VerifyResponse(testIkey, testSkey, testAkey, duoResponse(t, "alice", sigRequest))
+	if err != nil {
+		t.Fatalf("VerifyResponse() error = %v", err)
+	}
+	if username != "alice" {
+		t.Errorf("VerifyResponse() = %q, want alice", username)
+	}
+
+	tests := []struct {
+		name     string
+		response string
+		akey     string
+	}{
+		{"other user", duoResponse(t, "mallory", sigRequest), testAkey},
+		{"request signature echoed back", sigRequest, testAkey},
+		{"other application key", duoResponse(t, "alice", sigRequest), strings.Repeat("a", 40)},
+		{"tampered signature", duoResponse(t, "alice", sigRequest) + "00", testAkey},
+		{"malformed", "garbage", testAkey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := VerifyResponse(testIkey, testSkey, tt.akey, tt.response); !errors.Is(err, ErrInvalidResponse) {
+				t.Errorf("VerifyResponse() error = %v, want ErrInvalidResponse", err)
+			}
+		})
+	}
+}
+
+func TestVerifyResponseExpired(t *testing.T) {
+	sigRequest, err := SignRequest(testIkey, testSkey, testAkey, "alice")
+	if err != nil {
+		t.Fatalf("SignRequest() error = %v", err)
+	}
+	response := duoResponse(t, "alice", sigRequest)
+
+	defer func() { now = time.Now }()
+	now = func() time.Time { return time.Now().Add(time.Hour) }
+
+	if _, err := VerifyResponse(testIkey, testSkey, testAkey, response); !errors.Is(err, ErrInvalidResponse) {
+		t.Errorf("VerifyResponse() error = %v, want ErrInvalidResponse for an expired response", err)
+	}
+}
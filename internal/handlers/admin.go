@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"user_experience_toolkit/internal/config"
+
+	"github.com/duosecurity/duo_universal_golang/duouniversal"
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/csrf"
+	"github.com/gofiber/fiber/v3/middleware/session"
+)
+
+// Admin session keys
+const (
+	adminUserKey        = "admin_user"
+	adminPendingUserKey = "admin_pending_user"
+	adminDuoStateKey    = "admin_duo_state"
+	adminNextKey        = "admin_next"
+)
+
+// adminTokenAuthKey marks requests authenticated with the API bearer token
+const adminTokenAuthKey = "admin_token_auth"
+
+// AdminHandler authenticates access to the configuration UI and API
+type AdminHandler struct {
+	Config *config.Config
+	Admin  config.AdminConfig
+	Store  *session.Store
+}
+
+// NewAdminHandler creates an admin handler. store must be dedicated to admin sessions so
+// that signing in or out of a demo application does not affect the admin login.
+func NewAdminHandler(cfg *config.Config, admin config.AdminConfig, store *session.Store) *AdminHandler {
+	return &AdminHandler{
+		Config: cfg,
+		Admin:  admin,
+		Store:  store,
+	}
+}
+
+// RequireAdmin only lets requests through that carry a valid bearer token or belong
+// to a signed in admin session. API requests are rejected with 401, pages redirect to
+// the login form. Everything is allowed while admin authentication is not configured.
+func (h *AdminHandler) RequireAdmin(c fiber.Ctx) error {
+	if !h.Admin.Enabled() {
+		return c.Next()
+	}
+
+	if token, ok := BearerToken(c); ok {
+		if h.Admin.VerifyToken(token) {
+			c.Locals(adminTokenAuthKey, true)
+			return c.Next()
+		}
+		log.Printf("[AdminHandler] Rejected invalid API token for %s %s from %s", c.Method(), c.Path(), c.IP())
+		c.Set(fiber.HeaderWWWAuthenticate, `Bearer realm="uet", error="invalid_token"`)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid API token",
+		})
+	}
+
+	if sess, err := h.Store.Get(c); err == nil {
+		if user, _ := sess.Get(adminUserKey).(string); user != "" {
+			c.Locals(adminUserKey, user)
+			return c.Next()
+		}
+	}
+
+	if strings.HasPrefix(c.Path(), "/api/") {
+		c.Set(fiber.HeaderWWWAuthenticate, `Bearer realm="uet"`)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	return c.Redirect().To("/admin/login?next=" + url.QueryEscape(c.OriginalURL()))
+}
+
+// ShowLogin renders the admin login form
+func (h *AdminHandler) ShowLogin(c fiber.Ctx) error {
+	if !h.Admin.Enabled() {
+		return c.Redirect().To("/configure")
+	}
+	return h.renderLogin(c, "")
+}
+
+// ProcessLogin verifies the admin credentials and either signs the admin in or,
+// when Duo protection is configured, sends them to the Universal Prompt
+func (h *AdminHandler) ProcessLogin(c fiber.Ctx) error {
+	if len(h.Admin.Users) == 0 {
+		return c.Redirect().To("/configure")
+	}
+
+	username := strings.TrimSpace(c.FormValue("username"))
+	password := c.FormValue("password")
+
+	if username == "" || password == "" || !h.Admin.Authenticate(username, password) {
+		log.Printf("[AdminHandler] Failed admin login for %q from %s", username, c.IP())
+		return h.renderLogin(c, "Incorrect username or password")
+	}
+
+	sess, err := h.Store.Get(c)
+	if err != nil {
+		log.Printf("[AdminHandler] Failed to get session: %v", err)
+		return h.renderLogin(c, "Session error")
+	}
+
+	next := safeNextPath(c.FormValue("next"))
+
+	if !h.Admin.DuoEnabled() {
+		return h.completeLogin(c, sess, username, next)
+	}
+
+	duoClient, err := h.duoClient(c)
+	if err != nil {
+		log.Printf("[AdminHandler] %v", err)
+		return h.renderLogin(c, "Duo is not configured properly for admin logins")
+	}
+
+	if _, err := duoClient.HealthCheck(); err != nil {
+		log.Printf("[AdminHandler] Duo health check failed: %v", err)
+		return h.renderLogin(c, "2FA Unavailable. Confirm the admin Duo application is configured correctly")
+	}
+
+	state, err := duoClient.GenerateState()
+	if err != nil {
+		log.Printf("[AdminHandler] Failed to generate state: %v", err)
+		return h.renderLogin(c, "Failed to generate authentication state")
+	}
+
+	sess.Set(adminPendingUserKey, username)
+	sess.Set(adminDuoStateKey, state)
+	sess.Set(adminNextKey, next)
+	if err := sess.Save(); err != nil {
+		log.Printf("[AdminHandler] Failed to save session: %v", err)
+		return h.renderLogin(c, "Failed to save session")
+	}
+
+	authURL, err := duoClient.CreateAuthURL(username, state)
+	if err != nil {
+		log.Printf("[AdminHandler] Failed to generate auth URL: %v", err)
+		return h.renderLogin(c, "Failed to generate authentication URL")
+	}
+
+	return c.Redirect().To(authURL)
+}
+
+// DuoCallback completes an admin login after the Duo second factor
+func (h *AdminHandler) DuoCallback(c fiber.Ctx) error {
+	if errMsg := c.Query("error"); errMsg != "" {
+		log.Printf("[AdminHandler] Duo auth error: %s - %s", errMsg, c.Query("error_description"))
+		return h.renderLogin(c, fmt.Sprintf("Duo error: %s", errMsg))
+	}
+
+	code := c.Query("duo_code")
+	state := c.Query("state")
+	if code == "" || state == "" {
+		return h.renderLogin(c, "Missing authorization code or state")
+	}
+
+	sess, err := h.Store.Get(c)
+	if err != nil {
+		log.Printf("[AdminHandler] Failed to get session: %v", err)
+		return h.renderLogin(c, "Session error")
+	}
+
+	savedState, _ := sess.Get(adminDuoStateKey).(string)
+	username, _ := sess.Get(adminPendingUserKey).(string)
+	next, _ := sess.Get(adminNextKey).(string)
+	sess.Delete(adminDuoStateKey)
+	sess.Delete(adminPendingUserKey)
+	sess.Delete(adminNextKey)
+
+	if savedState == "" || username == "" {
+		sess.Save()
+		return h.renderLogin(c, "No pending admin login, please sign in again")
+	}
+	if state != savedState {
+		sess.Save()
+		return h.renderLogin(c, "Duo state does not match saved state")
+	}
+
+	duoClient, err := h.duoClient(c)
+	if err != nil {
+		log.Printf("[AdminHandler] %v", err)
+		sess.Save()
+		return h.renderLogin(c, "Duo is not configured properly for admin logins")
+	}
+
+	if _, err := duoClient.ExchangeAuthorizationCodeFor2faResult(code, username); err != nil {
+		log.Printf("[AdminHandler] Failed to exchange Duo code for %q: %v", username, err)
+		sess.Save()
+		return h.renderLogin(c, "Duo authentication failed")
+	}
+
+	return h.completeLogin(c, sess, username, safeNextPath(next))
+}
+
+// Logout ends the admin session. It is a POST with the CSRF token, so another site
+// cannot sign the admin out.
+func (h *AdminHandler) Logout(c fiber.Ctx) error {
+	sess, err := h.Store.Get(c)
+	if err == nil {
+		if user, _ := sess.Get(adminUserKey).(string); user != "" {
+			log.Printf("[AdminHandler] Admin %q signed out", user)
+		}
+		if err := sess.Destroy(); err != nil {
+			log.Printf("[AdminHandler] Failed to destroy session: %v", err)
+		}
+	}
+
+	if !h.Admin.Enabled() {
+		return c.Redirect().To("/")
+	}
+	return c.Redirect().To("/admin/login")
+}
+
+// completeLogin stores the admin user in a fresh session ID and redirects to next
+func (h *AdminHandler) completeLogin(c fiber.Ctx, sess *session.Session, username, next string) error {
+	// Issue a new session ID so a session fixed before login cannot be reused
+	if err := sess.Regenerate(); err != nil {
+		log.Printf("[AdminHandler] Failed to regenerate session: %v", err)
+		return h.renderLogin(c, "Session error")
+	}
+	sess.Set(adminUserKey, username)
+	if err := sess.Save(); err != nil {
+		log.Printf("[AdminHandler] Failed to save session: %v", err)
+		return h.renderLogin(c, "Failed to save session")
+	}
+
+	log.Printf("[AdminHandler] Admin %q signed in from %s", username, c.IP())
+	return c.Redirect().To(next)
+}
+
+// duoClient creates the Universal Prompt client for the configured admin Duo application
+func (h *AdminHandler) duoClient(c fiber.Ctx) (*duouniversal.Client, error) {
+	app, err := h.Config.GetApplication(h.Admin.DuoAppID)
+	if err != nil {
+		return nil, fmt.Errorf("admin Duo application %s not found", h.Admin.DuoAppID)
+	}
+	if app.GetApplicationType() != "websdk" {
+		return nil, fmt.Errorf("admin Duo application %s is %s, not websdk", app.ID, app.GetApplicationType())
+	}
+
+	client, err := duouniversal.NewClient(app.ClientID, app.ClientSecret, app.APIHostname, c.BaseURL()+"/admin/duo/callback")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create admin Duo client: %v", err)
+	}
+	return client, nil
+}
+
+func (h *AdminHandler) renderLogin(c fiber.Ctx, message string) error {
+	next := c.Query("next")
+	if c.Method() == fiber.MethodPost {
+		next = c.FormValue("next")
+	}
+
+	duoAppName := ""
+	if h.Admin.DuoEnabled() {
+		if app, err := h.Config.GetApplication(h.Admin.DuoAppID); err == nil {
+			duoAppName = app.Name
+		}
+	}
+
+	if message != "" {
+		c.Status(fiber.StatusUnauthorized)
+	}
+	return c.Render("admin_login", fiber.Map{
+		"Message":    message,
+		"Next":       safeNextPath(next),
+		"CSRFToken":  csrf.TokenFromContext(c),
+		"HasUsers":   len(h.Admin.Users) > 0,
+		"DuoAppName": duoAppName,
+	})
+}
+
+// adminIdentity describes who made a request for the audit log
+func adminIdentity(c fiber.Ctx) string {
+	if user, _ := c.Locals(adminUserKey).(string); user != "" {
+		return fmt.Sprintf("admin %q", user)
+	}
+	if tokenAuth, _ := c.Locals(adminTokenAuthKey).(bool); tokenAuth {
+		return "API token"
+	}
+	return "unauthenticated client"
+}
+
+// BearerToken returns the token of an "Authorization: Bearer" header
+func BearerToken(c fiber.Ctx) (string, bool) {
+	scheme, token, ok := strings.Cut(c.Get(fiber.HeaderAuthorization), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// safeNextPath only allows redirects to local paths after login
+func safeNextPath(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/configure"
+	}
+	return next
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+	"user_experience_toolkit/internal/config"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/session"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestRequireAdmin(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("api-token"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("failed to hash token: %v", err)
+	}
+
+	newApp := func(admin config.AdminConfig) *fiber.App {
+		handler := NewAdminHandler(&config.Config{}, admin, session.NewStore())
+		app := fiber.New()
+		app.Get("/configure", handler.RequireAdmin, func(c fiber.Ctx) error { return c.SendString("ok") })
+		app.Get("/api/config/tenants", handler.RequireAdmin, func(c fiber.Ctx) error { return c.SendString("ok") })
+		return app
+	}
+
+	protected := newApp(config.AdminConfig{APITokenHash: string(hash)})
+
+	tests := []struct {
+		name       string
+		app        *fiber.App
+		path       string
+		token      string
+		wantStatus int
+		wantLoc    string
+	}{
+		{"auth not configured", newApp(config.AdminConfig{}), "/api/config/tenants", "", fiber.StatusOK, ""},
+		{"valid token", protected, "/api/config/tenants", "api-token", fiber.StatusOK, ""},
+		{"invalid token", protected, "/api/config/tenants", "wrong", fiber.StatusUnauthorized, ""},
+		{"api without credentials", protected, "/api/config/tenants", "", fiber.StatusUnauthorized, ""},
+		{"page without credentials", protected, "/configure", "", fiber.StatusSeeOther, "/admin/login?next=%2Fconfigure"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(fiber.MethodGet, tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			resp, err := tt.app.Test(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantLoc != "" && resp.Header.Get("Location") != tt.wantLoc {
+				t.Errorf("Location = %q, want %q", resp.Header.Get("Location"), tt.wantLoc)
+			}
+		})
+	}
+}
+
+func TestSafeNextPath(t *testing.T) {
+	tests := map[string]string{
+		"":                     "/configure",
+		"/configure":           "/configure",
+		"/configure?tab=apps":  "/configure?tab=apps",
+		"https://evil.example": "/configure",
+		"//evil.example/path":  "/configure",
+		"/\\evil.example":      "/configure",
+		"javascript:alert(1)":  "/configure",
+	}
+
+	for next, want := range tests {
+		if got := safeNextPath(next); got != want {
+			t.Errorf("safeNextPath(%q) = %q, want %q", next, got, want)
+		}
+	}
+}
+
+func TestSkipCSRF(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		want   bool
+	}{
+		{fiber.MethodPost, "/app/a1/saml/acs", true},
+		{fiber.MethodPost, "/app/a1/saml/slo", true},
+		{fiber.MethodPost, "/app/a1/callback", true},
+		{fiber.MethodPost, "/app/a1", false},
+		{fiber.MethodPost, "/app/a1/", false},
+		{fiber.MethodPost, "/app/a1/auth", false},
+		{fiber.MethodPost, "/app/a1/saml/initiate", false},
+		{fiber.MethodPost, "/app/a1/oidc/refresh", false},
+		{fiber.MethodPost, "/app/a1/oidc/introspect", false},
+		{fiber.MethodPost, "/app/a1/oidc/userinfo", false},
+		{fiber.MethodGet, "/app/a1/oidc/success", false},
+		{fiber.MethodPost, "/api/config/tenants", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			app := fiber.New()
+			var got bool
+			app.All("/*", func(c fiber.Ctx) error {
+				got = SkipCSRF(c)
+				return nil
+			})
+
+			if _, err := app.Test(httptest.NewRequest(tt.method, tt.path, nil)); err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("SkipCSRF() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
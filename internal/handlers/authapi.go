@@ -0,0 +1,420 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"slices"
+	"strings"
+	"user_experience_toolkit/internal/config"
+	"user_experience_toolkit/internal/history"
+
+	duoapi "github.com/duosecurity/duo_api_golang"
+	"github.com/duosecurity/duo_api_golang/authapi"
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/session"
+)
+
+// Auth API session keys
+const (
+	authAPIUserKey     = "authapi_username"
+	authAPIAppKey      = "authapi_app"
+	authAPIDevicesKey  = "authapi_devices"
+	authAPITxidKey     = "authapi_txid"
+	authAPITxFactorKey = "authapi_tx_factor"
+	authAPITxDeviceKey = "authapi_tx_device"
+)
+
+// authAPIDeviceFactors are the factors a device can be asked for, in display order
+var authAPIDeviceFactors = []string{"push", "phone", "sms"}
+
+// authAPIDevice is an enrolled device returned by preauth
+type authAPIDevice struct {
+	Device  string   `json:"device"`
+	Type    string   `json:"type"`
+	Name    string   `json:"name,omitempty"`
+	Number  string   `json:"number,omitempty"`
+	Factors []string `json:"factors"` // push, phone and sms the device supports
+}
+
+// DisplayName returns the best available label for the device
+func (d authAPIDevice) DisplayName() string {
+	switch {
+	case d.Name != "" && d.Number != "":
+		return d.Name + " (" + d.Number + ")"
+	case d.Name != "":
+		return d.Name
+	case d.Number != "":
+		return d.Number
+	}
+	return d.Type
+}
+
+// supports reports whether factor can be sent to the device
+func (d authAPIDevice) supports(factor string) bool {
+	return slices.Contains(d.Factors, factor)
+}
+
+// AuthAPIHandler runs the Auth API flow: a password form, /auth/v2/preauth, a factor and
+// device chosen on this page instead of in the Universal Prompt, then /auth/v2/auth.
+// Push and phone callbacks run asynchronously and are polled with /auth/v2/auth_status.
+type AuthAPIHandler struct {
+	App     *config.Application
+	AuthAPI *authapi.AuthApi
+	Store   *session.Store
+}
+
+// NewAuthAPIHandlerFromApp creates a new Auth API handler from an Application config
+func NewAuthAPIHandlerFromApp(app *config.Application, store *session.Store) (*AuthAPIHandler, error) {
+	if app.GetApplicationType() != "authapi" {
+		return nil, fmt.Errorf("application is configured as %s, not Auth API", app.GetApplicationType())
+	}
+
+	api := duoapi.NewDuoApi(app.ClientID, app.ClientSecret, app.APIHostname, "user_experience_toolkit")
+	return &AuthAPIHandler{
+		App:     app,
+		AuthAPI: authapi.NewAuthApi(*api),
+		Store:   store,
+	}, nil
+}
+
+// Login renders the password form
+func (h *AuthAPIHandler) Login(c fiber.Ctx) error {
+	return h.renderLogin(c, "", nil)
+}
+
+// ProcessLogin runs preauth for the user and lists their enrolled devices
+func (h *AuthAPIHandler) ProcessLogin(c fiber.Ctx) error {
+	username := strings.TrimSpace(c.FormValue("username"))
+	password := c.FormValue("password")
+
+	// Basic validation
+	if username == "" || password == "" {
+		return h.renderLogin(c, "Incorrect username or password", nil)
+	}
+
+	preauth, err := h.AuthAPI.Preauth(authapi.PreauthUsername(username), authapi.PreauthIpAddr(c.IP()))
+	if err != nil {
+		log.Printf("[AuthAPIHandler] Preauth failed: %v", err)
+		recordFailure(nil, h.App, username, fmt.Sprintf("Preauth failed: %v", err))
+		return h.renderLogin(c, "2FA Unavailable. Confirm Duo client/secret/host values are correct", nil)
+	}
+	if preauth.Stat != "OK" {
+		log.Printf("[AuthAPIHandler] Preauth returned %s: %s", preauth.Stat, apiMessage(preauth.StatResult))
+		recordFailure(nil, h.App, username, "Duo Auth API error: "+apiMessage(preauth.StatResult))
+		return h.renderLogin(c, "Duo Auth API error: "+apiMessage(preauth.StatResult), nil)
+	}
+
+	switch preauth.Response.Result {
+	case "allow":
+		// Bypass users and applications with an allow policy skip the second factor
+		h.recordResult(nil, username, "none", "allow", preauth.Response.Status_Msg, preauth.Response)
+		return h.renderSuccess(c, username, "none", "", "allow", preauth.Response.Status_Msg, preauth.Response)
+	case "deny":
+		h.recordResult(nil, username, "", "deny", preauth.Response.Status_Msg, preauth.Response)
+		return h.renderLogin(c, preauth.Response.Status_Msg, nil)
+	case "enroll":
+		h.recordResult(nil, username, "", "enroll", preauth.Response.Status_Msg, preauth.Response)
+		return h.renderLogin(c, fmt.Sprintf("%s Enroll at %s", preauth.Response.Status_Msg, preauth.Response.Enroll_Portal_Url), nil)
+	case "auth":
+	default:
+		return h.renderLogin(c, "Unexpected preauth result: "+preauth.Response.Result, nil)
+	}
+
+	devices := preauthDevices(preauth)
+
+	sess, err := h.Store.Get(c)
+	if err != nil {
+		log.Printf("[AuthAPIHandler] Failed to get session: %v", err)
+		return h.renderLogin(c, "Session error", nil)
+	}
+	devicesJSON, err := json.Marshal(devices)
+	if err != nil {
+		return h.renderLogin(c, "Failed to store devices", nil)
+	}
+	sess.Set(authAPIUserKey, username)
+	sess.Set(authAPIAppKey, h.App.ID)
+	sess.Set(authAPIDevicesKey, string(devicesJSON))
+	sess.Delete(authAPITxidKey)
+	if err := sess.Save(); err != nil {
+		log.Printf("[AuthAPIHandler] Failed to save session: %v", err)
+		return h.renderLogin(c, "Failed to save session", nil)
+	}
+
+	return h.renderDevices(c, username, devices, "")
+}
+
+// preauthDevices lists the enrolled devices of a preauth response with the factors
+// this page can send to them
+func preauthDevices(preauth *authapi.PreauthResult) []authAPIDevice {
+	var devices []authAPIDevice
+	for _, d := range preauth.Response.Devices {
+		device := authAPIDevice{Device: d.Device, Type: d.Type, Name: d.Name, Number: d.Number}
+		for _, factor := range authAPIDeviceFactors {
+			if slices.Contains(d.Capabilities, factor) {
+				device.Factors = append(device.Factors, factor)
+			}
+		}
+		devices = append(devices, device)
+	}
+	return devices
+}
+
+// Auth sends the chosen factor to /auth/v2/auth. Passcodes and SMS are answered right
+// away; push and phone callbacks start an asynchronous transaction that the page polls.
+func (h *AuthAPIHandler) Auth(c fiber.Ctx) error {
+	sess, username, devices, err := h.pendingLogin(c)
+	if err != nil {
+		return h.renderLogin(c, err.Error(), nil)
+	}
+
+	factor := c.FormValue("factor")
+	var device authAPIDevice
+	if factor != "passcode" {
+		i := slices.IndexFunc(devices, func(d authAPIDevice) bool { return d.Device == c.FormValue("device") })
+		if i < 0 || !devices[i].supports(factor) {
+			return h.renderDevices(c, username, devices, "Choose one of the offered devices and factors")
+		}
+		device = devices[i]
+	}
+
+	options := []func(*url.Values){authapi.AuthUsername(username), authapi.AuthIpAddr(c.IP())}
+	switch factor {
+	case "passcode":
+		passcode := strings.TrimSpace(c.FormValue("passcode"))
+		if passcode == "" {
+			return h.renderDevices(c, username, devices, "Enter a passcode")
+		}
+		options = append(options, authapi.AuthPasscode(passcode))
+	case "sms":
+		options = append(options, authapi.AuthDevice(device.Device))
+	default:
+		options = append(options, authapi.AuthDevice(device.Device), authapi.AuthAsync())
+	}
+
+	markAttemptStarted(sess, h.App)
+	result, err := h.AuthAPI.Auth(factor, options...)
+	if err != nil {
+		log.Printf("[AuthAPIHandler] Auth failed: %v", err)
+		recordAttempt(sess, h.App, history.Attempt{Username: username, Factor: factor, Error: fmt.Sprintf("Auth failed: %v", err)})
+		return h.renderDevices(c, username, devices, "Duo Auth API request failed")
+	}
+	if result.Stat != "OK" {
+		log.Printf("[AuthAPIHandler] Auth returned %s: %s", result.Stat, apiMessage(result.StatResult))
+		recordAttempt(sess, h.App, history.Attempt{Username: username, Factor: factor, Error: "Duo Auth API error: " + apiMessage(result.StatResult)})
+		return h.renderDevices(c, username, devices, "Duo Auth API error: "+apiMessage(result.StatResult))
+	}
+
+	// Asynchronous push or phone callback: remember the transaction and poll it
+	if result.Response.Txid != "" {
+		sess.Set(authAPITxidKey, result.Response.Txid)
+		sess.Set(authAPITxFactorKey, factor)
+		sess.Set(authAPITxDeviceKey, device.DisplayName())
+		if err := sess.Save(); err != nil {
+			log.Printf("[AuthAPIHandler] Failed to save session: %v", err)
+			return h.renderDevices(c, username, devices, "Failed to save session")
+		}
+		return h.renderPending(c, factor, device.DisplayName())
+	}
+
+	if result.Response.Result != "allow" {
+		// SMS "fails" with status sms_sent; the user then enters one of the passcodes
+		if result.Response.Status != "sms_sent" {
+			h.recordResult(sess, username, factor, result.Response.Result, result.Response.Status_Msg, result.Response)
+		}
+		return h.renderDevices(c, username, devices, result.Response.Status_Msg)
+	}
+
+	h.recordResult(sess, username, factor, result.Response.Result, result.Response.Status_Msg, result.Response)
+	h.clearLogin(sess)
+	return h.renderSuccess(c, username, factor, device.DisplayName(), result.Response.Result, result.Response.Status_Msg, result.Response)
+}
+
+// Status reports the progress of the pending transaction as JSON for the polling page
+func (h *AuthAPIHandler) Status(c fiber.Ctx) error {
+	sess, _, _, err := h.pendingLogin(c)
+	if err != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	}
+	txid, _ := sess.Get(authAPITxidKey).(string)
+	if txid == "" {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "No pending authentication"})
+	}
+
+	status, err := h.AuthAPI.AuthStatus(txid)
+	if err != nil {
+		log.Printf("[AuthAPIHandler] Auth status failed: %v", err)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "Duo Auth API request failed"})
+	}
+	if status.Stat != "OK" {
+		log.Printf("[AuthAPIHandler] Auth status returned %s: %s", status.Stat, apiMessage(status.StatResult))
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "Duo Auth API error: " + apiMessage(status.StatResult)})
+	}
+
+	return c.JSON(fiber.Map{
+		"result":     status.Response.Result,
+		"status":     status.Response.Status,
+		"status_msg": status.Response.Status_Msg,
+	})
+}
+
+// Complete finishes the pending transaction once the page saw a final result. The result
+// is fetched again here so the outcome never depends on what the browser reports.
+func (h *AuthAPIHandler) Complete(c fiber.Ctx) error {
+	sess, username, devices, err := h.pendingLogin(c)
+	if err != nil {
+		return h.renderLogin(c, err.Error(), nil)
+	}
+	txid, _ := sess.Get(authAPITxidKey).(string)
+	factor, _ := sess.Get(authAPITxFactorKey).(string)
+	device, _ := sess.Get(authAPITxDeviceKey).(string)
+	if txid == "" {
+		return h.renderDevices(c, username, devices, "No pending authentication")
+	}
+
+	status, err := h.AuthAPI.AuthStatus(txid)
+	if err != nil {
+		log.Printf("[AuthAPIHandler] Auth status failed: %v", err)
+		return h.renderDevices(c, username, devices, "Duo Auth API request failed")
+	}
+	if status.Stat != "OK" {
+		log.Printf("[AuthAPIHandler] Auth status returned %s: %s", status.Stat, apiMessage(status.StatResult))
+		return h.renderDevices(c, username, devices, "Duo Auth API error: "+apiMessage(status.StatResult))
+	}
+
+	switch status.Response.Result {
+	case "allow":
+		h.recordResult(sess, username, factor, status.Response.Result, status.Response.Status_Msg, status.Response)
+		h.clearLogin(sess)
+		return h.renderSuccess(c, username, factor, device, status.Response.Result, status.Response.Status_Msg, status.Response)
+	case "waiting":
+		return h.renderPending(c, factor, device)
+	}
+
+	h.recordResult(sess, username, factor, status.Response.Result, status.Response.Status_Msg, status.Response)
+	sess.Delete(authAPITxidKey)
+	sess.Save()
+	return h.renderDevices(c, username, devices, status.Response.Status_Msg)
+}
+
+// pendingLogin loads the user and devices stored by ProcessLogin for this application
+func (h *AuthAPIHandler) pendingLogin(c fiber.Ctx) (*session.Session, string, []authAPIDevice, error) {
+	sess, err := h.Store.Get(c)
+	if err != nil {
+		log.Printf("[AuthAPIHandler] Failed to get session: %v", err)
+		return nil, "", nil, errors.New("Session error")
+	}
+
+	username, _ := sess.Get(authAPIUserKey).(string)
+	appID, _ := sess.Get(authAPIAppKey).(string)
+	if username == "" || appID != h.App.ID {
+		return nil, "", nil, errors.New("No pending login, please sign in again")
+	}
+
+	var devices []authAPIDevice
+	if devicesJSON, _ := sess.Get(authAPIDevicesKey).(string); devicesJSON != "" {
+		if err := json.Unmarshal([]byte(devicesJSON), &devices); err != nil {
+			return nil, "", nil, errors.New("No pending login, please sign in again")
+		}
+	}
+	return sess, username, devices, nil
+}
+
+// clearLogin removes the pending login once it succeeded
+func (h *AuthAPIHandler) clearLogin(sess *session.Session) {
+	for _, key := range []string{authAPIUserKey, authAPIAppKey, authAPIDevicesKey, authAPITxidKey, authAPITxFactorKey, authAPITxDeviceKey} {
+		sess.Delete(key)
+	}
+	if err := sess.Save(); err != nil {
+		log.Printf("[AuthAPIHandler] Failed to save session: %v", err)
+	}
+}
+
+// recordResult records a final preauth or auth result with the Auth API response as details
+func (h *AuthAPIHandler) recordResult(sess *session.Session, username, factor, result, statusMsg string, response interface{}) {
+	details, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		details = []byte(fmt.Sprintf("%+v", response))
+	}
+	recordAttempt(sess, h.App, history.Attempt{
+		Username: username,
+		Factor:   factor,
+		Success:  result == "allow",
+		Result:   result,
+		Error:    resultError(result, statusMsg),
+		Details:  string(details),
+	})
+}
+
+func (h *AuthAPIHandler) renderLogin(c fiber.Ctx, message string, extra fiber.Map) error {
+	data := fiber.Map{
+		"AppType":        "authapi",
+		"Message":        message,
+		"AppName":        h.App.Name,
+		"AppID":          h.App.ID,
+		"APIHostname":    h.App.APIHostname,
+		"AdminHostname":  getAdminHostname(h.App.APIHostname),
+		"IntegrationKey": h.App.ClientID,
+	}
+	for key, value := range extra {
+		data[key] = value
+	}
+	return c.Render("login", data)
+}
+
+// renderDevices shows the enrolled devices and their factors after a successful preauth
+func (h *AuthAPIHandler) renderDevices(c fiber.Ctx, username string, devices []authAPIDevice, message string) error {
+	return h.renderLogin(c, message, fiber.Map{
+		"Username":     username,
+		"Devices":      devices,
+		"ChooseFactor": true,
+	})
+}
+
+// renderPending shows the polling page of an asynchronous push or phone callback
+func (h *AuthAPIHandler) renderPending(c fiber.Ctx, factor, device string) error {
+	return h.renderLogin(c, "", fiber.Map{
+		"Pending":       true,
+		"PendingFactor": factor,
+		"PendingDevice": device,
+	})
+}
+
+func (h *AuthAPIHandler) renderSuccess(c fiber.Ctx, username, factor, device, result, status string, response interface{}) error {
+	// Format the Auth API response as JSON for display
+	tokenJSON, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		tokenJSON = []byte(fmt.Sprintf("%+v", response))
+	}
+
+	if device != "" {
+		factor += " to " + device
+	}
+
+	return c.Render("success", fiber.Map{
+		"AppType":        "authapi",
+		"TokenData":      string(tokenJSON),
+		"AppName":        h.App.Name,
+		"AppID":          h.App.ID,
+		"AuthResult":     result,
+		"AuthStatus":     status,
+		"AuthFactor":     factor,
+		"UserEmail":      username,
+		"AdminHostname":  getAdminHostname(h.App.APIHostname),
+		"IntegrationKey": h.App.ClientID,
+	})
+}
+
+// apiMessage describes a failed Auth API call
+func apiMessage(stat duoapi.StatResult) string {
+	message := stat.Stat
+	if stat.Message != nil {
+		message = *stat.Message
+	}
+	if stat.Message_Detail != nil {
+		message += " (" + *stat.Message_Detail + ")"
+	}
+	return message
+}
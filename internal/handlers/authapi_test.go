@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+	"user_experience_toolkit/internal/config"
+
+	duoapi "github.com/duosecurity/duo_api_golang"
+	"github.com/duosecurity/duo_api_golang/authapi"
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/session"
+)
+
+func TestPreauthDevices(t *testing.T) {
+	var preauth authapi.PreauthResult
+	body := `{"stat": "OK", "response": {"result": "auth", "devices": [
+		{"device": "DPFZRS9FB0D46QFTM890", "type": "phone", "name": "iPhone", "number": "XXX-XXX-0100", "capabilities": ["auto", "push", "sms", "phone", "mobile_otp"]},
+		{"device": "DHEKH0JJIYC1LX3AZWO4", "type": "token", "capabilities": []}
+	]}}`
+	if err := json.Unmarshal([]byte(body), &preauth); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	devices := preauthDevices(&preauth)
+	if len(devices) != 2 {
+		t.Fatalf("preauthDevices() returned %d devices, want 2", len(devices))
+	}
+	if want := []string{"push", "phone", "sms"}; !slices.Equal(devices[0].Factors, want) {
+		t.Errorf("Factors = %v, want %v", devices[0].Factors, want)
+	}
+	if got := devices[0].DisplayName(); got != "iPhone (XXX-XXX-0100)" {
+		t.Errorf("DisplayName() = %q", got)
+	}
+	if len(devices[1].Factors) != 0 || devices[1].DisplayName() != "token" {
+		t.Errorf("token device = %+v, want no factors and its type as name", devices[1])
+	}
+}
+
+func TestAuthAPIStatus(t *testing.T) {
+	var gotTxid string
+	duo := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/auth/v2/auth_status" {
+			http.NotFound(w, r)
+			return
+		}
+		gotTxid = r.URL.Query().Get("txid")
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"stat": "OK", "response": {"result": "waiting", "status": "pushed", "status_msg": "Pushed a login request to your device..."}}`)
+	}))
+	defer duo.Close()
+
+	host, _ := url.Parse(duo.URL)
+	api := duoapi.NewDuoApi("DIXXXXXXXXXXXXXXXXXX", "secret", host.Host, "test", duoapi.SetInsecure())
+	handler := &AuthAPIHandler{
+		App:     &config.Application{ID: "app1", Type: "authapi"},
+		AuthAPI: authapi.NewAuthApi(*api),
+		Store:   session.NewStore(),
+	}
+
+	app := fiber.New()
+	app.Get("/start", func(c fiber.Ctx) error {
+		sess, err := handler.Store.Get(c)
+		if err != nil {
+			return err
+		}
+		sess.Set(authAPIUserKey, "alice")
+		sess.Set(authAPIAppKey, "app1")
+		sess.Set(authAPITxidKey, "tx-123")
+		return sess.Save()
+	})
+	app.Get("/status", handler.Status)
+
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/status", nil))
+	if err != nil {
+		t.Fatalf("app.Test() error = %v", err)
+	}
+	if resp.StatusCode != fiber.StatusConflict {
+		t.Errorf("status without a pending login = %d, want 409", resp.StatusCode)
+	}
+
+	resp, err = app.Test(httptest.NewRequest(fiber.MethodGet, "/start", nil))
+	if err != nil {
+		t.Fatalf("app.Test() error = %v", err)
+	}
+	req := httptest.NewRequest(fiber.MethodGet, "/status", nil)
+	for _, cookie := range resp.Cookies() {
+		req.AddCookie(cookie)
+	}
+	resp, err = app.Test(req)
+	if err != nil {
+		t.Fatalf("app.Test() error = %v", err)
+	}
+
+	var status struct {
+		Result    string `json:"result"`
+		Status    string `json:"status"`
+		StatusMsg string `json:"status_msg"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK || status.Result != "waiting" || status.Status != "pushed" {
+		t.Errorf("Status() = %d %+v, want 200 waiting/pushed", resp.StatusCode, status)
+	}
+	if gotTxid != "tx-123" {
+		t.Errorf("auth_status txid = %q, want the one from the session", gotTxid)
+	}
+}
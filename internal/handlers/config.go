@@ -1,13 +1,16 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"user_experience_toolkit/internal/config"
 	"user_experience_toolkit/internal/duoadmin"
+	"user_experience_toolkit/internal/provision"
+	samlutil "user_experience_toolkit/internal/saml"
 
 	"github.com/gofiber/fiber/v3"
-	"github.com/google/uuid"
 )
 
 type ConfigHandler struct {
@@ -33,20 +36,44 @@ func (h *ConfigHandler) Show(c fiber.Ctx) error {
 	for _, tenant := range tenants {
 		apps := h.Config.GetApplicationsByTenant(tenant.ID)
 		tenantsWithApps = append(tenantsWithApps, TenantWithApps{
-			Tenant:       tenant,
-			Applications: apps,
+			Tenant:       tenant.Redacted(),
+			Applications: config.RedactApplications(apps),
 		})
 	}
 
+	adminUser, _ := c.Locals(adminUserKey).(string)
+
 	return c.Render("configure", fiber.Map{
-		"Tenants": tenantsWithApps,
+		"Tenants":          tenantsWithApps,
+		"AdminUser":        adminUser,
+		"ApplicationTypes": config.ApplicationTypes(),
 	})
 }
 
-// ListApplications returns JSON list of all applications
+// ListApplications returns JSON list of all applications with their secrets redacted
 func (h *ConfigHandler) ListApplications(c fiber.Ctx) error {
 	return c.JSON(fiber.Map{
-		"applications": h.Config.Applications,
+		"applications": config.RedactApplications(h.Config.Applications),
+	})
+}
+
+// RevealApplicationSecrets returns the client secret and signing key of one application.
+// Every call is written to the audit log.
+func (h *ConfigHandler) RevealApplicationSecrets(c fiber.Ctx) error {
+	app, err := h.Config.GetApplication(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	log.Printf("[Audit] %s revealed the secrets of application %s (%s) from %s", adminIdentity(c), app.ID, app.Name, c.IP())
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.JSON(fiber.Map{
+		"id":            app.ID,
+		"client_secret": app.ClientSecret,
+		"signing_key":   app.SigningKey,
 	})
 }
 
@@ -68,7 +95,7 @@ func (h *ConfigHandler) AddApplication(c fiber.Ctx) error {
 
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"message":     "Application added successfully",
-		"application": app,
+		"application": app.Redacted(),
 	})
 }
 
@@ -96,11 +123,13 @@ func (h *ConfigHandler) UpdateApplication(c fiber.Ctx) error {
 
 	return c.JSON(fiber.Map{
 		"message":     "Application updated successfully",
-		"application": app,
+		"application": app.Redacted(),
 	})
 }
 
-// DeleteApplication deletes an application
+// DeleteApplication deletes an application. With ?remote=true the Duo integration is
+// deleted through the tenant's Admin API first, and ?dry_run=true only reports which
+// integration would be removed.
 func (h *ConfigHandler) DeleteApplication(c fiber.Ctx) error {
 	id := c.Params("id")
 	if id == "" {
@@ -109,323 +138,212 @@ func (h *ConfigHandler) DeleteApplication(c fiber.Ctx) error {
 		})
 	}
 
+	app, err := h.Config.GetApplication(id)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	remote := fiber.Query[bool](c, "remote")
+	var integrations []IntegrationDeletion
+	if remote {
+		tenant, _ := h.Config.GetTenant(app.TenantID)
+		integrations = planIntegrationDeletions([]config.Application{*app}, tenant)
+
+		if fiber.Query[bool](c, "dry_run") {
+			return c.JSON(fiber.Map{
+				"dry_run":      true,
+				"integrations": integrations,
+			})
+		}
+
+		if !deleteIntegrations(integrations, tenant) {
+			return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+				"error":        "Failed to delete the Duo integration, the application was not deleted",
+				"integrations": integrations,
+			})
+		}
+		logIntegrationDeletions(c, integrations)
+	}
+
 	if err := h.Config.DeleteApplication(id); err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
 
+	if remote {
+		return c.JSON(fiber.Map{
+			"message":      "Application and Duo integration deleted successfully",
+			"integrations": integrations,
+		})
+	}
 	return c.JSON(fiber.Map{
 		"message": "Application deleted successfully",
 	})
 }
 
-// AutoCreateApplicationRequest represents the request body for auto-creating an application
-type AutoCreateApplicationRequest struct {
-	Name     string `json:"name"`
-	Type     string `json:"type"` // "websdk", "dmp", or "saml"
-	Enabled  bool   `json:"enabled"`
-	TenantID string `json:"tenant_id"` // Reference to tenant for Admin API creds
+// ImportSAMLMetadataRequest represents the request body for importing IdP metadata.
+// Exactly one of MetadataURL or MetadataXML must be provided.
+type ImportSAMLMetadataRequest struct {
+	MetadataURL string `json:"metadata_url"`
+	MetadataXML string `json:"metadata_xml"`
 }
 
-// AddTenantRequest represents the request body for adding a new tenant
-type AddTenantRequest struct {
-	Name           string `json:"name"`
-	AdminAPIKey    string `json:"admin_api_key"`
-	AdminAPISecret string `json:"admin_api_secret"`
-	APIHostname    string `json:"api_hostname"`
-}
-
-// AutoCreateApplication creates a new application using the Duo Admin API
-func (h *ConfigHandler) AutoCreateApplication(c fiber.Ctx) error {
-	log.Printf("[ConfigHandler] AutoCreateApplication called")
-
-	var req AutoCreateApplicationRequest
-
-	if err := c.Bind().JSON(&req); err != nil {
-		log.Printf("[ConfigHandler] Failed to parse request body: %v", err)
+// ImportSAMLMetadata fills the IdP fields of a SAML application from an IdP metadata
+// document, either fetched from a URL or pasted as XML
+func (h *ConfigHandler) ImportSAMLMetadata(c fiber.Ctx) error {
+	id := c.Params("id")
+	if id == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
+			"error": "Application ID is required",
 		})
 	}
 
-	log.Printf("[ConfigHandler] Request received - Name: %s, Type: %s, Enabled: %v, TenantID: %s",
-		req.Name, req.Type, req.Enabled, req.TenantID)
-
-	// Validate required fields
-	if req.Name == "" {
-		log.Printf("[ConfigHandler] Validation failed: Application name is required")
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Application name is required",
-		})
-	}
-	if req.Type != "websdk" && req.Type != "dmp" && req.Type != "saml" && req.Type != "oidc" {
-		log.Printf("[ConfigHandler] Validation failed: Invalid type '%s'", req.Type)
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Type must be 'websdk', 'dmp', 'saml', or 'oidc'",
+	existing, err := h.Config.GetApplication(id)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
 		})
 	}
-	if req.TenantID == "" {
-		log.Printf("[ConfigHandler] Validation failed: Tenant ID is required")
+	app := *existing
+
+	if app.GetApplicationType() != "saml" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Tenant ID is required",
+			"error": "IdP metadata can only be imported for SAML applications",
 		})
 	}
 
-	// Get the tenant to retrieve Admin API credentials
-	tenant, err := h.Config.GetTenant(req.TenantID)
-	if err != nil {
-		log.Printf("[ConfigHandler] Failed to get tenant: %v", err)
+	var req ImportSAMLMetadataRequest
+	if err := c.Bind().JSON(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Tenant not found: " + err.Error(),
+			"error": "Invalid request body",
 		})
 	}
 
-	log.Printf("[ConfigHandler] Using tenant '%s' with hostname: %s", tenant.Name, tenant.APIHostname)
-
-	// Create Duo Admin API client with tenant's credentials
-	adminClient := duoadmin.NewClient(tenant.AdminAPIKey, tenant.AdminAPISecret, tenant.APIHostname)
-
-	// Validate credentials first
-	log.Printf("[ConfigHandler] Validating Admin API credentials...")
-	if err := adminClient.ValidateCredentials(); err != nil {
-		log.Printf("[ConfigHandler] Credential validation failed: %v", err)
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "Invalid Admin API credentials or insufficient permissions: " + err.Error(),
+	req.MetadataURL = strings.TrimSpace(req.MetadataURL)
+	req.MetadataXML = strings.TrimSpace(req.MetadataXML)
+	if (req.MetadataURL == "") == (req.MetadataXML == "") {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Provide either metadata_url or metadata_xml",
 		})
 	}
 
-	log.Printf("[ConfigHandler] Credentials validated successfully")
-
-	// Prefix application name with tenant name
-	fullAppName := fmt.Sprintf("%s - %s", tenant.Name, req.Name)
-
-	var app config.Application
-
-	// Handle SAML type separately
-	if req.Type == "saml" {
-		log.Printf("[ConfigHandler] Creating SAML integration: %s", fullAppName)
-
-		// For SAML, we need to generate app ID and URLs first, then create everything together
-		baseURL := c.BaseURL()
-
-		// Generate a new UUID for the app
-		appID := uuid.New().String()
-
-		// Generate SAML URLs using the new app ID
-		entityID := fmt.Sprintf("%s/app/%s/saml", baseURL, appID)
-		acsURL := fmt.Sprintf("%s/app/%s/saml/acs", baseURL, appID)
-		metadataURL := fmt.Sprintf("%s/app/%s/saml/metadata", baseURL, appID)
-
-		log.Printf("[ConfigHandler] Generated app ID: %s", appID)
-		log.Printf("[ConfigHandler] Entity ID: %s", entityID)
-		log.Printf("[ConfigHandler] ACS URL: %s", acsURL)
-
-		// Create SAML integration via Admin API
-		samlIntegration, err := adminClient.CreateSAMLIntegration(duoadmin.CreateSAMLIntegrationParams{
-			Name:            fullAppName,
-			EntityID:        entityID,
-			ACSURL:          acsURL,
-			NameIDFormat:    "urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress",
-			NameIDAttribute: "<Email Address>",
-		})
+	data := []byte(req.MetadataXML)
+	if req.MetadataURL != "" {
+		log.Printf("[ConfigHandler] Fetching IdP metadata for app %s from: %s", app.Name, req.MetadataURL)
+		data, err = samlutil.FetchIDPMetadata(c.Context(), req.MetadataURL)
 		if err != nil {
-			log.Printf("[ConfigHandler] Failed to create SAML integration: %v", err)
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to create SAML application via Duo Admin API: " + err.Error(),
-			})
-		}
-
-		log.Printf("[ConfigHandler] SAML integration created successfully")
-
-		// Extract IDP metadata from the API response
-		integrationKey := samlIntegration.IntegrationKey
-		idpEntityID := samlIntegration.SSO.IDPMetadata.EntityID
-		idpSSOURL := samlIntegration.SSO.IDPMetadata.SSOURL
-		idpCertificate := samlIntegration.SSO.IDPMetadata.Cert
-
-		log.Printf("[ConfigHandler] Integration Key: %s", integrationKey)
-		log.Printf("[ConfigHandler] IDP Entity ID: %s", idpEntityID)
-		log.Printf("[ConfigHandler] IDP SSO URL: %s", idpSSOURL)
-		log.Printf("[ConfigHandler] IDP Certificate length: %d bytes", len(idpCertificate))
-
-		// Create the complete application object with all required fields
-		app = config.Application{
-			ID:          appID,
-			TenantID:    req.TenantID,
-			Name:        fullAppName,
-			Type:        "saml",
-			Enabled:     req.Enabled,
-			ClientID:    integrationKey,
-			APIHostname: tenant.APIHostname,
-			EntityID:    entityID,
-			ACSURL:      acsURL,
-			MetadataURL: metadataURL,
-			// IDP metadata from Duo API response
-			IDPEntityID:    idpEntityID,
-			IDPSSOURL:      idpSSOURL,
-			IDPCertificate: idpCertificate,
-		}
-
-		// Now add the complete app to config (only once with all fields)
-		if err := h.Config.AddApplication(app); err != nil {
-			log.Printf("[ConfigHandler] Failed to save application to config: %v", err)
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			log.Printf("[ConfigHandler] Failed to fetch IdP metadata: %v", err)
+			return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
 				"error": err.Error(),
 			})
 		}
+	}
 
-		log.Printf("[ConfigHandler] SAML application created successfully. ID: %s", app.ID)
-
-		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-			"message":     "SAML application created successfully via Duo Admin API",
-			"application": app,
+	metadata, err := samlutil.ParseIDPMetadata(data)
+	if err != nil {
+		log.Printf("[ConfigHandler] Failed to parse IdP metadata: %v", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Invalid IdP metadata: %v", err),
 		})
 	}
 
-	// Handle OIDC type separately
-	if req.Type == "oidc" {
-		log.Printf("[ConfigHandler] Creating OIDC integration: %s", fullAppName)
+	log.Printf("[ConfigHandler] IDP Entity ID: %s", metadata.EntityID)
+	log.Printf("[ConfigHandler] IDP SSO URL: %s", metadata.SSOURL)
+	log.Printf("[ConfigHandler] IDP SLO URL: %s", metadata.SLOURL)
+	log.Printf("[ConfigHandler] IDP signing certificates: %d", len(metadata.Certificates))
 
-		// For OIDC, we need to generate app ID and redirect URI first, then create everything together
-		baseURL := c.BaseURL()
+	app.IDPEntityID = metadata.EntityID
+	app.IDPSSOURL = metadata.SSOURL
+	app.IDPSLOURL = metadata.SLOURL
+	app.IDPCertificate = metadata.CertificatePEM
 
-		// Generate a new UUID for the app
-		appID := uuid.New().String()
+	if err := h.Config.UpdateApplication(id, app); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 
-		// Generate OIDC redirect URI using the new app ID
-		redirectURI := fmt.Sprintf("%s/app/%s/oidc/callback", baseURL, appID)
+	return c.JSON(fiber.Map{
+		"message":     "IdP metadata imported successfully",
+		"metadata":    metadata,
+		"application": app.Redacted(),
+	})
+}
 
-		log.Printf("[ConfigHandler] Generated app ID: %s", appID)
-		log.Printf("[ConfigHandler] Redirect URI: %s", redirectURI)
+// AutoCreateApplicationRequest represents the request body for auto-creating an application
+type AutoCreateApplicationRequest struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"` // a registered application type, e.g. "websdk" or "saml"
+	Enabled  bool   `json:"enabled"`
+	TenantID string `json:"tenant_id"` // Reference to tenant for Admin API creds
 
-		// Create OIDC integration via Admin API
-		oidcIntegration, err := adminClient.CreateOIDCIntegration(duoadmin.CreateOIDCIntegrationParams{
-			Name:                   fullAppName,
-			RedirectURIs:           []string{redirectURI},
-			Scopes:                 []string{}, // Only openid, which is added automatically
-			AccessTokenLifespan:    3600,
-			AllowPKCEOnly:          false,
-			EnableRefreshToken:     true,
-			RefreshTokenChainLife:  2592000,
-			RefreshTokenSingleLife: 86400,
-		})
-		if err != nil {
-			log.Printf("[ConfigHandler] Failed to create OIDC integration: %v", err)
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to create OIDC application via Duo Admin API: " + err.Error(),
-			})
-		}
+	// OIDC only
+	Scopes   []string `json:"scopes,omitempty"`    // Scopes in addition to openid, e.g. ["profile", "email"]
+	PKCEMode string   `json:"pkce_mode,omitempty"` // "", "s256" or "public"
+}
 
-		log.Printf("[ConfigHandler] OIDC integration created successfully")
-
-		// Extract IDP metadata from the API response
-		clientID := oidcIntegration.SSO.IDPMetadata.ClientID
-		clientSecret := oidcIntegration.SSO.IDPMetadata.ClientSecret
-		discoveryURL := oidcIntegration.SSO.IDPMetadata.DiscoveryURL
-		issuer := oidcIntegration.SSO.IDPMetadata.Issuer
-		authEndpoint := oidcIntegration.SSO.IDPMetadata.AuthorizeEndpointURL
-		tokenEndpoint := oidcIntegration.SSO.IDPMetadata.TokenEndpointURL
-		userinfoEndpoint := oidcIntegration.SSO.IDPMetadata.UserInfoEndpointURL
-		jwksEndpoint := oidcIntegration.SSO.IDPMetadata.JWKSEndpointURL
-
-		log.Printf("[ConfigHandler] Client ID: %s", clientID)
-		log.Printf("[ConfigHandler] Discovery URL: %s", discoveryURL)
-		log.Printf("[ConfigHandler] Issuer: %s", issuer)
-
-		// Create the complete application object with all required fields
-		app = config.Application{
-			ID:           appID,
-			TenantID:     req.TenantID,
-			Name:         fullAppName,
-			Type:         "oidc",
-			Enabled:      req.Enabled,
-			ClientID:     clientID,
-			ClientSecret: clientSecret,
-			APIHostname:  tenant.APIHostname,
-			RedirectURI:  redirectURI,
-			// IDP metadata from Duo API response
-			IDPDiscoveryURL:          discoveryURL,
-			IDPIssuer:                issuer,
-			IDPAuthorizationEndpoint: authEndpoint,
-			IDPTokenEndpoint:         tokenEndpoint,
-			IDPUserInfoEndpoint:      userinfoEndpoint,
-			IDPJWKSEndpoint:          jwksEndpoint,
-		}
+// AddTenantRequest represents the request body for adding a new tenant
+type AddTenantRequest struct {
+	Name           string `json:"name"`
+	AdminAPIKey    string `json:"admin_api_key"`
+	AdminAPISecret string `json:"admin_api_secret"`
+	APIHostname    string `json:"api_hostname"`
+}
 
-		// Now add the complete app to config (only once with all fields)
-		if err := h.Config.AddApplication(app); err != nil {
-			log.Printf("[ConfigHandler] Failed to save application to config: %v", err)
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": err.Error(),
-			})
-		}
+// AutoCreateApplication creates a new application using the Duo Admin API
+func (h *ConfigHandler) AutoCreateApplication(c fiber.Ctx) error {
+	log.Printf("[ConfigHandler] AutoCreateApplication called")
 
-		log.Printf("[ConfigHandler] OIDC application created successfully. ID: %s", app.ID)
+	var req AutoCreateApplicationRequest
 
-		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-			"message":     "OIDC application created successfully via Duo Admin API",
-			"application": app,
+	if err := c.Bind().JSON(&req); err != nil {
+		log.Printf("[ConfigHandler] Failed to parse request body: %v", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
 		})
 	}
 
-	// Map the type to Duo's integration type for websdk/dmp
-	// For Web SDK v4 (Universal Prompt), the integration type is "websdk"
-	// For Device Management Portal, the integration type is "device-management-portal"
-	integrationType := "websdk" // Default to WebSDK
-	if req.Type == "dmp" {
-		integrationType = "device-management-portal"
-	}
-
-	log.Printf("[ConfigHandler] Creating integration with type: %s, name: %s", integrationType, fullAppName)
+	log.Printf("[ConfigHandler] Request received - Name: %s, Type: %s, Enabled: %v, TenantID: %s",
+		req.Name, req.Type, req.Enabled, req.TenantID)
 
-	// Create the integration via Admin API
-	integration, err := adminClient.CreateIntegration(duoadmin.CreateIntegrationParams{
-		Name:    fullAppName,
-		Type:    integrationType,
-		Enabled: req.Enabled,
+	app, err := provision.NewProvisioner(h.Config).CreateApplication(provision.Request{
+		Name:     req.Name,
+		Type:     req.Type,
+		Enabled:  req.Enabled,
+		TenantID: req.TenantID,
+		BaseURL:  c.BaseURL(),
+		Scopes:   req.Scopes,
+		PKCEMode: req.PKCEMode,
 	})
 	if err != nil {
-		log.Printf("[ConfigHandler] Failed to create integration: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to create application via Duo Admin API: " + err.Error(),
-		})
-	}
-
-	log.Printf("[ConfigHandler] Integration created successfully")
-
-	// Create the application config with the returned credentials
-	app = config.Application{
-		TenantID:     req.TenantID,
-		Name:         fullAppName,
-		Type:         req.Type,
-		Enabled:      req.Enabled,
-		ClientID:     integration.IntegrationKey,
-		ClientSecret: integration.SecretKey,
-		APIHostname:  tenant.APIHostname,
-	}
-
-	log.Printf("[ConfigHandler] Saving application to config...")
-
-	// Add to config
-	if err := h.Config.AddApplication(app); err != nil {
-		log.Printf("[ConfigHandler] Failed to save application to config: %v", err)
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		log.Printf("[ConfigHandler] Failed to auto-create application: %v", err)
+		status := fiber.StatusBadRequest
+		switch {
+		case errors.Is(err, provision.ErrCredentials):
+			status = fiber.StatusUnauthorized
+		case errors.Is(err, provision.ErrAdminAPI):
+			status = fiber.StatusInternalServerError
+		}
+		return c.Status(status).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
 
 	log.Printf("[ConfigHandler] Application created and saved successfully. ID: %s", app.ID)
 
+	appType, _ := config.LookupApplicationType(app.Type)
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-		"message":     "Application created successfully via Duo Admin API",
-		"application": app,
+		"message":     fmt.Sprintf("%s application created successfully via Duo Admin API", appType.Label),
+		"application": app.Redacted(),
 	})
 }
 
 // Tenant Handlers
 
-// ListTenants returns JSON list of all tenants with their applications
+// ListTenants returns JSON list of all tenants with their applications, secrets redacted
 func (h *ConfigHandler) ListTenants(c fiber.Ctx) error {
 	tenants := h.Config.GetAllTenants()
 
@@ -439,8 +357,8 @@ func (h *ConfigHandler) ListTenants(c fiber.Ctx) error {
 	for _, tenant := range tenants {
 		apps := h.Config.GetApplicationsByTenant(tenant.ID)
 		response = append(response, TenantWithApps{
-			Tenant:       tenant,
-			Applications: apps,
+			Tenant:       tenant.Redacted(),
+			Applications: config.RedactApplications(apps),
 		})
 	}
 
@@ -497,11 +415,204 @@ func (h *ConfigHandler) AddTenant(c fiber.Ctx) error {
 
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"message": "Tenant added successfully",
-		"tenant":  tenant,
+		"tenant":  tenant.Redacted(),
 	})
 }
 
-// DeleteTenant deletes a tenant and all its applications
+// UpdateTenant changes the name, hostname or Admin API credentials of a tenant. The
+// resulting credentials are validated against the Duo Admin API before they are saved.
+// An admin_api_secret that is empty or the redacted placeholder keeps the stored secret.
+func (h *ConfigHandler) UpdateTenant(c fiber.Ctx) error {
+	id := c.Params("id")
+
+	existing, err := h.Config.GetTenant(id)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var req AddTenantRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	tenant := config.Tenant{
+		Name:           strings.TrimSpace(req.Name),
+		AdminAPIKey:    strings.TrimSpace(req.AdminAPIKey),
+		AdminAPISecret: req.AdminAPISecret,
+		APIHostname:    strings.TrimSpace(req.APIHostname),
+	}
+	if tenant.AdminAPISecret == "" || tenant.AdminAPISecret == config.RedactedSecret {
+		tenant.AdminAPISecret = existing.AdminAPISecret
+	}
+
+	log.Printf("[ConfigHandler] Updating tenant %s - Name: %s, APIHostname: %s", id, tenant.Name, tenant.APIHostname)
+
+	adminClient := duoadmin.NewClient(tenant.AdminAPIKey, tenant.AdminAPISecret, tenant.APIHostname)
+	if err := adminClient.ValidateCredentials(); err != nil {
+		log.Printf("[ConfigHandler] Credential validation failed: %v", err)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid Admin API credentials or insufficient permissions: " + err.Error(),
+		})
+	}
+
+	if err := h.Config.UpdateTenant(id, tenant); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if tenant.AdminAPIKey != existing.AdminAPIKey || tenant.AdminAPISecret != existing.AdminAPISecret {
+		log.Printf("[Audit] %s replaced the Admin API credentials of tenant %s (%s) from %s", adminIdentity(c), id, tenant.Name, c.IP())
+	}
+
+	updated, _ := h.Config.GetTenant(id)
+	return c.JSON(fiber.Map{
+		"message": "Tenant updated successfully",
+		"tenant":  updated.Redacted(),
+	})
+}
+
+// RotateTenantCredentialsRequest carries the new Admin API credentials of a rotation.
+// AdminAPIKey defaults to the active integration key when only the secret changes.
+type RotateTenantCredentialsRequest struct {
+	AdminAPIKey    string `json:"admin_api_key"`
+	AdminAPISecret string `json:"admin_api_secret"`
+}
+
+// StartTenantRotation validates new Admin API credentials and stages them next to the
+// active ones, which stay in use until the rotation is confirmed
+func (h *ConfigHandler) StartTenantRotation(c fiber.Ctx) error {
+	id := c.Params("id")
+
+	tenant, err := h.Config.GetTenant(id)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var req RotateTenantCredentialsRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	req.AdminAPIKey = strings.TrimSpace(req.AdminAPIKey)
+	if req.AdminAPIKey == "" {
+		req.AdminAPIKey = tenant.AdminAPIKey
+	}
+	if req.AdminAPISecret == "" || req.AdminAPISecret == config.RedactedSecret {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "The new admin_api_secret is required",
+		})
+	}
+
+	log.Printf("[ConfigHandler] Validating new Admin API credentials for tenant %s (%s)", tenant.ID, tenant.Name)
+	adminClient := duoadmin.NewClient(req.AdminAPIKey, req.AdminAPISecret, tenant.APIHostname)
+	if err := adminClient.ValidateCredentials(); err != nil {
+		log.Printf("[ConfigHandler] New credential validation failed: %v", err)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "The new Admin API credentials were rejected, the active credentials are unchanged: " + err.Error(),
+		})
+	}
+
+	if err := h.Config.StageTenantCredentials(id, req.AdminAPIKey, req.AdminAPISecret); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	log.Printf("[Audit] %s staged new Admin API credentials for tenant %s (%s) from %s", adminIdentity(c), tenant.ID, tenant.Name, c.IP())
+
+	updated, _ := h.Config.GetTenant(id)
+	return c.JSON(fiber.Map{
+		"message": "New credentials validated. The active credentials stay in use until you confirm the rotation.",
+		"tenant":  updated.Redacted(),
+	})
+}
+
+// ConfirmTenantRotation re-validates the staged credentials and makes them active
+func (h *ConfigHandler) ConfirmTenantRotation(c fiber.Ctx) error {
+	id := c.Params("id")
+
+	tenant, err := h.Config.GetTenant(id)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if !tenant.RotationPending() {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "No credential rotation is pending for this tenant",
+		})
+	}
+
+	adminClient := duoadmin.NewClient(tenant.PendingAdminAPIKey, tenant.PendingAdminAPISecret, tenant.APIHostname)
+	if err := adminClient.ValidateCredentials(); err != nil {
+		log.Printf("[ConfigHandler] Pending credential validation failed: %v", err)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "The new Admin API credentials no longer validate, the active credentials are unchanged: " + err.Error(),
+		})
+	}
+
+	if err := h.Config.ConfirmTenantRotation(id); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	log.Printf("[Audit] %s confirmed the Admin API credential rotation of tenant %s (%s) from %s", adminIdentity(c), tenant.ID, tenant.Name, c.IP())
+
+	updated, _ := h.Config.GetTenant(id)
+	return c.JSON(fiber.Map{
+		"message": "Credential rotation confirmed. The old Admin API secret is no longer stored.",
+		"tenant":  updated.Redacted(),
+	})
+}
+
+// CancelTenantRotation discards staged credentials and keeps the active ones
+func (h *ConfigHandler) CancelTenantRotation(c fiber.Ctx) error {
+	id := c.Params("id")
+
+	if err := h.Config.CancelTenantRotation(id); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	log.Printf("[Audit] %s cancelled the Admin API credential rotation of tenant %s from %s", adminIdentity(c), id, c.IP())
+
+	return c.JSON(fiber.Map{
+		"message": "Credential rotation cancelled",
+	})
+}
+
+// RevealTenantSecret returns the Admin API secret of one tenant.
+// Every call is written to the audit log.
+func (h *ConfigHandler) RevealTenantSecret(c fiber.Ctx) error {
+	tenant, err := h.Config.GetTenant(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	log.Printf("[Audit] %s revealed the Admin API secret of tenant %s (%s) from %s", adminIdentity(c), tenant.ID, tenant.Name, c.IP())
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.JSON(fiber.Map{
+		"id":               tenant.ID,
+		"admin_api_secret": tenant.AdminAPISecret,
+	})
+}
+
+// DeleteTenant deletes a tenant and all its applications. With ?remote=true the Duo
+// integrations of those applications are deleted as well, and ?dry_run=true only
+// lists the integrations that would be removed.
 func (h *ConfigHandler) DeleteTenant(c fiber.Ctx) error {
 	id := c.Params("id")
 	if id == "" {
@@ -510,6 +621,38 @@ func (h *ConfigHandler) DeleteTenant(c fiber.Ctx) error {
 		})
 	}
 
+	tenant, err := h.Config.GetTenant(id)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	remote := fiber.Query[bool](c, "remote")
+	var integrations []IntegrationDeletion
+	if remote {
+		integrations = planIntegrationDeletions(h.Config.GetApplicationsByTenant(id), tenant)
+
+		if fiber.Query[bool](c, "dry_run") {
+			return c.JSON(fiber.Map{
+				"dry_run":      true,
+				"integrations": integrations,
+			})
+		}
+
+		log.Printf("[ConfigHandler] Deleting %d Duo integrations of tenant %s", len(integrations), id)
+		if !deleteIntegrations(integrations, tenant) {
+			logIntegrationDeletions(c, integrations)
+			removed := h.removeDeletedApplications(integrations)
+			return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+				"error":                "Failed to delete some Duo integrations, the tenant was not deleted. Applications whose integrations were deleted have been removed.",
+				"integrations":         integrations,
+				"removed_applications": removed,
+			})
+		}
+		logIntegrationDeletions(c, integrations)
+	}
+
 	log.Printf("[ConfigHandler] Deleting tenant: %s", id)
 
 	if err := h.Config.DeleteTenant(id); err != nil {
@@ -520,6 +663,12 @@ func (h *ConfigHandler) DeleteTenant(c fiber.Ctx) error {
 
 	log.Printf("[ConfigHandler] Tenant and associated applications deleted successfully")
 
+	if remote {
+		return c.JSON(fiber.Map{
+			"message":      "Tenant, its applications and their Duo integrations deleted successfully",
+			"integrations": integrations,
+		})
+	}
 	return c.JSON(fiber.Map{
 		"message": "Tenant and all associated applications deleted successfully",
 	})
@@ -6,6 +6,7 @@ import (
 	"log"
 	"strings"
 	"user_experience_toolkit/internal/config"
+	"user_experience_toolkit/internal/history"
 
 	"github.com/duosecurity/duo_universal_golang/duouniversal"
 	"github.com/gofiber/fiber/v3"
@@ -138,6 +139,7 @@ func (h *DMPHandler) ProcessLogin(c fiber.Ctx) error {
 
 	sess.Set("state", state)
 	sess.Set("username", username)
+	markAttemptStarted(sess, h.App)
 
 	if err := sess.Save(); err != nil {
 		log.Printf("Failed to save session: %v", err)
@@ -175,6 +177,8 @@ func (h *DMPHandler) Callback(c fiber.Ctx) error {
 	if errMsg := c.Query("error"); errMsg != "" {
 		errDesc := c.Query("error_description")
 		log.Printf("Duo auth error: %s - %s", errMsg, errDesc)
+		sess, _ := h.Store.Get(c)
+		recordFailure(sess, h.App, sessionString(sess, "username"), fmt.Sprintf("%s: %s", errMsg, errDesc))
 		return c.SendString(fmt.Sprintf("Got Error: %s: %s", errMsg, errDesc))
 	}
 
@@ -183,6 +187,7 @@ func (h *DMPHandler) Callback(c fiber.Ctx) error {
 	state := c.Query("state")
 
 	if code == "" || state == "" {
+		recordFailure(nil, h.App, "", "Missing authorization code or state")
 		return c.Render("login", fiber.Map{
 			"AppType":        "dmp",
 			"Message":        "Missing authorization code or state",
@@ -198,6 +203,7 @@ func (h *DMPHandler) Callback(c fiber.Ctx) error {
 	sess, err := h.Store.Get(c)
 	if err != nil {
 		log.Printf("Failed to get session: %v", err)
+		recordFailure(nil, h.App, "", "Session error")
 		return c.Render("login", fiber.Map{
 			"AppType":        "dmp",
 			"Message":        "Session error",
@@ -213,6 +219,7 @@ func (h *DMPHandler) Callback(c fiber.Ctx) error {
 	username := sess.Get("username")
 
 	if savedState == nil || username == nil {
+		recordFailure(sess, h.App, "", "No saved state")
 		return c.Render("login", fiber.Map{
 			"AppType":        "dmp",
 			"Message":        "No saved state, please login again",
@@ -226,6 +233,7 @@ func (h *DMPHandler) Callback(c fiber.Ctx) error {
 
 	// Verify state matches
 	if state != savedState.(string) {
+		recordFailure(sess, h.App, username.(string), "Duo state does not match saved state")
 		return c.Render("login", fiber.Map{
 			"AppType":        "dmp",
 			"Message":        "Duo state does not match saved state",
@@ -241,6 +249,7 @@ func (h *DMPHandler) Callback(c fiber.Ctx) error {
 	decodedToken, err := h.DuoClient.ExchangeAuthorizationCodeFor2faResult(code, username.(string))
 	if err != nil {
 		log.Printf("Failed to exchange code: %v", err)
+		recordFailure(sess, h.App, username.(string), fmt.Sprintf("Failed to exchange code: %v", err))
 		return c.Render("login", fiber.Map{
 			"AppType":        "dmp",
 			"Message":        "Error decoding Duo result. Confirm device clock is correct.",
@@ -258,6 +267,15 @@ func (h *DMPHandler) Callback(c fiber.Ctx) error {
 		tokenJSON = []byte(fmt.Sprintf("%+v", decodedToken))
 	}
 
+	recordAttempt(sess, h.App, history.Attempt{
+		Username: username.(string),
+		Factor:   decodedToken.AuthContext.Factor,
+		Success:  decodedToken.AuthResult.Result == "allow",
+		Result:   decodedToken.AuthResult.Result,
+		Error:    resultError(decodedToken.AuthResult.Result, decodedToken.AuthResult.StatusMsg),
+		Details:  string(tokenJSON),
+	})
+
 	// Clean up session
 	sess.Delete("state")
 	sess.Delete("username")
@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"user_experience_toolkit/internal/config"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/session"
+)
+
+// Flow serves the /app/:id/* requests of one application type. path is the part of
+// the URL after /app/:id/.
+type Flow func(c fiber.Ctx, app *config.Application, path string, store *session.Store) error
+
+var (
+	flowsMu sync.RWMutex
+	flows   = map[string]Flow{
+		"websdk":   serveV4,
+		"dmp":      serveDMP,
+		"saml":     serveSAML,
+		"oidc":     serveOIDC,
+		"authapi":  serveAuthAPI,
+		"websdkv2": serveWebSDKV2,
+	}
+)
+
+// idpCallbackPaths are the endpoints under /app/:id/ that receive cross-site posts
+// from the IdP and so cannot carry a CSRF token
+var idpCallbackPaths = map[string]bool{
+	"saml/acs": true, // SAML assertion consumer service
+	"saml/slo": true, // SAML single logout
+	"callback": true, // Web SDK callbacks
+}
+
+// SkipCSRF reports whether CSRF protection is skipped for a request: only the
+// state-changing requests to the IdP callbacks above. Every other post, including the
+// login and factor forms of the demo applications, needs the token, and safe requests
+// are not skipped, so application pages get the CSRF cookie.
+func SkipCSRF(c fiber.Ctx) bool {
+	rest, ok := strings.CutPrefix(c.Path(), "/app/")
+	if !ok {
+		return false
+	}
+	switch c.Method() {
+	case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions, fiber.MethodTrace:
+		return false
+	}
+
+	// rest is ":id/<path>"
+	_, path, _ := strings.Cut(rest, "/")
+	return idpCallbackPaths[strings.Trim(path, "/")]
+}
+
+// RegisterFlow sets the flow of an application type registered with
+// config.RegisterApplicationType
+func RegisterFlow(appType string, flow Flow) error {
+	if _, ok := config.LookupApplicationType(appType); !ok {
+		return fmt.Errorf("application type %s is not registered", appType)
+	}
+
+	flowsMu.Lock()
+	defer flowsMu.Unlock()
+	flows[appType] = flow
+	return nil
+}
+
+// ServeApplication routes a request for app to the flow of its type
+func ServeApplication(c fiber.Ctx, app *config.Application, path string, store *session.Store) error {
+	flowsMu.RLock()
+	flow, ok := flows[app.GetApplicationType()]
+	flowsMu.RUnlock()
+
+	if !ok {
+		log.Printf("No flow registered for application type %s", app.GetApplicationType())
+		return c.Status(fiber.StatusNotFound).SendString("Application type not supported")
+	}
+	return flow(c, app, path, store)
+}
+
+// serveV4 handles requests for WebSDK (Universal Prompt) applications
+func serveV4(c fiber.Ctx, app *config.Application, path string, store *session.Store) error {
+	// Get base URL for redirect URI generation
+	baseURL := c.BaseURL()
+
+	handler, err := NewV4HandlerFromApp(app, store, baseURL)
+	if err != nil {
+		log.Printf("Failed to create V4 handler: %v", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to initialize V4 handler")
+	}
+
+	switch {
+	case path == "" || path == "/":
+		if c.Method() == "GET" {
+			return handler.Login(c)
+		} else if c.Method() == "POST" {
+			return handler.ProcessLogin(c)
+		}
+	case path == "callback":
+		return handler.Callback(c)
+	}
+
+	return c.Status(fiber.StatusNotFound).SendString("Not found")
+}
+
+// serveDMP handles requests for DMP applications
+func serveDMP(c fiber.Ctx, app *config.Application, path string, store *session.Store) error {
+	// Get base URL for redirect URI generation
+	baseURL := c.BaseURL()
+
+	handler, err := NewDMPHandlerFromApp(app, store, baseURL)
+	if err != nil {
+		log.Printf("Failed to create DMP handler: %v", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to initialize DMP handler")
+	}
+
+	switch {
+	case path == "" || path == "/":
+		if c.Method() == "GET" {
+			return handler.Login(c)
+		} else if c.Method() == "POST" {
+			return handler.ProcessLogin(c)
+		}
+	case path == "callback":
+		return handler.Callback(c)
+	}
+
+	return c.Status(fiber.StatusNotFound).SendString("Not found")
+}
+
+// serveSAML handles requests for SAML applications
+func serveSAML(c fiber.Ctx, app *config.Application, path string, store *session.Store) error {
+	// Get base URL for redirect URI generation
+	baseURL := c.BaseURL()
+
+	handler, err := NewSAMLHandlerFromApp(app, store, baseURL)
+	if err != nil {
+		log.Printf("Failed to create SAML handler: %v", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to initialize SAML handler")
+	}
+
+	switch {
+	case path == "" || path == "/" || path == "saml" || path == "saml/":
+		return handler.Login(c)
+	case path == "saml/initiate":
+		return handler.InitiateSAML(c)
+	case path == "saml/acs":
+		if c.Method() == "POST" {
+			return handler.ACS(c)
+		}
+	case path == "saml/metadata":
+		return handler.Metadata(c)
+	case path == "saml/slo":
+		return handler.SLO(c)
+	case path == "saml/success":
+		return handler.Success(c)
+	case path == "saml/trace":
+		return handler.Trace(c)
+	}
+
+	return c.Status(fiber.StatusNotFound).SendString("Not found")
+}
+
+// serveOIDC handles requests for OIDC applications
+func serveOIDC(c fiber.Ctx, app *config.Application, path string, store *session.Store) error {
+	// Get base URL for redirect URI generation
+	baseURL := c.BaseURL()
+
+	handler, err := NewOIDCHandlerFromApp(app, store, baseURL)
+	if err != nil {
+		log.Printf("Failed to create OIDC handler: %v", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to initialize OIDC handler")
+	}
+
+	switch {
+	case path == "" || path == "/" || path == "oidc" || path == "oidc/":
+		return handler.Login(c)
+	case path == "oidc/initiate":
+		return handler.InitiateOIDC(c)
+	case path == "oidc/callback":
+		return handler.Callback(c)
+	case path == "oidc/success":
+		return handler.Success(c)
+	case path == "oidc/refresh" && c.Method() == fiber.MethodPost:
+		return handler.RefreshTokens(c)
+	case path == "oidc/introspect" && c.Method() == fiber.MethodPost:
+		return handler.IntrospectToken(c)
+	case path == "oidc/userinfo" && c.Method() == fiber.MethodPost:
+		return handler.RefetchUserInfo(c)
+	case path == "oidc/logout":
+		return handler.Logout(c)
+	case path == "oidc/logout/callback":
+		return handler.LogoutCallback(c)
+	}
+
+	return c.Status(fiber.StatusNotFound).SendString("Not found")
+}
+
+// serveAuthAPI handles requests for Auth API applications
+func serveAuthAPI(c fiber.Ctx, app *config.Application, path string, store *session.Store) error {
+	handler, err := NewAuthAPIHandlerFromApp(app, store)
+	if err != nil {
+		log.Printf("Failed to create Auth API handler: %v", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to initialize Auth API handler")
+	}
+
+	switch {
+	case path == "" || path == "/":
+		if c.Method() == "GET" {
+			return handler.Login(c)
+		} else if c.Method() == "POST" {
+			return handler.ProcessLogin(c)
+		}
+	case path == "auth" && c.Method() == "POST":
+		return handler.Auth(c)
+	case path == "status":
+		return handler.Status(c)
+	case path == "complete":
+		return handler.Complete(c)
+	}
+
+	return c.Status(fiber.StatusNotFound).SendString("Not found")
+}
+
+// serveWebSDKV2 handles requests for Web SDK v2 (iframe) applications
+func serveWebSDKV2(c fiber.Ctx, app *config.Application, path string, store *session.Store) error {
+	handler, err := NewWebSDKV2HandlerFromApp(app, store)
+	if err != nil {
+		log.Printf("Failed to create Web SDK v2 handler: %v", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to initialize Web SDK v2 handler")
+	}
+
+	switch {
+	case path == "" || path == "/":
+		if c.Method() == "GET" {
+			return handler.Login(c)
+		} else if c.Method() == "POST" {
+			return handler.ProcessLogin(c)
+		}
+	case path == "callback" && c.Method() == "POST":
+		return handler.Callback(c)
+	}
+
+	return c.Status(fiber.StatusNotFound).SendString("Not found")
+}
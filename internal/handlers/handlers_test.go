@@ -339,3 +339,41 @@ func TestAddTenantRequest(t *testing.T) {
 		t.Errorf("AddTenantRequest.APIHostname = %v, want api-test.duosecurity.com", req.APIHostname)
 	}
 }
+
+func TestFlowsCoverApplicationTypes(t *testing.T) {
+	for _, appType := range config.ApplicationTypes() {
+		if _, ok := flows[appType.ID]; !ok {
+			t.Errorf("application type %s has no flow", appType.ID)
+		}
+	}
+
+	if err := RegisterFlow("unregistered", serveV4); err == nil {
+		t.Error("RegisterFlow() should reject a type that is not registered in config")
+	}
+}
+
+func TestNewWebSDKV2HandlerFromApp(t *testing.T) {
+	app := &config.Application{
+		ID:           "test-app",
+		Type:         "websdkv2",
+		ClientID:     "DIXXXXXXXXXXXXXXXXXX",
+		ClientSecret: "deadbeefdeadbeefdeadbeefdeadbeefdeadbeef",
+	}
+	handler, err := NewWebSDKV2HandlerFromApp(app, nil)
+	if err != nil {
+		t.Fatalf("NewWebSDKV2HandlerFromApp() error = %v", err)
+	}
+	if len(handler.applicationKey) < 40 {
+		t.Errorf("application key is %d characters, Web SDK v2 requires at least 40", len(handler.applicationKey))
+	}
+
+	rotated := *app
+	rotated.ClientSecret = "feedfacefeedfacefeedfacefeedfacefeedface"
+	if webSDKV2ApplicationKey(&rotated) == handler.applicationKey {
+		t.Error("application key should change with the client secret")
+	}
+
+	if _, err := NewWebSDKV2HandlerFromApp(&config.Application{Type: "websdk"}, nil); err == nil {
+		t.Error("NewWebSDKV2HandlerFromApp() should reject a websdk app")
+	}
+}
@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+	"user_experience_toolkit/internal/config"
+	"user_experience_toolkit/internal/history"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/session"
+)
+
+// authHistory records the outcome of every login. Handlers are created per request,
+// so the store is shared here; attempts are not recorded while it is nil.
+var authHistory *history.Store
+
+// SetHistoryStore sets where the demo applications record their login attempts
+func SetHistoryStore(store *history.Store) {
+	authHistory = store
+}
+
+// historyStartedKey prefixes the session key holding when a login of an app started
+const historyStartedKey = "history_started_"
+
+// markAttemptStarted remembers when a login started so its duration can be recorded.
+// It is saved together with the rest of the session by the caller.
+func markAttemptStarted(sess *session.Session, app *config.Application) {
+	sess.Set(historyStartedKey+app.ID, time.Now().UnixMilli())
+}
+
+// recordAttempt fills in the application and duration of attempt and stores it.
+// sess may be nil when the login has no session, e.g. a request without cookies.
+func recordAttempt(sess *session.Session, app *config.Application, attempt history.Attempt) {
+	if authHistory == nil {
+		return
+	}
+
+	attempt.Time = time.Now()
+	attempt.AppID = app.ID
+	attempt.AppName = app.Name
+	attempt.AppType = app.GetApplicationType()
+	attempt.TenantID = app.TenantID
+	if sess != nil {
+		if started, ok := sess.Get(historyStartedKey + app.ID).(int64); ok {
+			attempt.DurationMS = attempt.Time.UnixMilli() - started
+			sess.Delete(historyStartedKey + app.ID)
+			if err := sess.Save(); err != nil {
+				log.Printf("[History] Failed to save session: %v", err)
+			}
+		}
+	}
+
+	if err := authHistory.Record(&attempt); err != nil {
+		log.Printf("[History] Failed to record attempt for app %s: %v", app.ID, err)
+	}
+}
+
+// recordFailure records a failed login with the reason shown to the user
+func recordFailure(sess *session.Session, app *config.Application, username, reason string) {
+	recordAttempt(sess, app, history.Attempt{Username: username, Error: reason})
+}
+
+// resultError explains a Duo result other than allow, for attempts that completed
+// the protocol but were not allowed
+func resultError(result, statusMsg string) string {
+	if result == "allow" {
+		return ""
+	}
+	if statusMsg != "" {
+		return statusMsg
+	}
+	return fmt.Sprintf("Duo result: %s", result)
+}
+
+// sessionString returns a string value of sess, or "" when sess is nil or has no such value
+func sessionString(sess *session.Session, key string) string {
+	if sess == nil {
+		return ""
+	}
+	value, _ := sess.Get(key).(string)
+	return value
+}
+
+// HistoryHandler serves the authentication history page and API
+type HistoryHandler struct {
+	Config *config.Config
+	Store  *history.Store // nil when history is disabled
+}
+
+// NewHistoryHandler creates a new history handler
+func NewHistoryHandler(cfg *config.Config, store *history.Store) *HistoryHandler {
+	return &HistoryHandler{Config: cfg, Store: store}
+}
+
+// Show renders the history page; the attempts are loaded from the JSON API
+func (h *HistoryHandler) Show(c fiber.Ctx) error {
+	adminUser, _ := c.Locals(adminUserKey).(string)
+
+	return c.Render("history", fiber.Map{
+		"Enabled":          h.Store != nil,
+		"Applications":     config.RedactApplications(h.Config.GetAllApplications()),
+		"ApplicationTypes": config.ApplicationTypes(),
+		"AdminUser":        adminUser,
+	})
+}
+
+// List returns the attempts matching the query filters, newest first, without details
+func (h *HistoryHandler) List(c fiber.Ctx) error {
+	attempts, status, err := h.query(c)
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+	for i := range attempts {
+		attempts[i].Details = ""
+	}
+	return c.JSON(fiber.Map{"attempts": attempts})
+}
+
+// Get returns one attempt with its decoded token or assertion
+func (h *HistoryHandler) Get(c fiber.Ctx) error {
+	if h.Store == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Authentication history is disabled"})
+	}
+
+	attempt, err := h.Store.Get(c.Params("id"))
+	if errors.Is(err, history.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Attempt not found"})
+	}
+	if err != nil {
+		log.Printf("[History] Failed to read attempt: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to read history"})
+	}
+	return c.JSON(attempt)
+}
+
+// Export downloads the attempts matching the query filters as JSON (with details) or CSV
+func (h *HistoryHandler) Export(c fiber.Ctx) error {
+	attempts, status, err := h.query(c)
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	filename := "uet-history-" + time.Now().UTC().Format("20060102-150405")
+	switch format := c.Query("format", "json"); format {
+	case "json":
+		c.Attachment(filename + ".json")
+		return c.JSON(attempts)
+	case "csv":
+		c.Attachment(filename + ".csv")
+		c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+		return history.WriteCSV(c.Response().BodyWriter(), attempts)
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid format: %s (must be json or csv)", format)})
+	}
+}
+
+// Clear deletes every recorded attempt
+func (h *HistoryHandler) Clear(c fiber.Ctx) error {
+	if h.Store == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Authentication history is disabled"})
+	}
+	if err := h.Store.Clear(); err != nil {
+		log.Printf("[History] Failed to clear history: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to clear history"})
+	}
+	log.Printf("[Audit] %s cleared the authentication history from %s", adminIdentity(c), c.IP())
+	return c.JSON(fiber.Map{"message": "History cleared"})
+}
+
+// query lists the attempts selected by the request's filter parameters
+func (h *HistoryHandler) query(c fiber.Ctx) ([]history.Attempt, int, error) {
+	if h.Store == nil {
+		return nil, fiber.StatusNotFound, errors.New("Authentication history is disabled")
+	}
+
+	filter, err := parseHistoryFilter(c)
+	if err != nil {
+		return nil, fiber.StatusBadRequest, err
+	}
+	attempts, err := h.Store.List(filter)
+	if err != nil {
+		log.Printf("[History] Failed to list attempts: %v", err)
+		return nil, fiber.StatusInternalServerError, errors.New("Failed to read history")
+	}
+	return attempts, fiber.StatusOK, nil
+}
+
+// parseHistoryFilter reads app_id, type, username, status, since, until and limit
+func parseHistoryFilter(c fiber.Ctx) (history.Filter, error) {
+	filter := history.Filter{
+		AppID:    c.Query("app_id"),
+		AppType:  c.Query("type"),
+		Username: c.Query("username"),
+		Status:   c.Query("status"),
+	}
+
+	var err error
+	if filter.Since, err = parseHistoryTime(c.Query("since"), false); err != nil {
+		return filter, fmt.Errorf("invalid since: %v", err)
+	}
+	if filter.Until, err = parseHistoryTime(c.Query("until"), true); err != nil {
+		return filter, fmt.Errorf("invalid until: %v", err)
+	}
+	if limit := c.Query("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil {
+			return filter, fmt.Errorf("invalid limit: %q", limit)
+		}
+	}
+
+	return filter, filter.Validate()
+}
+
+// parseHistoryTime accepts RFC 3339 timestamps or dates. A date used as the end of a
+// range includes the whole day.
+func parseHistoryTime(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	day, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a date or RFC 3339 time", value)
+	}
+	if endOfDay {
+		return day.Add(24*time.Hour - time.Nanosecond), nil
+	}
+	return day, nil
+}
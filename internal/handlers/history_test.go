@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"user_experience_toolkit/internal/config"
+	"user_experience_toolkit/internal/history"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/session"
+)
+
+func openTestHistory(t *testing.T) *history.Store {
+	t.Helper()
+	store, err := history.Open(filepath.Join(t.TempDir(), "history.db"), 0)
+	if err != nil {
+		t.Fatalf("history.Open() error = %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestRecordAttempt(t *testing.T) {
+	store := openTestHistory(t)
+	SetHistoryStore(store)
+	t.Cleanup(func() { SetHistoryStore(nil) })
+
+	app := &config.Application{ID: "app1", Name: "Demo", Type: "dmp", TenantID: "t1"}
+	sessions := session.NewStore()
+
+	server := fiber.New()
+	server.Get("/start", func(c fiber.Ctx) error {
+		sess, err := sessions.Get(c)
+		if err != nil {
+			return err
+		}
+		sess.Set(historyStartedKey+app.ID, time.Now().Add(-2*time.Second).UnixMilli())
+		return sess.Save()
+	})
+	server.Get("/finish", func(c fiber.Ctx) error {
+		sess, err := sessions.Get(c)
+		if err != nil {
+			return err
+		}
+		recordAttempt(sess, app, history.Attempt{Username: "alice", Factor: "Duo Push", Success: true, Result: "allow"})
+		return nil
+	})
+
+	resp, err := server.Test(httptest.NewRequest(fiber.MethodGet, "/start", nil))
+	if err != nil {
+		t.Fatalf("server.Test() error = %v", err)
+	}
+	cookies := resp.Cookies()
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(fiber.MethodGet, "/finish", nil)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		if _, err := server.Test(req); err != nil {
+			t.Fatalf("server.Test() error = %v", err)
+		}
+	}
+
+	attempts, err := store.List(history.Filter{})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(attempts) != 2 {
+		t.Fatalf("recorded %d attempts, want 2", len(attempts))
+	}
+	first := attempts[1]
+	if first.AppName != "Demo" || first.AppType != "dmp" || first.TenantID != "t1" || first.Username != "alice" {
+		t.Errorf("attempt = %+v, want the application filled in", first)
+	}
+	if first.DurationMS < 2000 {
+		t.Errorf("DurationMS = %d, want the time since the login started", first.DurationMS)
+	}
+	if attempts[0].DurationMS != 0 {
+		t.Errorf("second attempt DurationMS = %d, want 0 once the start was used", attempts[0].DurationMS)
+	}
+
+	// Without a store nothing is recorded
+	SetHistoryStore(nil)
+	recordFailure(nil, app, "bob", "Session error")
+	if attempts, _ := store.List(history.Filter{}); len(attempts) != 2 {
+		t.Errorf("recorded %d attempts with history disabled, want 2", len(attempts))
+	}
+}
+
+func TestHistoryAPI(t *testing.T) {
+	store := openTestHistory(t)
+	for _, a := range []history.Attempt{
+		{AppID: "app1", AppType: "websdk", Username: "alice", Success: true, Result: "allow", Details: `{"sub":"alice"}`},
+		{AppID: "app2", AppType: "saml", Username: "bob", Error: "SAML assertion audience mismatch"},
+	} {
+		if err := store.Record(&a); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+
+	newApp := func(store *history.Store) *fiber.App {
+		handler := NewHistoryHandler(&config.Config{}, store)
+		app := fiber.New()
+		app.Get("/api/history", handler.List)
+		app.Get("/api/history/export", handler.Export)
+		app.Get("/api/history/:id", handler.Get)
+		app.Delete("/api/history", handler.Clear)
+		return app
+	}
+	app := newApp(store)
+
+	get := func(t *testing.T, app *fiber.App, target string) (*http.Response, string) {
+		t.Helper()
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, target, nil))
+		if err != nil {
+			t.Fatalf("app.Test() error = %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+
+	resp, body := get(t, app, "/api/history?status=failure")
+	var list struct {
+		Attempts []history.Attempt `json:"attempts"`
+	}
+	if err := json.Unmarshal([]byte(body), &list); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK || len(list.Attempts) != 1 || list.Attempts[0].Username != "bob" {
+		t.Fatalf("List(status=failure) = %d %s, want bob's attempt", resp.StatusCode, body)
+	}
+
+	resp, body = get(t, app, "/api/history?username=alice")
+	if strings.Contains(body, `"details"`) {
+		t.Errorf("List() = %s, want the details left out", body)
+	}
+	if err := json.Unmarshal([]byte(body), &list); err != nil || len(list.Attempts) != 1 {
+		t.Fatalf("List(username=alice) = %d %s", resp.StatusCode, body)
+	}
+
+	resp, body = get(t, app, "/api/history/"+list.Attempts[0].ID)
+	if resp.StatusCode != fiber.StatusOK || !strings.Contains(body, `\"sub\":\"alice\"`) {
+		t.Errorf("Get() = %d %s, want the attempt with details", resp.StatusCode, body)
+	}
+
+	resp, body = get(t, app, "/api/history/export?format=csv&type=saml")
+	if resp.StatusCode != fiber.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/csv") ||
+		!strings.Contains(resp.Header.Get("Content-Disposition"), ".csv") {
+		t.Errorf("Export(csv) = %d %v", resp.StatusCode, resp.Header)
+	}
+	if lines := strings.Split(strings.TrimSpace(body), "\n"); len(lines) != 2 || !strings.Contains(lines[1], "bob") {
+		t.Errorf("Export(csv) = %q, want a header and bob's attempt", body)
+	}
+
+	tests := []struct {
+		name       string
+		app        *fiber.App
+		target     string
+		wantStatus int
+	}{
+		{"unknown attempt", app, "/api/history/00", fiber.StatusNotFound},
+		{"invalid status", app, "/api/history?status=denied", fiber.StatusBadRequest},
+		{"invalid since", app, "/api/history?since=yesterday", fiber.StatusBadRequest},
+		{"invalid format", app, "/api/history/export?format=xml", fiber.StatusBadRequest},
+		{"disabled", newApp(nil), "/api/history", fiber.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if resp, body := get(t, tt.app, tt.target); resp.StatusCode != tt.wantStatus {
+				t.Errorf("GET %s = %d %s, want %d", tt.target, resp.StatusCode, body, tt.wantStatus)
+			}
+		})
+	}
+
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodDelete, "/api/history", nil))
+	if err != nil || resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("Clear() = %v, %v", resp, err)
+	}
+	if attempts, _ := store.List(history.Filter{}); len(attempts) != 0 {
+		t.Errorf("%d attempts left after Clear(), want 0", len(attempts))
+	}
+}
+
+func TestParseHistoryTime(t *testing.T) {
+	day := time.Date(2026, 3, 4, 0, 0, 0, 0, time.Local)
+
+	since, err := parseHistoryTime("2026-03-04", false)
+	if err != nil || !since.Equal(day) {
+		t.Errorf("parseHistoryTime(since) = %v, %v, want %v", since, err, day)
+	}
+	until, err := parseHistoryTime("2026-03-04", true)
+	if err != nil || !until.After(day.Add(23*time.Hour)) || !until.Before(day.Add(24*time.Hour)) {
+		t.Errorf("parseHistoryTime(until) = %v, %v, want the end of the day", until, err)
+	}
+	exact, err := parseHistoryTime("2026-03-04T10:00:00Z", true)
+	if err != nil || !exact.Equal(time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("parseHistoryTime(RFC 3339) = %v, %v", exact, err)
+	}
+}
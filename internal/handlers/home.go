@@ -29,5 +29,7 @@ func (h *HomeHandler) Index(c fiber.Ctx) error {
 		"Tenants":      tenants,
 		"HasTenants":   len(tenants) > 0,
 		"TenantMap":    tenantMap,
+		// Offered by the add application form
+		"ApplicationTypes": config.ApplicationTypes(),
 	})
 }
@@ -0,0 +1,725 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"slices"
+	"strings"
+	"user_experience_toolkit/internal/config"
+	"user_experience_toolkit/internal/drift"
+	"user_experience_toolkit/internal/duoadmin"
+	"user_experience_toolkit/internal/provision"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+)
+
+// Status of a remote integration in a deletion plan or result
+const (
+	IntegrationPending  = "pending"   // would be deleted (dry run)
+	IntegrationDeleted  = "deleted"   // removed from the Duo tenant
+	IntegrationNotFound = "not_found" // already gone from the Duo tenant
+	IntegrationSkipped  = "skipped"   // no tenant or integration key to delete with
+	IntegrationFailed   = "failed"    // the Admin API call failed
+)
+
+// IntegrationDeletion describes the Duo integration behind one application when it
+// is deleted with ?remote=true
+type IntegrationDeletion struct {
+	ApplicationID   string `json:"application_id"`
+	ApplicationName string `json:"application_name"`
+	Type            string `json:"type"`
+	IntegrationKey  string `json:"integration_key,omitempty"`
+	Status          string `json:"status"`
+	Error           string `json:"error,omitempty"`
+}
+
+// planIntegrationDeletions lists the Duo integrations behind apps. Applications that
+// are not linked to a tenant cannot be deleted through the Admin API and are skipped.
+func planIntegrationDeletions(apps []config.Application, tenant *config.Tenant) []IntegrationDeletion {
+	plan := make([]IntegrationDeletion, 0, len(apps))
+	for _, app := range apps {
+		deletion := IntegrationDeletion{
+			ApplicationID:   app.ID,
+			ApplicationName: app.Name,
+			Type:            app.GetApplicationType(),
+			IntegrationKey:  app.ClientID,
+			Status:          IntegrationPending,
+		}
+		switch {
+		case tenant == nil:
+			deletion.Status = IntegrationSkipped
+			deletion.Error = "application is not linked to a tenant"
+		case app.ClientID == "":
+			deletion.Status = IntegrationSkipped
+			deletion.Error = "application has no integration key"
+		}
+		plan = append(plan, deletion)
+	}
+	return plan
+}
+
+// deleteIntegrations carries out a deletion plan with the tenant's Admin API
+// credentials. It returns false if any integration could not be deleted.
+func deleteIntegrations(plan []IntegrationDeletion, tenant *config.Tenant) bool {
+	ok := true
+	var adminClient *duoadmin.Client
+	for i := range plan {
+		if plan[i].Status != IntegrationPending {
+			continue
+		}
+		if adminClient == nil {
+			adminClient = duoadmin.NewClient(tenant.AdminAPIKey, tenant.AdminAPISecret, tenant.APIHostname)
+		}
+
+		err := adminClient.DeleteIntegration(plan[i].IntegrationKey)
+		switch {
+		case err == nil:
+			plan[i].Status = IntegrationDeleted
+		case errors.Is(err, duoadmin.ErrIntegrationNotFound):
+			plan[i].Status = IntegrationNotFound
+		default:
+			log.Printf("[ConfigHandler] Failed to delete integration %s of application %s: %v", plan[i].IntegrationKey, plan[i].ApplicationID, err)
+			plan[i].Status = IntegrationFailed
+			plan[i].Error = err.Error()
+			ok = false
+		}
+	}
+	return ok
+}
+
+// removeDeletedApplications removes the applications whose Duo integration is gone
+// after a partially failed deletion, so none is left pointing at a deleted integration.
+// It returns the IDs of the removed applications.
+func (h *ConfigHandler) removeDeletedApplications(plan []IntegrationDeletion) []string {
+	removed := []string{}
+	for _, deletion := range plan {
+		if deletion.Status != IntegrationDeleted && deletion.Status != IntegrationNotFound {
+			continue
+		}
+		if err := h.Config.DeleteApplication(deletion.ApplicationID); err != nil {
+			log.Printf("[ConfigHandler] Failed to remove application %s after deleting its integration: %v", deletion.ApplicationID, err)
+			continue
+		}
+		removed = append(removed, deletion.ApplicationID)
+	}
+	return removed
+}
+
+// logIntegrationDeletions writes one audit line per integration removed from Duo
+func logIntegrationDeletions(c fiber.Ctx, plan []IntegrationDeletion) {
+	for _, deletion := range plan {
+		if deletion.Status == IntegrationDeleted {
+			log.Printf("[Audit] %s deleted Duo integration %s of application %s (%s) from %s", adminIdentity(c), deletion.IntegrationKey, deletion.ApplicationID, deletion.ApplicationName, c.IP())
+		}
+	}
+}
+
+// importableTypes maps the Duo integration types that can be imported to application types
+var importableTypes = map[string]string{
+	"websdk":                   "websdk",
+	"device-management-portal": "dmp",
+	"sso-generic":              "saml",
+	"sso-oidc-generic":         "oidc",
+	"authapi":                  "authapi",
+}
+
+// ImportableIntegration is an integration of a tenant that can be turned into an application
+type ImportableIntegration struct {
+	IntegrationKey string `json:"integration_key"`
+	Name           string `json:"name"`
+	DuoType        string `json:"duo_type"`
+	Type           string `json:"type"`
+	ApplicationID  string `json:"application_id,omitempty"` // set when the integration is already imported
+}
+
+// ImportIntegrationsRequest represents the request body for importing integrations
+type ImportIntegrationsRequest struct {
+	IntegrationKeys []string `json:"integration_keys"`
+	Enabled         bool     `json:"enabled"`
+}
+
+// IntegrationImport is the outcome of importing one integration
+type IntegrationImport struct {
+	IntegrationKey string   `json:"integration_key"`
+	Name           string   `json:"name,omitempty"`
+	Type           string   `json:"type,omitempty"`
+	ApplicationID  string   `json:"application_id,omitempty"`
+	Status         string   `json:"status"` // "imported", "skipped" or "failed"
+	Error          string   `json:"error,omitempty"`
+	Warnings       []string `json:"warnings,omitempty"`
+}
+
+// ListTenantIntegrations lists the WebSDK, DMP, SAML and OIDC integrations of a tenant
+// that can be imported as applications
+func (h *ConfigHandler) ListTenantIntegrations(c fiber.Ctx) error {
+	tenant, err := h.Config.GetTenant(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	adminClient := duoadmin.NewClient(tenant.AdminAPIKey, tenant.AdminAPISecret, tenant.APIHostname)
+	integrations, err := adminClient.ListIntegrations()
+	if err != nil {
+		log.Printf("[ConfigHandler] Failed to list integrations of tenant %s: %v", tenant.ID, err)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error": "Failed to list integrations via Duo Admin API: " + err.Error(),
+		})
+	}
+
+	imported := importedIntegrations(h.Config.GetApplicationsByTenant(tenant.ID))
+
+	importable := []ImportableIntegration{}
+	for _, integration := range integrations {
+		appType, ok := importableTypes[integration.Type]
+		if !ok {
+			continue
+		}
+		importable = append(importable, ImportableIntegration{
+			IntegrationKey: integration.IntegrationKey,
+			Name:           integration.Name,
+			DuoType:        integration.Type,
+			Type:           appType,
+			ApplicationID:  imported[integration.IntegrationKey],
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"integrations": importable,
+	})
+}
+
+// ImportTenantIntegrations creates an application for each selected integration of a
+// tenant, filling credentials and IdP metadata from the Admin API
+func (h *ConfigHandler) ImportTenantIntegrations(c fiber.Ctx) error {
+	tenant, err := h.Config.GetTenant(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var req ImportIntegrationsRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if len(req.IntegrationKeys) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Select at least one integration to import",
+		})
+	}
+
+	adminClient := duoadmin.NewClient(tenant.AdminAPIKey, tenant.AdminAPISecret, tenant.APIHostname)
+	baseURL := c.BaseURL()
+
+	results := make([]IntegrationImport, 0, len(req.IntegrationKeys))
+	count := 0
+	for _, integrationKey := range req.IntegrationKeys {
+		result := IntegrationImport{IntegrationKey: integrationKey}
+
+		if appID, ok := importedIntegrations(h.Config.GetApplicationsByTenant(tenant.ID))[integrationKey]; ok {
+			result.Status = "skipped"
+			result.ApplicationID = appID
+			result.Error = "integration is already imported"
+			results = append(results, result)
+			continue
+		}
+
+		app, warnings, err := h.buildImportedApplication(adminClient, tenant, integrationKey, baseURL)
+		if err == nil {
+			app.Enabled = req.Enabled
+			err = h.Config.AddApplication(*app)
+		}
+		if err != nil {
+			log.Printf("[ConfigHandler] Failed to import integration %s of tenant %s: %v", integrationKey, tenant.ID, err)
+			result.Status = "failed"
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+
+		log.Printf("[ConfigHandler] Imported integration %s as %s application %s", integrationKey, app.Type, app.ID)
+		result.Status = "imported"
+		result.Name = app.Name
+		result.Type = app.Type
+		result.ApplicationID = app.ID
+		result.Warnings = warnings
+		results = append(results, result)
+		count++
+	}
+
+	status := fiber.StatusOK
+	if count == 0 {
+		status = fiber.StatusBadRequest
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"message": fmt.Sprintf("Imported %d of %d integrations", count, len(req.IntegrationKeys)),
+		"results": results,
+	})
+}
+
+// buildImportedApplication fetches an integration and turns it into an application of
+// the tenant. The returned warnings point out settings that do not match this toolkit.
+func (h *ConfigHandler) buildImportedApplication(adminClient *duoadmin.Client, tenant *config.Tenant, integrationKey, baseURL string) (*config.Application, []string, error) {
+	integration, err := adminClient.GetIntegration(integrationKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	appType, ok := importableTypes[integration.Type]
+	if !ok {
+		return nil, nil, fmt.Errorf("integrations of type %s cannot be imported", integration.Type)
+	}
+
+	app := &config.Application{
+		TenantID:    tenant.ID,
+		Name:        integration.Name,
+		Type:        appType,
+		ClientID:    integration.IntegrationKey,
+		APIHostname: tenant.APIHostname,
+	}
+	var warnings []string
+
+	switch appType {
+	case "websdk", "dmp", "authapi":
+		app.ID = uuid.New().String()
+		app.ClientSecret = integration.SecretKey
+
+	case "saml":
+		samlIntegration, err := adminClient.GetSAMLIntegration(integrationKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		acsURLs := samlIntegration.ACSURLs()
+		if len(acsURLs) == 0 {
+			return nil, nil, fmt.Errorf("SAML integration has no ACS URL")
+		}
+
+		app.ACSURL = acsURLs[0]
+		app.ID = h.importedApplicationID(acsURLs, "saml/acs")
+		for _, acsURL := range acsURLs {
+			if appIDFromURL(acsURL, "saml/acs") == app.ID {
+				app.ACSURL = acsURL
+			}
+		}
+		app.EntityID = samlIntegration.SSO.SAMLConfig.EntityID
+		app.MetadataURL = fmt.Sprintf("%s/app/%s/saml/metadata", baseURL, app.ID)
+		app.IDPEntityID = samlIntegration.SSO.IDPMetadata.EntityID
+		app.IDPSSOURL = samlIntegration.SSO.IDPMetadata.SSOURL
+		app.IDPSLOURL = samlIntegration.SSO.IDPMetadata.SLOURL
+		app.IDPCertificate = samlIntegration.SSO.IDPMetadata.Cert
+
+		if want := fmt.Sprintf("%s/app/%s/saml/acs", baseURL, app.ID); app.ACSURL != want {
+			warnings = append(warnings, fmt.Sprintf("Duo sends assertions to %s; set the ACS URL of the integration to %s to sign in through this toolkit", app.ACSURL, want))
+		}
+
+	case "oidc":
+		oidcIntegration, err := adminClient.GetOIDCIntegration(integrationKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		redirectURIs := oidcIntegration.SSO.OIDCConfig.RedirectURIs
+		if len(redirectURIs) == 0 {
+			return nil, nil, fmt.Errorf("OIDC integration has no redirect URI")
+		}
+
+		app.ID = h.importedApplicationID(redirectURIs, "oidc/callback")
+		app.RedirectURI = fmt.Sprintf("%s/app/%s/oidc/callback", baseURL, app.ID)
+		registered := false
+		for _, redirectURI := range redirectURIs {
+			if redirectURI == app.RedirectURI {
+				registered = true
+			}
+		}
+		if !registered {
+			warnings = append(warnings, fmt.Sprintf("Add %s to the redirect URIs of the integration to sign in through this toolkit", app.RedirectURI))
+		}
+
+		metadata := oidcIntegration.SSO.IDPMetadata
+		if metadata.ClientID != "" {
+			app.ClientID = metadata.ClientID
+		}
+		app.ClientSecret = metadata.ClientSecret
+		app.IDPDiscoveryURL = metadata.DiscoveryURL
+		app.IDPIssuer = metadata.Issuer
+		app.IDPAuthorizationEndpoint = metadata.AuthorizeEndpointURL
+		app.IDPTokenEndpoint = metadata.TokenEndpointURL
+		app.IDPUserInfoEndpoint = metadata.UserInfoEndpointURL
+		app.IDPJWKSEndpoint = metadata.JWKSEndpointURL
+		app.IDPIntrospectionEndpoint = metadata.TokenIntrospectionEndpointURL
+
+		for _, scope := range oidcIntegration.SSO.OIDCConfig.Scopes {
+			if scope.Name != "" && scope.Name != "openid" {
+				app.OIDCScopes = append(app.OIDCScopes, scope.Name)
+			}
+		}
+		if oidcIntegration.SSO.OIDCConfig.GrantTypes.AuthorizationCode.AllowPKCEOnly {
+			app.PKCEMode = config.PKCEModePublic
+		}
+	}
+
+	return app, warnings, nil
+}
+
+// importedApplicationID reuses the application ID embedded in one of the integration's
+// callback URLs, e.g. when it was created by another instance of this toolkit, and
+// falls back to a new ID
+func (h *ConfigHandler) importedApplicationID(urls []string, suffix string) string {
+	for _, u := range urls {
+		if id := appIDFromURL(u, suffix); id != "" {
+			if _, err := h.Config.GetApplication(id); err != nil {
+				return id
+			}
+		}
+	}
+	return uuid.New().String()
+}
+
+// appIDFromURL extracts {id} from a URL whose path is /app/{id}/{suffix}
+func appIDFromURL(rawURL, suffix string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	rest, ok := strings.CutPrefix(parsed.Path, "/app/")
+	if !ok {
+		return ""
+	}
+	id, ok := strings.CutSuffix(rest, "/"+suffix)
+	if !ok || id == "" || strings.Contains(id, "/") {
+		return ""
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return ""
+	}
+	return id
+}
+
+// importedIntegrations maps the integration keys of apps to their application IDs
+func importedIntegrations(apps []config.Application) map[string]string {
+	imported := make(map[string]string, len(apps))
+	for _, app := range apps {
+		if app.ClientID != "" {
+			imported[app.ClientID] = app.ID
+		}
+	}
+	return imported
+}
+
+// CheckDrift compares every application, or those of ?tenant_id=, with its Duo integration
+func (h *ConfigHandler) CheckDrift(c fiber.Ctx) error {
+	tenantID := c.Query("tenant_id")
+	if tenantID != "" {
+		if _, err := h.Config.GetTenant(tenantID); err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+	}
+
+	reports := drift.NewChecker(h.Config).Check(tenantID)
+	log.Printf("[ConfigHandler] Drift check: %s", drift.Summary(reports))
+
+	return c.JSON(fiber.Map{
+		"summary": drift.Summary(reports),
+		"reports": reports,
+	})
+}
+
+// PushDrift sends the local entity ID, ACS URL or redirect URI of an application to
+// its Duo integration
+func (h *ConfigHandler) PushDrift(c fiber.Ctx) error {
+	id := c.Params("id")
+	if _, err := h.Config.GetApplication(id); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	report, err := drift.NewChecker(h.Config).Push(id)
+	if err != nil {
+		log.Printf("[ConfigHandler] Failed to push application %s: %v", id, err)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error":  err.Error(),
+			"report": report,
+		})
+	}
+	if !report.Pushed {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":  "Nothing to push, the settings in Duo already match or cannot be pushed",
+			"report": report,
+		})
+	}
+
+	log.Printf("[Audit] %s pushed the settings of application %s to Duo integration %s from %s", adminIdentity(c), id, report.IntegrationKey, c.IP())
+
+	return c.JSON(fiber.Map{
+		"message": "Local settings pushed to Duo",
+		"report":  report,
+	})
+}
+
+// IntegrationSettings are the settings of an application's Duo integration that can be
+// changed from /configure. Only the section matching the application type is used.
+type IntegrationSettings struct {
+	Name string                   `json:"name"`
+	SAML *SAMLIntegrationSettings `json:"saml,omitempty"`
+	OIDC *OIDCIntegrationSettings `json:"oidc,omitempty"`
+}
+
+// SAMLIntegrationSettings are the NameID settings of a SAML integration
+type SAMLIntegrationSettings struct {
+	NameIDFormat    string `json:"nameid_format"`
+	NameIDAttribute string `json:"nameid_attribute"`
+}
+
+// OIDCIntegrationSettings are the scopes, redirect URIs and token settings of an OIDC
+// integration. Lifespans are in seconds.
+type OIDCIntegrationSettings struct {
+	RedirectURIs               []string `json:"redirect_uris"`
+	Scopes                     []string `json:"scopes"`
+	AccessTokenLifespan        int      `json:"access_token_lifespan"`
+	AllowPKCEOnly              bool     `json:"allow_pkce_only"`
+	EnableRefreshToken         bool     `json:"enable_refresh_token"`
+	RefreshTokenChainLifespan  int      `json:"refresh_token_chain_lifespan,omitempty"`
+	RefreshTokenSingleLifespan int      `json:"refresh_token_single_lifespan,omitempty"`
+}
+
+// integrationApplication returns the application of :id and its tenant, or the HTTP
+// status and error to report when it is not linked to a tenant integration
+func (h *ConfigHandler) integrationApplication(c fiber.Ctx) (*config.Application, *config.Tenant, int, error) {
+	app, err := h.Config.GetApplication(c.Params("id"))
+	if err != nil {
+		return nil, nil, fiber.StatusNotFound, err
+	}
+	if app.TenantID == "" || app.ClientID == "" {
+		return nil, nil, fiber.StatusBadRequest, fmt.Errorf("Application is not linked to a tenant integration")
+	}
+	tenant, err := h.Config.GetTenant(app.TenantID)
+	if err != nil {
+		return nil, nil, fiber.StatusBadRequest, err
+	}
+	return app, tenant, fiber.StatusOK, nil
+}
+
+// GetIntegrationSettings reads the current settings of an application's Duo integration
+func (h *ConfigHandler) GetIntegrationSettings(c fiber.Ctx) error {
+	app, tenant, status, err := h.integrationApplication(c)
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	adminClient := duoadmin.NewClient(tenant.AdminAPIKey, tenant.AdminAPISecret, tenant.APIHostname)
+	settings, err := fetchIntegrationSettings(adminClient, app)
+	if err != nil {
+		log.Printf("[ConfigHandler] Failed to read integration %s of application %s: %v", app.ClientID, app.ID, err)
+		status = fiber.StatusBadGateway
+		if errors.Is(err, duoadmin.ErrIntegrationNotFound) {
+			status = fiber.StatusNotFound
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error": "Failed to read integration via Duo Admin API: " + err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"type":            app.GetApplicationType(),
+		"integration_key": app.ClientID,
+		"settings":        settings,
+	})
+}
+
+// fetchIntegrationSettings reads the settings of app's integration from the Admin API
+func fetchIntegrationSettings(adminClient *duoadmin.Client, app *config.Application) (*IntegrationSettings, error) {
+	switch app.GetApplicationType() {
+	case "saml":
+		integration, err := adminClient.GetSAMLIntegration(app.ClientID)
+		if err != nil {
+			return nil, err
+		}
+		samlConfig := integration.SSO.SAMLConfig
+		return &IntegrationSettings{
+			Name: integration.Name,
+			SAML: &SAMLIntegrationSettings{
+				NameIDFormat:    samlConfig.NameIDFormat,
+				NameIDAttribute: samlConfig.NameIDAttribute,
+			},
+		}, nil
+
+	case "oidc":
+		integration, err := adminClient.GetOIDCIntegration(app.ClientID)
+		if err != nil {
+			return nil, err
+		}
+		oidcConfig := integration.SSO.OIDCConfig
+		authCode := oidcConfig.GrantTypes.AuthorizationCode
+		settings := &OIDCIntegrationSettings{
+			RedirectURIs:               oidcConfig.RedirectURIs,
+			Scopes:                     []string{},
+			AccessTokenLifespan:        authCode.AccessTokenLifespan,
+			AllowPKCEOnly:              authCode.AllowPKCEOnly,
+			EnableRefreshToken:         authCode.RefreshToken.RefreshTokenChainLifespan > 0,
+			RefreshTokenChainLifespan:  authCode.RefreshToken.RefreshTokenChainLifespan,
+			RefreshTokenSingleLifespan: authCode.RefreshToken.RefreshTokenSingleLifespan,
+		}
+		for _, scope := range oidcConfig.Scopes {
+			if scope.Name != "" && scope.Name != "openid" {
+				settings.Scopes = append(settings.Scopes, scope.Name)
+			}
+		}
+		return &IntegrationSettings{Name: integration.Name, OIDC: settings}, nil
+
+	default:
+		integration, err := adminClient.GetIntegration(app.ClientID)
+		if err != nil {
+			return nil, err
+		}
+		return &IntegrationSettings{Name: integration.Name}, nil
+	}
+}
+
+// UpdateIntegrationSettings pushes new settings to an application's Duo integration and
+// then stores the matching name, OIDC scopes and PKCE mode in the local application
+func (h *ConfigHandler) UpdateIntegrationSettings(c fiber.Ctx) error {
+	app, tenant, status, err := h.integrationApplication(c)
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var settings IntegrationSettings
+	if err := c.Bind().JSON(&settings); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	normalizeIntegrationSettings(&settings)
+	updatedApp, err := applyIntegrationSettings(*app, tenant, settings)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// Duo gets the values saved locally: the tenant-prefixed name and the cleaned-up scopes
+	if settings.Name != "" {
+		settings.Name = updatedApp.Name
+	}
+
+	adminClient := duoadmin.NewClient(tenant.AdminAPIKey, tenant.AdminAPISecret, tenant.APIHostname)
+	switch app.GetApplicationType() {
+	case "saml":
+		err = adminClient.UpdateSAMLIntegration(app.ClientID, duoadmin.UpdateSAMLIntegrationParams{
+			Name:            settings.Name,
+			NameIDFormat:    settings.SAML.NameIDFormat,
+			NameIDAttribute: settings.SAML.NameIDAttribute,
+		})
+	case "oidc":
+		err = adminClient.UpdateOIDCIntegration(app.ClientID, duoadmin.UpdateOIDCIntegrationParams{
+			Name:                   settings.Name,
+			RedirectURIs:           settings.OIDC.RedirectURIs,
+			Scopes:                 updatedApp.OIDCScopes,
+			AccessTokenLifespan:    settings.OIDC.AccessTokenLifespan,
+			AllowPKCEOnly:          settings.OIDC.AllowPKCEOnly,
+			EnableRefreshToken:     settings.OIDC.EnableRefreshToken,
+			RefreshTokenChainLife:  settings.OIDC.RefreshTokenChainLifespan,
+			RefreshTokenSingleLife: settings.OIDC.RefreshTokenSingleLifespan,
+		})
+	default:
+		err = adminClient.UpdateIntegration(app.ClientID, duoadmin.UpdateIntegrationParams{
+			Name: settings.Name,
+		})
+	}
+	if err != nil {
+		log.Printf("[ConfigHandler] Failed to update integration %s of application %s: %v", app.ClientID, app.ID, err)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error": "Failed to update integration via Duo Admin API: " + err.Error(),
+		})
+	}
+
+	if err := h.Config.UpdateApplication(app.ID, updatedApp); err != nil {
+		log.Printf("[ConfigHandler] Integration %s updated but saving application %s failed: %v", app.ClientID, app.ID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Duo integration updated, but saving the application failed: " + err.Error(),
+		})
+	}
+
+	log.Printf("[Audit] %s updated the Duo integration %s of application %s from %s", adminIdentity(c), app.ClientID, app.ID, c.IP())
+
+	return c.JSON(fiber.Map{
+		"message":     "Duo integration updated",
+		"application": updatedApp.Redacted(),
+	})
+}
+
+// normalizeIntegrationSettings trims the name and redirect URIs of settings and drops
+// empty and duplicate redirect URIs
+func normalizeIntegrationSettings(settings *IntegrationSettings) {
+	settings.Name = strings.TrimSpace(settings.Name)
+	if settings.OIDC == nil {
+		return
+	}
+	var uris []string
+	for _, uri := range settings.OIDC.RedirectURIs {
+		uri = strings.TrimSpace(uri)
+		if uri != "" && !slices.Contains(uris, uri) {
+			uris = append(uris, uri)
+		}
+	}
+	settings.OIDC.RedirectURIs = uris
+}
+
+// applyIntegrationSettings validates settings for app and returns the application with
+// the local fields that mirror the integration updated. A new name gets the tenant
+// prefix, as in provision.CreateApplication.
+func applyIntegrationSettings(app config.Application, tenant *config.Tenant, settings IntegrationSettings) (config.Application, error) {
+	if settings.Name != "" {
+		app.Name = provision.ApplicationName(tenant.Name, settings.Name)
+	}
+
+	switch app.GetApplicationType() {
+	case "saml":
+		if settings.SAML == nil {
+			return app, fmt.Errorf("saml settings are required")
+		}
+
+	case "oidc":
+		oidc := settings.OIDC
+		if oidc == nil {
+			return app, fmt.Errorf("oidc settings are required")
+		}
+		if !slices.Contains(oidc.RedirectURIs, app.RedirectURI) {
+			return app, fmt.Errorf("redirect URIs must include %s, the callback of this application", app.RedirectURI)
+		}
+		if oidc.AccessTokenLifespan < 0 || oidc.RefreshTokenChainLifespan < 0 || oidc.RefreshTokenSingleLifespan < 0 {
+			return app, fmt.Errorf("token lifespans cannot be negative")
+		}
+
+		app.OIDCScopes = nil
+		for _, scope := range oidc.Scopes {
+			scope = strings.TrimSpace(scope)
+			if scope != "" && scope != "openid" && !slices.Contains(app.OIDCScopes, scope) {
+				app.OIDCScopes = append(app.OIDCScopes, scope)
+			}
+		}
+
+		// A PKCE-only integration accepts no client secret
+		switch {
+		case oidc.AllowPKCEOnly:
+			app.PKCEMode = config.PKCEModePublic
+		case app.PKCEMode == config.PKCEModePublic:
+			if app.ClientSecret == "" {
+				return app, fmt.Errorf("a client secret is required to turn off PKCE-only; set it on the application first")
+			}
+			app.PKCEMode = config.PKCEModeS256
+		}
+	}
+
+	return app, nil
+}
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"user_experience_toolkit/internal/config"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func TestPlanIntegrationDeletions(t *testing.T) {
+	tenant := &config.Tenant{ID: "tenant1"}
+	apps := []config.Application{
+		{ID: "app1", Name: "WebSDK", Type: "websdk", ClientID: "DIAAA"},
+		{ID: "app2", Name: "No key", Type: "oidc"},
+	}
+
+	plan := planIntegrationDeletions(apps, tenant)
+	if len(plan) != 2 {
+		t.Fatalf("planIntegrationDeletions() returned %d entries, want 2", len(plan))
+	}
+	if plan[0].Status != IntegrationPending || plan[0].IntegrationKey != "DIAAA" {
+		t.Errorf("plan[0] = %+v, want pending DIAAA", plan[0])
+	}
+	if plan[1].Status != IntegrationSkipped {
+		t.Errorf("plan[1].Status = %s, want skipped", plan[1].Status)
+	}
+
+	plan = planIntegrationDeletions(apps[:1], nil)
+	if plan[0].Status != IntegrationSkipped {
+		t.Errorf("application without tenant status = %s, want skipped", plan[0].Status)
+	}
+}
+
+func TestRemoveDeletedApplications(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	content := "applications:\n"
+	for _, id := range []string{"deleted", "gone", "failed"} {
+		content += `
+  - id: "` + id + `"
+    tenant_id: "tenant1"
+    name: "` + id + `"
+    type: "websdk"
+    client_id: "DIAAAAAAAAAAAAAAAAAA"
+    client_secret: "secret"
+    api_hostname: "api-test.duosecurity.com"
+`
+	}
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	handler := NewConfigHandler(cfg)
+	removed := handler.removeDeletedApplications([]IntegrationDeletion{
+		{ApplicationID: "deleted", Status: IntegrationDeleted},
+		{ApplicationID: "gone", Status: IntegrationNotFound},
+		{ApplicationID: "failed", Status: IntegrationFailed},
+	})
+
+	if !slices.Equal(removed, []string{"deleted", "gone"}) {
+		t.Errorf("removeDeletedApplications() = %v, want [deleted gone]", removed)
+	}
+	if apps := cfg.GetAllApplications(); len(apps) != 1 || apps[0].ID != "failed" {
+		t.Errorf("remaining applications = %+v, want only the one whose deletion failed", apps)
+	}
+}
+
+func TestDeleteTenantDryRun(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+tenants:
+  - id: "tenant1"
+    name: "Test Tenant"
+    admin_api_key: "key"
+    admin_api_secret: "secret"
+    api_hostname: "api-test.duosecurity.com"
+applications:
+  - id: "app1"
+    tenant_id: "tenant1"
+    name: "WebSDK App"
+    type: "websdk"
+    enabled: true
+    client_id: "DIAAAAAAAAAAAAAAAAAA"
+    client_secret: "secret"
+    api_hostname: "api-test.duosecurity.com"
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	handler := NewConfigHandler(cfg)
+	app := fiber.New()
+	app.Delete("/api/config/tenants/:id", handler.DeleteTenant)
+
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodDelete, "/api/config/tenants/tenant1?remote=true&dry_run=true", nil))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	var result struct {
+		DryRun       bool                  `json:"dry_run"`
+		Integrations []IntegrationDeletion `json:"integrations"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !result.DryRun || len(result.Integrations) != 1 || result.Integrations[0].IntegrationKey != "DIAAAAAAAAAAAAAAAAAA" {
+		t.Errorf("dry run response = %+v", result)
+	}
+
+	if _, err := cfg.GetTenant("tenant1"); err != nil {
+		t.Error("dry run deleted the tenant")
+	}
+	if len(cfg.GetApplicationsByTenant("tenant1")) != 1 {
+		t.Error("dry run deleted the applications")
+	}
+}
+
+func TestAppIDFromURL(t *testing.T) {
+	id := "0b8e0c52-6f1e-4f7a-9a53-3f0c5d5b2c11"
+	tests := []struct {
+		url    string
+		suffix string
+		want   string
+	}{
+		{"https://uet.example.com/app/" + id + "/saml/acs", "saml/acs", id},
+		{"http://localhost:8080/app/" + id + "/oidc/callback", "oidc/callback", id},
+		{"https://uet.example.com/app/" + id + "/saml/acs", "oidc/callback", ""},
+		{"https://sp.example.com/saml/acs", "saml/acs", ""},
+		{"https://uet.example.com/app/not-a-uuid/saml/acs", "saml/acs", ""},
+		{"https://uet.example.com/app/a/" + id + "/saml/acs", "saml/acs", ""},
+	}
+
+	for _, tt := range tests {
+		if got := appIDFromURL(tt.url, tt.suffix); got != tt.want {
+			t.Errorf("appIDFromURL(%q, %q) = %q, want %q", tt.url, tt.suffix, got, tt.want)
+		}
+	}
+}
+
+func TestImportedIntegrations(t *testing.T) {
+	imported := importedIntegrations([]config.Application{
+		{ID: "app1", ClientID: "DIAAA"},
+		{ID: "app2"},
+	})
+	if imported["DIAAA"] != "app1" {
+		t.Errorf("importedIntegrations()[DIAAA] = %q, want app1", imported["DIAAA"])
+	}
+	if len(imported) != 1 {
+		t.Errorf("importedIntegrations() = %v, want only applications with a client_id", imported)
+	}
+}
+
+func TestNormalizeIntegrationSettings(t *testing.T) {
+	callback := "https://uet.example.com/app/app1/oidc/callback"
+	settings := IntegrationSettings{
+		Name: "  Portal ",
+		OIDC: &OIDCIntegrationSettings{RedirectURIs: []string{" " + callback + " ", "", callback, "https://other.example.com/cb"}},
+	}
+
+	normalizeIntegrationSettings(&settings)
+
+	if settings.Name != "Portal" {
+		t.Errorf("Name = %q, want Portal", settings.Name)
+	}
+	if want := []string{callback, "https://other.example.com/cb"}; !slices.Equal(settings.OIDC.RedirectURIs, want) {
+		t.Errorf("RedirectURIs = %q, want %q", settings.OIDC.RedirectURIs, want)
+	}
+}
+
+func TestApplyIntegrationSettings(t *testing.T) {
+	callback := "https://uet.example.com/app/app1/oidc/callback"
+	app := config.Application{
+		ID:           "app1",
+		Type:         "oidc",
+		ClientID:     "DIOIDC",
+		ClientSecret: "secret",
+		RedirectURI:  callback,
+		OIDCScopes:   []string{"email"},
+	}
+	tenant := &config.Tenant{ID: "tenant1", Name: "Test Tenant"}
+
+	updated, err := applyIntegrationSettings(app, tenant, IntegrationSettings{
+		OIDC: &OIDCIntegrationSettings{
+			RedirectURIs:  []string{callback},
+			Scopes:        []string{"openid", "profile", " email ", "profile"},
+			AllowPKCEOnly: true,
+		},
+	})
+	if err != nil {
+		t.Fatalf("applyIntegrationSettings() error = %v", err)
+	}
+	if !slices.Equal(updated.OIDCScopes, []string{"profile", "email"}) {
+		t.Errorf("OIDCScopes = %v, want [profile email]", updated.OIDCScopes)
+	}
+	if updated.PKCEMode != config.PKCEModePublic {
+		t.Errorf("PKCEMode = %q, want public", updated.PKCEMode)
+	}
+
+	updated, err = applyIntegrationSettings(updated, tenant, IntegrationSettings{
+		OIDC: &OIDCIntegrationSettings{RedirectURIs: []string{callback}},
+	})
+	if err != nil {
+		t.Fatalf("applyIntegrationSettings() error = %v", err)
+	}
+	if updated.PKCEMode != config.PKCEModeS256 {
+		t.Errorf("PKCEMode after turning off PKCE-only = %q, want s256", updated.PKCEMode)
+	}
+
+	tests := []struct {
+		name     string
+		app      config.Application
+		settings IntegrationSettings
+	}{
+		{"missing callback", app, IntegrationSettings{OIDC: &OIDCIntegrationSettings{RedirectURIs: []string{"https://other.example.com/callback"}}}},
+		{"missing oidc settings", app, IntegrationSettings{Name: "OIDC"}},
+		{"negative lifespan", app, IntegrationSettings{OIDC: &OIDCIntegrationSettings{RedirectURIs: []string{callback}, AccessTokenLifespan: -1}}},
+		{"public client without secret", config.Application{Type: "oidc", RedirectURI: callback, PKCEMode: config.PKCEModePublic}, IntegrationSettings{OIDC: &OIDCIntegrationSettings{RedirectURIs: []string{callback}}}},
+		{"missing saml settings", config.Application{Type: "saml"}, IntegrationSettings{Name: "SAML"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := applyIntegrationSettings(tt.app, tenant, tt.settings); err == nil {
+				t.Error("applyIntegrationSettings() should fail")
+			}
+		})
+	}
+
+	renamed, err := applyIntegrationSettings(config.Application{Name: "Test Tenant - Portal", Type: "websdk"}, tenant, IntegrationSettings{Name: "Renamed"})
+	if err != nil {
+		t.Errorf("applyIntegrationSettings() websdk error = %v", err)
+	}
+	if renamed.Name != "Test Tenant - Renamed" {
+		t.Errorf("Name = %q, want Test Tenant - Renamed", renamed.Name)
+	}
+	unnamed, err := applyIntegrationSettings(config.Application{Name: "Test Tenant - Portal", Type: "websdk"}, tenant, IntegrationSettings{})
+	if err != nil || unnamed.Name != "Test Tenant - Portal" {
+		t.Errorf("applyIntegrationSettings() without a name = %q, %v; want the name kept", unnamed.Name, err)
+	}
+}
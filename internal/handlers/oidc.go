@@ -7,8 +7,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"net/url"
 	"time"
 	"user_experience_toolkit/internal/config"
+	"user_experience_toolkit/internal/history"
+	oidcutil "user_experience_toolkit/internal/oidc"
 
 	"github.com/coreos/go-oidc/v3/oidc"
 	"github.com/gofiber/fiber/v3"
@@ -67,7 +70,13 @@ func NewOIDCHandlerFromApp(app *config.Application, store *session.Store, baseUR
 		ClientSecret: app.ClientSecret,
 		RedirectURL:  redirectURI,
 		Endpoint:     provider.Endpoint(),
-		Scopes:       []string{oidc.ScopeOpenID}, // Only request openid scope
+		Scopes:       app.OIDCScopeList(),
+	}
+
+	// A public client proves possession of the PKCE verifier instead of the secret
+	if app.PKCEMode == config.PKCEModePublic {
+		oauth2Config.ClientSecret = ""
+		oauth2Config.Endpoint.AuthStyle = oauth2.AuthStyleInParams
 	}
 
 	// Create ID token verifier
@@ -78,6 +87,8 @@ func NewOIDCHandlerFromApp(app *config.Application, store *session.Store, baseUR
 	log.Printf("[OIDCHandler] OIDC handler initialized successfully")
 	log.Printf("[OIDCHandler] Client ID: %s", app.ClientID)
 	log.Printf("[OIDCHandler] Redirect URI: %s", redirectURI)
+	log.Printf("[OIDCHandler] Scopes: %v", oauth2Config.Scopes)
+	log.Printf("[OIDCHandler] PKCE mode: %s", pkceModeLabel(app.PKCEMode))
 
 	return &OIDCHandler{
 		App:          app,
@@ -123,6 +134,16 @@ func (h *OIDCHandler) InitiateOIDC(c fiber.Ctx) error {
 	nonce := generateRandomString(32)
 	sess.Set("oidc_nonce", nonce)
 
+	authOptions := []oauth2.AuthCodeOption{oidc.Nonce(nonce)}
+
+	// Generate PKCE code verifier; only its S256 challenge is sent to the IDP
+	if h.App.UsesPKCE() {
+		verifier := oauth2.GenerateVerifier()
+		sess.Set("oidc_code_verifier", verifier)
+		authOptions = append(authOptions, oauth2.S256ChallengeOption(verifier))
+	}
+	markAttemptStarted(sess, h.App)
+
 	if err := sess.Save(); err != nil {
 		log.Printf("[OIDCHandler] Failed to save session: %v", err)
 		return c.Status(fiber.StatusInternalServerError).SendString("Session error")
@@ -132,8 +153,8 @@ func (h *OIDCHandler) InitiateOIDC(c fiber.Ctx) error {
 	log.Printf("[OIDCHandler] Generated state: %s", state)
 	log.Printf("[OIDCHandler] Generated nonce: %s", nonce)
 
-	// Build authorization URL with nonce
-	authURL := h.OAuth2Config.AuthCodeURL(state, oidc.Nonce(nonce))
+	// Build authorization URL with nonce (and code challenge)
+	authURL := h.OAuth2Config.AuthCodeURL(state, authOptions...)
 
 	log.Printf("[OIDCHandler] Redirecting to IDP: %s", authURL)
 	return c.Redirect().To(authURL)
@@ -147,6 +168,7 @@ func (h *OIDCHandler) Callback(c fiber.Ctx) error {
 	sess, err := h.Session.Get(c)
 	if err != nil {
 		log.Printf("[OIDCHandler] Failed to get session: %v", err)
+		recordFailure(nil, h.App, "", "Session error")
 		return c.Status(fiber.StatusInternalServerError).SendString("Session error")
 	}
 
@@ -156,12 +178,14 @@ func (h *OIDCHandler) Callback(c fiber.Ctx) error {
 	savedState := sess.Get("oidc_state")
 	if savedState == nil {
 		log.Printf("[OIDCHandler] No state found in session")
+		recordFailure(sess, h.App, "", "Invalid state: no state in session")
 		return c.Status(fiber.StatusBadRequest).SendString("Invalid state: no state in session")
 	}
 
 	receivedState := c.Query("state")
 	if receivedState != savedState.(string) {
 		log.Printf("[OIDCHandler] State mismatch. Expected: %s, Got: %s", savedState, receivedState)
+		recordFailure(sess, h.App, "", "Invalid state parameter")
 		return c.Status(fiber.StatusBadRequest).SendString("Invalid state parameter")
 	}
 
@@ -171,6 +195,7 @@ func (h *OIDCHandler) Callback(c fiber.Ctx) error {
 	if errParam := c.Query("error"); errParam != "" {
 		errDesc := c.Query("error_description")
 		log.Printf("[OIDCHandler] Error from IDP: %s - %s", errParam, errDesc)
+		recordFailure(sess, h.App, "", fmt.Sprintf("Authentication error: %s - %s", errParam, errDesc))
 		return c.Status(fiber.StatusForbidden).SendString(fmt.Sprintf("Authentication error: %s - %s", errParam, errDesc))
 	}
 
@@ -178,6 +203,7 @@ func (h *OIDCHandler) Callback(c fiber.Ctx) error {
 	code := c.Query("code")
 	if code == "" {
 		log.Printf("[OIDCHandler] No authorization code in callback")
+		recordFailure(sess, h.App, "", "Missing authorization code")
 		return c.Status(fiber.StatusBadRequest).SendString("Missing authorization code")
 	}
 
@@ -185,10 +211,22 @@ func (h *OIDCHandler) Callback(c fiber.Ctx) error {
 
 	ctx := context.Background()
 
-	// Exchange authorization code for tokens
-	oauth2Token, err := h.OAuth2Config.Exchange(ctx, code)
+	// Exchange authorization code for tokens, proving possession of the PKCE verifier
+	var exchangeOptions []oauth2.AuthCodeOption
+	if h.App.UsesPKCE() {
+		verifier, ok := sess.Get("oidc_code_verifier").(string)
+		if !ok || verifier == "" {
+			log.Printf("[OIDCHandler] No PKCE code verifier found in session")
+			recordFailure(sess, h.App, "", "Invalid session: no PKCE code verifier")
+			return c.Status(fiber.StatusBadRequest).SendString("Invalid session: no PKCE code verifier")
+		}
+		exchangeOptions = append(exchangeOptions, oauth2.VerifierOption(verifier))
+	}
+
+	oauth2Token, err := h.OAuth2Config.Exchange(ctx, code, exchangeOptions...)
 	if err != nil {
 		log.Printf("[OIDCHandler] Failed to exchange code for token: %v", err)
+		recordFailure(sess, h.App, "", fmt.Sprintf("Failed to exchange token: %v", err))
 		return c.Status(fiber.StatusInternalServerError).SendString(fmt.Sprintf("Failed to exchange token: %v", err))
 	}
 
@@ -198,6 +236,7 @@ func (h *OIDCHandler) Callback(c fiber.Ctx) error {
 	rawIDToken, ok := oauth2Token.Extra("id_token").(string)
 	if !ok {
 		log.Printf("[OIDCHandler] No id_token in token response")
+		recordFailure(sess, h.App, "", "No id_token in response")
 		return c.Status(fiber.StatusInternalServerError).SendString("No id_token in response")
 	}
 
@@ -213,12 +252,14 @@ func (h *OIDCHandler) Callback(c fiber.Ctx) error {
 	idToken, err := h.Verifier.Verify(ctx, rawIDToken)
 	if err != nil {
 		log.Printf("[OIDCHandler] Failed to verify ID token: %v", err)
+		recordFailure(sess, h.App, "", fmt.Sprintf("Failed to verify ID token: %v", err))
 		return c.Status(fiber.StatusForbidden).SendString(fmt.Sprintf("Failed to verify ID token: %v", err))
 	}
 
 	// Verify nonce
 	if idToken.Nonce != nonceStr {
 		log.Printf("[OIDCHandler] Nonce mismatch. Expected: %s, Got: %s", nonceStr, idToken.Nonce)
+		recordFailure(sess, h.App, idToken.Subject, "Invalid nonce")
 		return c.Status(fiber.StatusBadRequest).SendString("Invalid nonce")
 	}
 
@@ -228,6 +269,7 @@ func (h *OIDCHandler) Callback(c fiber.Ctx) error {
 	var claims map[string]interface{}
 	if err := idToken.Claims(&claims); err != nil {
 		log.Printf("[OIDCHandler] Failed to extract claims: %v", err)
+		recordFailure(sess, h.App, idToken.Subject, "Failed to extract claims")
 		return c.Status(fiber.StatusInternalServerError).SendString("Failed to extract claims")
 	}
 
@@ -277,10 +319,19 @@ func (h *OIDCHandler) Callback(c fiber.Ctx) error {
 	sess.Set("auth_time", time.Now().Unix())
 	sess.Set("access_token", oauth2Token.AccessToken)
 	sess.Set("token_type", oauth2Token.TokenType)
+	sess.Set("id_token", rawIDToken) // Sent back as id_token_hint on RP-initiated logout
+	sess.Set("refresh_token", oauth2Token.RefreshToken)
+	if !oauth2Token.Expiry.IsZero() {
+		sess.Set("token_expiry", oauth2Token.Expiry.Unix())
+	}
+	if scope, ok := oauth2Token.Extra("scope").(string); ok {
+		sess.Set("granted_scope", scope)
+	}
 
 	// Clean up temporary session data
 	sess.Delete("oidc_state")
 	sess.Delete("oidc_nonce")
+	sess.Delete("oidc_code_verifier")
 
 	if err := sess.Save(); err != nil {
 		log.Printf("[OIDCHandler] Failed to save session: %v", err)
@@ -288,6 +339,11 @@ func (h *OIDCHandler) Callback(c fiber.Ctx) error {
 	}
 
 	log.Printf("[OIDCHandler] User authenticated: %s", userEmail)
+	claimsDetails, err := json.MarshalIndent(claims, "", "  ")
+	if err != nil {
+		claimsDetails = claimsJSON
+	}
+	recordAttempt(sess, h.App, history.Attempt{Username: userEmail, Success: true, Details: string(claimsDetails)})
 
 	// Redirect to success page
 	return c.Redirect().To(fmt.Sprintf("/app/%s/oidc/success", h.App.ID))
@@ -346,40 +402,406 @@ func (h *OIDCHandler) Success(c fiber.Ctx) error {
 		"authTime":  authTimeStr,
 		"claims":    claimsMap,
 		"tokenType": sess.Get("token_type"),
+		"scopes":    h.OAuth2Config.Scopes,
+		"pkce":      pkceModeLabel(h.App.PKCEMode),
+	}
+	if grantedScope, ok := sess.Get("granted_scope").(string); ok && grantedScope != "" {
+		responseData["grantedScope"] = grantedScope
 	}
 
 	// Format response data as JSON for display
 	responseJSON, _ := json.MarshalIndent(responseData, "", "  ")
 
+	// Decode the tokens for the token inspector
+	idToken, _ := sess.Get("id_token").(string)
+	accessToken, _ := sess.Get("access_token").(string)
+	refreshToken, _ := sess.Get("refresh_token").(string)
+	tokens := []*oidcutil.TokenInspection{
+		oidcutil.InspectToken("ID Token", idToken),
+		oidcutil.InspectToken("Access Token", accessToken),
+	}
+
 	return c.Render("success", fiber.Map{
-		"AppType":        "oidc",
-		"AppID":          h.App.ID,
-		"AppName":        h.App.Name,
-		"UserEmail":      userEmail,
-		"AuthFactor":     "OpenID Connect",
-		"AuthResult":     "success",
-		"TokenData":      string(responseJSON),
-		"ClaimsJSON":     string(responseJSON),
-		"AdminHostname":  getAdminHostname(h.App.APIHostname),
-		"IntegrationKey": h.App.ClientID,
+		"Tokens":                tokens,
+		"RefreshAvailable":      refreshToken != "",
+		"IntrospectionEndpoint": h.introspectionEndpoint(),
+		"AppType":               "oidc",
+		"AppID":                 h.App.ID,
+		"AppName":               h.App.Name,
+		"UserEmail":             userEmail,
+		"AuthFactor":            "OpenID Connect",
+		"AuthResult":            "success",
+		"TokenData":             string(responseJSON),
+		"ClaimsJSON":            string(responseJSON),
+		"AdminHostname":         getAdminHostname(h.App.APIHostname),
+		"IntegrationKey":        h.App.ClientID,
+	})
+}
+
+// oidcLogoutRoundTrip records an RP-initiated logout so the page the provider returns
+// to can confirm it; stored in the session as JSON while the user is at the provider
+type oidcLogoutRoundTrip struct {
+	Flow                  string `json:"flow"` // "rp-initiated" or "local"
+	Subject               string `json:"sub,omitempty"`
+	SessionID             string `json:"sid,omitempty"`
+	EndSessionEndpoint    string `json:"end_session_endpoint,omitempty"`
+	PostLogoutRedirectURI string `json:"post_logout_redirect_uri,omitempty"`
+	State                 string `json:"state,omitempty"`
+	ReturnedState         string `json:"returned_state,omitempty"`
+	StateVerified         bool   `json:"state_verified"`
+	StartedAt             string `json:"started_at,omitempty"`
+	CompletedAt           string `json:"completed_at,omitempty"`
+	Note                  string `json:"note,omitempty"`
+}
+
+// oidcSessionKeys are the session values set by a completed OIDC login
+var oidcSessionKeys = []string{
+	"authenticated", "user_id", "user_email", "claims_json", "auth_time",
+	"access_token", "token_type", "id_token", "granted_scope",
+	"refresh_token", "token_expiry",
+}
+
+// RefreshTokens redeems the refresh token stored at login for a new set of tokens
+func (h *OIDCHandler) RefreshTokens(c fiber.Ctx) error {
+	log.Printf("[OIDCHandler] Refreshing tokens for app: %s", h.App.Name)
+
+	sess, status, err := h.authenticatedSession(c)
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	refreshToken, _ := sess.Get("refresh_token").(string)
+	if refreshToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "No refresh token in this session; the provider did not issue one at login",
+		})
+	}
+
+	ctx := context.Background()
+
+	// A token without an access token is never valid, so the source always refreshes
+	token, err := h.OAuth2Config.TokenSource(ctx, &oauth2.Token{RefreshToken: refreshToken}).Token()
+	if err != nil {
+		log.Printf("[OIDCHandler] Token refresh failed: %v", err)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error": fmt.Sprintf("Token refresh failed: %v", err),
+		})
+	}
+
+	result := fiber.Map{
+		"message":               "Tokens refreshed",
+		"token_type":            token.TokenType,
+		"refresh_token_rotated": token.RefreshToken != refreshToken,
+		"id_token_refreshed":    false,
+	}
+	if !token.Expiry.IsZero() {
+		result["expires_at"] = token.Expiry.UTC().Format(time.RFC3339)
+		sess.Set("token_expiry", token.Expiry.Unix())
+	}
+	if scope, ok := token.Extra("scope").(string); ok {
+		result["scope"] = scope
+		sess.Set("granted_scope", scope)
+	}
+
+	// A refreshed ID token carries no nonce; its signature, issuer and audience still apply
+	if rawIDToken, ok := token.Extra("id_token").(string); ok && rawIDToken != "" {
+		if _, err := h.Verifier.Verify(ctx, rawIDToken); err != nil {
+			log.Printf("[OIDCHandler] Refreshed ID token failed verification: %v", err)
+			result["id_token_error"] = err.Error()
+		} else {
+			sess.Set("id_token", rawIDToken)
+			result["id_token_refreshed"] = true
+		}
+	}
+
+	sess.Set("access_token", token.AccessToken)
+	sess.Set("token_type", token.TokenType)
+	if token.RefreshToken != "" {
+		sess.Set("refresh_token", token.RefreshToken)
+	}
+	if err := sess.Save(); err != nil {
+		log.Printf("[OIDCHandler] Failed to save session: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Session error"})
+	}
+
+	log.Printf("[OIDCHandler] Tokens refreshed (refresh token rotated: %v)", result["refresh_token_rotated"])
+	return c.JSON(result)
+}
+
+// IntrospectToken asks the provider's introspection endpoint about the access token
+// (or the refresh token when the request body has {"token": "refresh_token"})
+func (h *OIDCHandler) IntrospectToken(c fiber.Ctx) error {
+	log.Printf("[OIDCHandler] Introspecting token for app: %s", h.App.Name)
+
+	sess, status, err := h.authenticatedSession(c)
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var req struct {
+		Token string `json:"token"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.Bind().Body(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+		}
+	}
+
+	tokenTypeHint := "access_token"
+	if req.Token == "refresh_token" {
+		tokenTypeHint = "refresh_token"
+	}
+	token, _ := sess.Get(tokenTypeHint).(string)
+
+	endpoint := h.introspectionEndpoint()
+	result, err := oidcutil.Introspect(context.Background(), oidcutil.IntrospectionRequest{
+		Endpoint:      endpoint,
+		ClientID:      h.OAuth2Config.ClientID,
+		ClientSecret:  h.OAuth2Config.ClientSecret,
+		Token:         token,
+		TokenTypeHint: tokenTypeHint,
+	})
+	if err != nil {
+		log.Printf("[OIDCHandler] Token introspection failed: %v", err)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error": fmt.Sprintf("Token introspection failed: %v", err),
+		})
+	}
+
+	log.Printf("[OIDCHandler] Introspected %s (active: %v)", tokenTypeHint, result["active"])
+	return c.JSON(fiber.Map{
+		"endpoint":        endpoint,
+		"token_type_hint": tokenTypeHint,
+		"result":          result,
 	})
 }
 
-// Logout handles logout requests
+// RefetchUserInfo calls the userinfo endpoint again with the current access token
+func (h *OIDCHandler) RefetchUserInfo(c fiber.Ctx) error {
+	log.Printf("[OIDCHandler] Re-fetching userinfo for app: %s", h.App.Name)
+
+	sess, status, err := h.authenticatedSession(c)
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	accessToken, _ := sess.Get("access_token").(string)
+	tokenType, _ := sess.Get("token_type").(string)
+
+	userInfo, err := h.Provider.UserInfo(context.Background(), oauth2.StaticTokenSource(&oauth2.Token{
+		AccessToken: accessToken,
+		TokenType:   tokenType,
+	}))
+	if err != nil {
+		log.Printf("[OIDCHandler] Userinfo request failed: %v", err)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error": fmt.Sprintf("Userinfo request failed: %v", err),
+		})
+	}
+
+	var claims map[string]interface{}
+	if err := userInfo.Claims(&claims); err != nil {
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to decode userinfo claims: %v", err),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"subject": userInfo.Subject,
+		"claims":  claims,
+	})
+}
+
+// authenticatedSession returns the session of a signed-in user, or the HTTP status
+// and error to report when there is none
+func (h *OIDCHandler) authenticatedSession(c fiber.Ctx) (*session.Session, int, error) {
+	sess, err := h.Session.Get(c)
+	if err != nil {
+		log.Printf("[OIDCHandler] Failed to get session: %v", err)
+		return nil, fiber.StatusInternalServerError, fmt.Errorf("Session error")
+	}
+
+	if authenticated, _ := sess.Get("authenticated").(bool); !authenticated {
+		return nil, fiber.StatusUnauthorized, fmt.Errorf("Not authenticated")
+	}
+
+	return sess, fiber.StatusOK, nil
+}
+
+// introspectionEndpoint returns the configured token introspection endpoint, falling
+// back to the introspection_endpoint advertised in discovery
+func (h *OIDCHandler) introspectionEndpoint() string {
+	if h.App.IDPIntrospectionEndpoint != "" {
+		return h.App.IDPIntrospectionEndpoint
+	}
+
+	var discovery struct {
+		IntrospectionEndpoint string `json:"introspection_endpoint"`
+	}
+	if err := h.Provider.Claims(&discovery); err != nil {
+		return ""
+	}
+	return discovery.IntrospectionEndpoint
+}
+
+// Logout handles logout requests. When the provider advertises an end_session_endpoint
+// and the session holds an ID token, the user is sent there (RP-initiated logout) and
+// returns to LogoutCallback; otherwise only the local session is cleared.
 func (h *OIDCHandler) Logout(c fiber.Ctx) error {
 	log.Printf("[OIDCHandler] Handling logout request for app: %s", h.App.Name)
 
-	// Get session
 	sess, err := h.Session.Get(c)
-	if err == nil {
-		// Destroy session
-		if err := sess.Destroy(); err != nil {
-			log.Printf("[OIDCHandler] Failed to destroy session: %v", err)
+	if err != nil {
+		log.Printf("[OIDCHandler] Failed to get session: %v", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("Session error")
+	}
+
+	roundTrip := &oidcLogoutRoundTrip{Flow: "local"}
+	roundTrip.Subject, _ = sess.Get("user_id").(string)
+	rawIDToken, _ := sess.Get("id_token").(string)
+	roundTrip.SessionID = idTokenSessionID(sess)
+	roundTrip.EndSessionEndpoint = h.endSessionEndpoint()
+
+	switch {
+	case rawIDToken == "":
+		roundTrip.Note = "No ID token in this session; only the local session was cleared"
+	case roundTrip.EndSessionEndpoint == "":
+		roundTrip.Note = "The provider does not advertise an end_session_endpoint; only the local session was cleared"
+	default:
+		endSessionURL, err := url.Parse(roundTrip.EndSessionEndpoint)
+		if err != nil {
+			log.Printf("[OIDCHandler] Invalid end_session_endpoint %q: %v", roundTrip.EndSessionEndpoint, err)
+			roundTrip.Note = fmt.Sprintf("The provider's end_session_endpoint is invalid (%v); only the local session was cleared", err)
+			break
+		}
+
+		roundTrip.Flow = "rp-initiated"
+		roundTrip.State = generateRandomString(32)
+		roundTrip.PostLogoutRedirectURI = fmt.Sprintf("%s/app/%s/oidc/logout/callback", h.BaseURL, h.App.ID)
+		roundTrip.StartedAt = time.Now().UTC().Format(time.RFC3339)
+
+		query := endSessionURL.Query()
+		query.Set("id_token_hint", rawIDToken)
+		query.Set("post_logout_redirect_uri", roundTrip.PostLogoutRedirectURI)
+		query.Set("client_id", h.App.ClientID)
+		query.Set("state", roundTrip.State)
+		endSessionURL.RawQuery = query.Encode()
+
+		roundTripJSON, err := json.Marshal(roundTrip)
+		if err != nil {
+			log.Printf("[OIDCHandler] Failed to marshal logout state: %v", err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Session error")
+		}
+
+		// The user is signed out locally now; only the pending logout survives the redirect
+		for _, key := range oidcSessionKeys {
+			sess.Delete(key)
+		}
+		sess.Set("oidc_logout_json", string(roundTripJSON))
+		if err := sess.Save(); err != nil {
+			log.Printf("[OIDCHandler] Failed to save session: %v", err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Session error")
 		}
+
+		log.Printf("[OIDCHandler] Redirecting %s to end_session_endpoint: %s", roundTrip.Subject, roundTrip.EndSessionEndpoint)
+		return c.Redirect().To(endSessionURL.String())
+	}
+
+	if err := sess.Destroy(); err != nil {
+		log.Printf("[OIDCHandler] Failed to destroy session: %v", err)
+	}
+	log.Printf("[OIDCHandler] Local logout: %s", roundTrip.Note)
+
+	return h.renderLogout(c, roundTrip)
+}
+
+// LogoutCallback is the post_logout_redirect_uri the provider returns to after
+// ending its session
+func (h *OIDCHandler) LogoutCallback(c fiber.Ctx) error {
+	log.Printf("[OIDCHandler] Received post-logout redirect for app: %s", h.App.Name)
+
+	sess, err := h.Session.Get(c)
+	if err != nil {
+		log.Printf("[OIDCHandler] Failed to get session: %v", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("Session error")
+	}
+
+	roundTrip := &oidcLogoutRoundTrip{Flow: "rp-initiated"}
+	if roundTripJSON, ok := sess.Get("oidc_logout_json").(string); ok {
+		if err := json.Unmarshal([]byte(roundTripJSON), roundTrip); err != nil {
+			log.Printf("[OIDCHandler] Failed to parse logout state: %v", err)
+		}
+	}
+	roundTrip.ReturnedState = c.Query("state")
+	roundTrip.CompletedAt = time.Now().UTC().Format(time.RFC3339)
+
+	switch {
+	case roundTrip.State == "":
+		roundTrip.Note = "No RP-initiated logout is pending in this session"
+	case roundTrip.ReturnedState == "":
+		roundTrip.Note = "The provider returned without a state parameter"
+	case roundTrip.ReturnedState != roundTrip.State:
+		roundTrip.Note = "The state returned by the provider does not match the logout request"
+	default:
+		roundTrip.StateVerified = true
+	}
+
+	if err := sess.Destroy(); err != nil {
+		log.Printf("[OIDCHandler] Failed to destroy session: %v", err)
 	}
+	log.Printf("[OIDCHandler] RP-initiated logout completed (state verified: %v)", roundTrip.StateVerified)
+
+	return h.renderLogout(c, roundTrip)
+}
 
-	// Redirect to login page
-	return c.Redirect().To(fmt.Sprintf("/app/%s", h.App.ID))
+// renderLogout renders the logout result page for an OIDC logout
+func (h *OIDCHandler) renderLogout(c fiber.Ctx, roundTrip *oidcLogoutRoundTrip) error {
+	roundTripJSON, _ := json.MarshalIndent(roundTrip, "", "  ")
+
+	return c.Render("logout", fiber.Map{
+		"AppType":    "oidc",
+		"AppID":      h.App.ID,
+		"AppName":    h.App.Name,
+		"OIDCLogout": roundTrip,
+		"LogoutJSON": string(roundTripJSON),
+	})
+}
+
+// endSessionEndpoint returns the end_session_endpoint from the provider's discovery document
+func (h *OIDCHandler) endSessionEndpoint() string {
+	var discovery struct {
+		EndSessionEndpoint string `json:"end_session_endpoint"`
+	}
+	if err := h.Provider.Claims(&discovery); err != nil {
+		log.Printf("[OIDCHandler] Failed to read discovery claims: %v", err)
+		return ""
+	}
+	return discovery.EndSessionEndpoint
+}
+
+// idTokenSessionID returns the sid claim of the ID token stored in the session, if any
+func idTokenSessionID(sess *session.Session) string {
+	claimsJSON, ok := sess.Get("claims_json").(string)
+	if !ok {
+		return ""
+	}
+	var claims struct {
+		SID string `json:"sid"`
+	}
+	json.Unmarshal([]byte(claimsJSON), &claims)
+	return claims.SID
+}
+
+// pkceModeLabel describes a PKCE mode for logs and the success page
+func pkceModeLabel(mode string) string {
+	switch mode {
+	case config.PKCEModeS256:
+		return "S256 (confidential client)"
+	case config.PKCEModePublic:
+		return "S256 (public client, no secret)"
+	default:
+		return "disabled"
+	}
 }
 
 // generateRandomString generates a random base64 encoded string
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"user_experience_toolkit/internal/config"
+	"user_experience_toolkit/internal/history"
 
 	"github.com/duosecurity/duo_universal_golang/duouniversal"
 	"github.com/gofiber/fiber/v3"
@@ -133,6 +134,7 @@ func (h *V4Handler) ProcessLogin(c fiber.Ctx) error {
 
 	sess.Set("state", state)
 	sess.Set("username", username)
+	markAttemptStarted(sess, h.App)
 
 	if err := sess.Save(); err != nil {
 		log.Printf("Failed to save session: %v", err)
@@ -170,6 +172,8 @@ func (h *V4Handler) Callback(c fiber.Ctx) error {
 	if errMsg := c.Query("error"); errMsg != "" {
 		errDesc := c.Query("error_description")
 		log.Printf("Duo auth error: %s - %s", errMsg, errDesc)
+		sess, _ := h.Store.Get(c)
+		recordFailure(sess, h.App, sessionString(sess, "username"), fmt.Sprintf("%s: %s", errMsg, errDesc))
 		return c.SendString(fmt.Sprintf("Got Error: %s: %s", errMsg, errDesc))
 	}
 
@@ -178,6 +182,7 @@ func (h *V4Handler) Callback(c fiber.Ctx) error {
 	state := c.Query("state")
 
 	if code == "" || state == "" {
+		recordFailure(nil, h.App, "", "Missing authorization code or state")
 		return c.Render("login", fiber.Map{
 			"AppType":        "v4",
 			"Message":        "Missing authorization code or state",
@@ -193,6 +198,7 @@ func (h *V4Handler) Callback(c fiber.Ctx) error {
 	sess, err := h.Store.Get(c)
 	if err != nil {
 		log.Printf("Failed to get session: %v", err)
+		recordFailure(nil, h.App, "", "Session error")
 		return c.Render("login", fiber.Map{
 			"AppType":        "v4",
 			"Message":        "Session error",
@@ -208,6 +214,7 @@ func (h *V4Handler) Callback(c fiber.Ctx) error {
 	username := sess.Get("username")
 
 	if savedState == nil || username == nil {
+		recordFailure(sess, h.App, "", "No saved state")
 		return c.Render("login", fiber.Map{
 			"AppType":        "v4",
 			"Message":        "No saved state, please login again",
@@ -221,6 +228,7 @@ func (h *V4Handler) Callback(c fiber.Ctx) error {
 
 	// Verify state matches
 	if state != savedState.(string) {
+		recordFailure(sess, h.App, username.(string), "Duo state does not match saved state")
 		return c.Render("login", fiber.Map{
 			"AppType":        "v4",
 			"Message":        "Duo state does not match saved state",
@@ -236,6 +244,7 @@ func (h *V4Handler) Callback(c fiber.Ctx) error {
 	decodedToken, err := h.DuoClient.ExchangeAuthorizationCodeFor2faResult(code, username.(string))
 	if err != nil {
 		log.Printf("Failed to exchange code: %v", err)
+		recordFailure(sess, h.App, username.(string), fmt.Sprintf("Failed to exchange code: %v", err))
 		return c.Render("login", fiber.Map{
 			"AppType":        "v4",
 			"Message":        "Error decoding Duo result. Confirm device clock is correct.",
@@ -287,6 +296,15 @@ func (h *V4Handler) Callback(c fiber.Ctx) error {
 		authLocation = loc.Country
 	}
 
+	recordAttempt(sess, h.App, history.Attempt{
+		Username: username.(string),
+		Factor:   authFactor,
+		Success:  authResult == "allow",
+		Result:   authResult,
+		Error:    resultError(authResult, authStatus),
+		Details:  string(tokenJSON),
+	})
+
 	// Clean up session
 	sess.Delete("state")
 	sess.Delete("username")
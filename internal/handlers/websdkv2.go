@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"user_experience_toolkit/internal/config"
+	"user_experience_toolkit/internal/duoweb"
+	"user_experience_toolkit/internal/history"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/session"
+)
+
+// WebSDKV2Handler runs the legacy Web SDK v2 flow: a password form followed by the Duo
+// iframe, which posts a signed response back to the callback
+type WebSDKV2Handler struct {
+	App            *config.Application
+	Store          *session.Store
+	applicationKey string
+}
+
+// NewWebSDKV2HandlerFromApp creates a new Web SDK v2 handler from an Application config
+func NewWebSDKV2HandlerFromApp(app *config.Application, store *session.Store) (*WebSDKV2Handler, error) {
+	if app.GetApplicationType() != "websdkv2" {
+		return nil, fmt.Errorf("application is configured as %s, not Web SDK v2", app.GetApplicationType())
+	}
+
+	return &WebSDKV2Handler{
+		App:            app,
+		Store:          store,
+		applicationKey: webSDKV2ApplicationKey(app),
+	}, nil
+}
+
+// webSDKV2ApplicationKey derives the application secret (akey) that signs the APP half of
+// the request. It is never sent to Duo, so deriving it from the client secret avoids
+// another setting while still changing when the secret is rotated.
+func webSDKV2ApplicationKey(app *config.Application) string {
+	mac := hmac.New(sha256.New, []byte(app.ClientSecret))
+	mac.Write([]byte("websdkv2 application key " + app.ID))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Login renders the password form
+func (h *WebSDKV2Handler) Login(c fiber.Ctx) error {
+	return h.renderLogin(c, "", "")
+}
+
+// ProcessLogin signs a request for the user and renders the Duo iframe
+func (h *WebSDKV2Handler) ProcessLogin(c fiber.Ctx) error {
+	username := strings.TrimSpace(c.FormValue("username"))
+	password := c.FormValue("password")
+
+	// Basic validation
+	if username == "" || password == "" {
+		return h.renderLogin(c, "Incorrect username or password", "")
+	}
+
+	sigRequest, err := duoweb.SignRequest(h.App.ClientID, h.App.ClientSecret, h.applicationKey, username)
+	if err != nil {
+		log.Printf("[WebSDKV2Handler] Failed to sign request: %v", err)
+		return h.renderLogin(c, "Failed to sign the Duo request: "+err.Error(), "")
+	}
+
+	// Remember when the attempt started; the signed response names the user
+	if sess, err := h.Store.Get(c); err == nil {
+		markAttemptStarted(sess, h.App)
+		if err := sess.Save(); err != nil {
+			log.Printf("[WebSDKV2Handler] Failed to save session: %v", err)
+		}
+	}
+
+	return h.renderLogin(c, "", sigRequest)
+}
+
+// Callback verifies the sig_response posted by the iframe
+func (h *WebSDKV2Handler) Callback(c fiber.Ctx) error {
+	sess, _ := h.Store.Get(c)
+	sigResponse := c.FormValue("sig_response")
+	if sigResponse == "" {
+		recordFailure(sess, h.App, "", "Missing Duo response")
+		return h.renderLogin(c, "Missing Duo response", "")
+	}
+
+	username, err := duoweb.VerifyResponse(h.App.ClientID, h.App.ClientSecret, h.applicationKey, sigResponse)
+	if err != nil {
+		log.Printf("[WebSDKV2Handler] Failed to verify response: %v", err)
+		recordAttempt(sess, h.App, history.Attempt{Error: fmt.Sprintf("Failed to verify response: %v", err), Details: sigResponse})
+		return h.renderLogin(c, "Duo response could not be verified. Confirm device clock is correct.", "")
+	}
+
+	// Format the verified response as JSON for display
+	tokenJSON, err := json.MarshalIndent(map[string]string{
+		"username":        username,
+		"integration_key": h.App.ClientID,
+		"sig_response":    sigResponse,
+	}, "", "  ")
+	if err != nil {
+		tokenJSON = []byte(sigResponse)
+	}
+	recordAttempt(sess, h.App, history.Attempt{Username: username, Success: true, Result: "allow", Details: string(tokenJSON)})
+
+	return c.Render("success", fiber.Map{
+		"AppType":        "websdkv2",
+		"TokenData":      string(tokenJSON),
+		"AppName":        h.App.Name,
+		"AppID":          h.App.ID,
+		"AuthResult":     "allow",
+		"AuthFactor":     "Duo iframe",
+		"UserEmail":      username,
+		"AdminHostname":  getAdminHostname(h.App.APIHostname),
+		"IntegrationKey": h.App.ClientID,
+	})
+}
+
+func (h *WebSDKV2Handler) renderLogin(c fiber.Ctx, message, sigRequest string) error {
+	return c.Render("login", fiber.Map{
+		"AppType":        "websdkv2",
+		"Message":        message,
+		"AppName":        h.App.Name,
+		"AppID":          h.App.ID,
+		"APIHostname":    h.App.APIHostname,
+		"AdminHostname":  getAdminHostname(h.App.APIHostname),
+		"IntegrationKey": h.App.ClientID,
+		"SigRequest":     sigRequest,
+	})
+}
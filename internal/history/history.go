@@ -0,0 +1,295 @@
+// Package history stores the outcome of every login attempt made through the demo
+// applications in a local bbolt database, so past results can be compared and exported
+package history
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// historyBucket holds every attempt, keyed by ID so a cursor walks them oldest first
+const historyBucket = "attempts"
+
+// ErrNotFound is returned by Get for an unknown attempt ID
+var ErrNotFound = errors.New("attempt not found")
+
+// Attempt is one finished login, successful or not
+type Attempt struct {
+	ID         string    `json:"id"`
+	Time       time.Time `json:"time"`                  // when the attempt finished
+	DurationMS int64     `json:"duration_ms,omitempty"` // from the start of the login, when it started in this session
+	AppID      string    `json:"app_id"`
+	AppName    string    `json:"app_name"`
+	AppType    string    `json:"app_type"`
+	TenantID   string    `json:"tenant_id,omitempty"`
+	Username   string    `json:"username,omitempty"`
+	Factor     string    `json:"factor,omitempty"`
+	Success    bool      `json:"success"`
+	Result     string    `json:"result,omitempty"`  // result reported by Duo, e.g. allow or deny
+	Error      string    `json:"error,omitempty"`   // why the attempt failed
+	Details    string    `json:"details,omitempty"` // decoded token, claims or assertion
+}
+
+// Duration returns the time from the start of the login to its result
+func (a Attempt) Duration() time.Duration {
+	return time.Duration(a.DurationMS) * time.Millisecond
+}
+
+// Filter selects attempts. Empty fields match everything.
+type Filter struct {
+	AppID    string
+	AppType  string
+	Username string // case-insensitive substring
+	Status   string // "success" or "failure"
+	Since    time.Time
+	Until    time.Time
+	Limit    int // newest attempts returned, 0 for all
+}
+
+// Filter statuses
+const (
+	StatusSuccess = "success"
+	StatusFailure = "failure"
+)
+
+// Validate checks the filter values that come from a request
+func (f Filter) Validate() error {
+	switch f.Status {
+	case "", StatusSuccess, StatusFailure:
+	default:
+		return fmt.Errorf("invalid status: %s (must be success or failure)", f.Status)
+	}
+	if f.Limit < 0 {
+		return fmt.Errorf("limit must not be negative")
+	}
+	if !f.Since.IsZero() && !f.Until.IsZero() && f.Until.Before(f.Since) {
+		return fmt.Errorf("until must not be before since")
+	}
+	return nil
+}
+
+// Match reports whether the attempt passes the filter
+func (f Filter) Match(a Attempt) bool {
+	if f.AppID != "" && a.AppID != f.AppID {
+		return false
+	}
+	if f.AppType != "" && a.AppType != f.AppType {
+		return false
+	}
+	if f.Username != "" && !strings.Contains(strings.ToLower(a.Username), strings.ToLower(f.Username)) {
+		return false
+	}
+	if f.Status == StatusSuccess && !a.Success || f.Status == StatusFailure && a.Success {
+		return false
+	}
+	if !f.Since.IsZero() && a.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && a.Time.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// Store is the bbolt database of attempts. It keeps at most maxEntries attempts and
+// drops the oldest when a new one is recorded.
+type Store struct {
+	db         *bolt.DB
+	maxEntries int
+}
+
+// Open opens (or creates) the history database at path
+func Open(path string, maxEntries int) (*Store, error) {
+	if dir := filepath.Dir(path); dir != "" && dir != "." {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, fmt.Errorf("failed to create history directory: %w", err)
+		}
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history database: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(historyBucket))
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create history bucket: %w", err)
+	}
+
+	return &Store{db: db, maxEntries: maxEntries}, nil
+}
+
+// Record stores the attempt, assigning its ID and, when unset, its time
+func (s *Store) Record(a *Attempt) error {
+	if a.Time.IsZero() {
+		a.Time = time.Now()
+	}
+	key, err := newKey(a.Time)
+	if err != nil {
+		return err
+	}
+	a.ID = hex.EncodeToString(key)
+
+	data, err := json.Marshal(a)
+	if err != nil {
+		return fmt.Errorf("failed to encode attempt: %w", err)
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(historyBucket))
+		if err := bucket.Put(key, data); err != nil {
+			return err
+		}
+
+		// Drop the oldest attempts beyond the limit. Stats does not count the pending
+		// put, so walk back from the newest attempt instead.
+		if s.maxEntries <= 0 {
+			return nil
+		}
+		cursor := bucket.Cursor()
+		k, _ := cursor.Last()
+		for kept := 0; k != nil && kept < s.maxEntries; kept++ {
+			k, _ = cursor.Prev()
+		}
+		// Collect the keys first; deleting while a cursor walks the bucket skips entries
+		var oldest [][]byte
+		for ; k != nil; k, _ = cursor.Prev() {
+			oldest = append(oldest, append([]byte(nil), k...))
+		}
+		for _, k := range oldest {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// List returns the attempts that match the filter, newest first
+func (s *Store) List(f Filter) ([]Attempt, error) {
+	attempts := []Attempt{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket([]byte(historyBucket)).Cursor()
+		for k, v := cursor.Last(); k != nil; k, v = cursor.Prev() {
+			var a Attempt
+			if err := json.Unmarshal(v, &a); err != nil {
+				return fmt.Errorf("failed to decode attempt %x: %w", k, err)
+			}
+			if !f.Match(a) {
+				continue
+			}
+			attempts = append(attempts, a)
+			if f.Limit > 0 && len(attempts) == f.Limit {
+				break
+			}
+		}
+		return nil
+	})
+	return attempts, err
+}
+
+// Get returns the attempt with the given ID
+func (s *Store) Get(id string) (*Attempt, error) {
+	key, err := hex.DecodeString(id)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+
+	var a *Attempt
+	err = s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket([]byte(historyBucket)).Get(key)
+		if data == nil {
+			return ErrNotFound
+		}
+		a = &Attempt{}
+		return json.Unmarshal(data, a)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Clear removes every attempt
+func (s *Store) Clear() error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket([]byte(historyBucket)); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucket([]byte(historyBucket))
+		return err
+	})
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// newKey orders attempts by time: 8 bytes of Unix nanoseconds followed by 4 random
+// bytes so attempts finishing at the same instant do not collide
+func newKey(t time.Time) ([]byte, error) {
+	key := make([]byte, 12)
+	binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	if _, err := rand.Read(key[8:]); err != nil {
+		return nil, fmt.Errorf("failed to generate attempt ID: %w", err)
+	}
+	return key, nil
+}
+
+// csvHeader lists the exported columns; details are left out of CSV exports
+var csvHeader = []string{"id", "time", "duration_ms", "app_id", "app_name", "app_type", "tenant_id", "username", "factor", "success", "result", "error"}
+
+// WriteCSV writes the attempts as CSV, one row per attempt
+func WriteCSV(w io.Writer, attempts []Attempt) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, a := range attempts {
+		err := writer.Write([]string{
+			a.ID,
+			a.Time.UTC().Format(time.RFC3339),
+			strconv.FormatInt(a.DurationMS, 10),
+			csvSafe(a.AppID),
+			csvSafe(a.AppName),
+			a.AppType,
+			csvSafe(a.TenantID),
+			csvSafe(a.Username),
+			csvSafe(a.Factor),
+			strconv.FormatBool(a.Success),
+			csvSafe(a.Result),
+			csvSafe(a.Error),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvSafe keeps spreadsheets from evaluating values such as usernames as formulas
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
@@ -0,0 +1,188 @@
+package history
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestStore(t *testing.T, maxEntries int) *Store {
+	t.Helper()
+	store, err := Open(filepath.Join(t.TempDir(), "history.db"), maxEntries)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestStoreRecordAndList(t *testing.T) {
+	store := openTestStore(t, 0)
+	start := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+
+	attempts := []Attempt{
+		{Time: start, AppID: "a1", AppType: "websdk", Username: "alice", Success: true, Result: "allow", Details: `{"sub":"alice"}`},
+		{Time: start.Add(time.Hour), AppID: "a2", AppType: "saml", Username: "bob", Error: "SAML assertion audience mismatch"},
+		{Time: start.Add(2 * time.Hour), AppID: "a1", AppType: "websdk", Username: "Alice.Smith", Result: "deny", Error: "Login denied"},
+	}
+	for i := range attempts {
+		if err := store.Record(&attempts[i]); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+		if attempts[i].ID == "" {
+			t.Fatal("Record() should assign an ID")
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string // usernames, newest first
+	}{
+		{"all", Filter{}, []string{"Alice.Smith", "bob", "alice"}},
+		{"app", Filter{AppID: "a1"}, []string{"Alice.Smith", "alice"}},
+		{"type", Filter{AppType: "saml"}, []string{"bob"}},
+		{"username substring ignores case", Filter{Username: "ALICE"}, []string{"Alice.Smith", "alice"}},
+		{"successes", Filter{Status: StatusSuccess}, []string{"alice"}},
+		{"failures", Filter{Status: StatusFailure}, []string{"Alice.Smith", "bob"}},
+		{"since", Filter{Since: start.Add(30 * time.Minute)}, []string{"Alice.Smith", "bob"}},
+		{"until", Filter{Until: start.Add(90 * time.Minute)}, []string{"bob", "alice"}},
+		{"limit", Filter{Limit: 1}, []string{"Alice.Smith"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := store.List(tt.filter)
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			var usernames []string
+			for _, a := range got {
+				usernames = append(usernames, a.Username)
+			}
+			if len(usernames) != len(tt.want) {
+				t.Fatalf("List() = %v, want %v", usernames, tt.want)
+			}
+			for i := range usernames {
+				if usernames[i] != tt.want[i] {
+					t.Fatalf("List() = %v, want %v", usernames, tt.want)
+				}
+			}
+		})
+	}
+
+	got, err := store.Get(attempts[0].ID)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.Details != attempts[0].Details || !got.Time.Equal(start) {
+		t.Errorf("Get() = %+v, want %+v", got, attempts[0])
+	}
+	for _, id := range []string{"0000", "not hex"} {
+		if _, err := store.Get(id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get(%q) error = %v, want ErrNotFound", id, err)
+		}
+	}
+}
+
+func TestStorePrunesOldest(t *testing.T) {
+	store := openTestStore(t, 3)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		a := Attempt{Time: start.Add(time.Duration(i) * time.Second), AppID: "a1", Username: string(rune('a' + i))}
+		if err := store.Record(&a); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+
+	got, err := store.List(Filter{})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(got) != 3 || got[0].Username != "e" || got[2].Username != "c" {
+		t.Errorf("List() kept %+v, want the 3 newest attempts", got)
+	}
+}
+
+func TestStoreClear(t *testing.T) {
+	store := openTestStore(t, 0)
+	if err := store.Record(&Attempt{AppID: "a1"}); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	if err := store.Clear(); err != nil {
+		t.Fatalf("Clear() error = %v", err)
+	}
+	got, err := store.List(Filter{})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("List() after Clear() = %d attempts, want 0", len(got))
+	}
+	if err := store.Record(&Attempt{AppID: "a1"}); err != nil {
+		t.Errorf("Record() after Clear() error = %v", err)
+	}
+}
+
+func TestFilterValidate(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name    string
+		filter  Filter
+		wantErr bool
+	}{
+		{"empty", Filter{}, false},
+		{"success", Filter{Status: StatusSuccess}, false},
+		{"unknown status", Filter{Status: "denied"}, true},
+		{"negative limit", Filter{Limit: -1}, true},
+		{"until before since", Filter{Since: now, Until: now.Add(-time.Hour)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.filter.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	attempts := []Attempt{{
+		ID:         "01",
+		Time:       time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC),
+		DurationMS: 1500,
+		AppID:      "a1",
+		AppName:    "Demo",
+		AppType:    "websdk",
+		Username:   "=HYPERLINK(\"http://example.com\")",
+		Factor:     "Duo Push",
+		Success:    true,
+		Result:     "allow",
+		Details:    `{"secret":"not exported"}`,
+	}}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, attempts); err != nil {
+		t.Fatalf("WriteCSV() error = %v", err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("reading CSV: %v", err)
+	}
+	if len(records) != 2 || len(records[1]) != len(csvHeader) {
+		t.Fatalf("WriteCSV() = %v, want a header and one row of %d columns", records, len(csvHeader))
+	}
+
+	row := records[1]
+	if row[1] != "2026-01-02T10:00:00Z" || row[2] != "1500" || row[9] != "true" {
+		t.Errorf("WriteCSV() row = %v", row)
+	}
+	if row[7] != "'=HYPERLINK(\"http://example.com\")" {
+		t.Errorf("username = %q, want it prefixed so spreadsheets do not evaluate it", row[7])
+	}
+	if bytes.Contains(buf.Bytes(), []byte("not exported")) {
+		t.Error("WriteCSV() should leave out the details")
+	}
+}
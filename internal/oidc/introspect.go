@@ -0,0 +1,84 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// maxIntrospectionResponseSize caps the size of an introspection response body
+	maxIntrospectionResponseSize = 1 << 20
+
+	// introspectionTimeout bounds how long a token introspection request may take
+	introspectionTimeout = 15 * time.Second
+)
+
+// IntrospectionRequest describes an RFC 7662 token introspection call
+type IntrospectionRequest struct {
+	Endpoint      string
+	ClientID      string
+	ClientSecret  string // Empty for public clients; client_id is then sent in the form
+	Token         string
+	TokenTypeHint string // "access_token" or "refresh_token"
+}
+
+// Introspect asks the provider whether a token is active and returns its response as is
+func Introspect(ctx context.Context, req IntrospectionRequest) (map[string]interface{}, error) {
+	if req.Endpoint == "" {
+		return nil, fmt.Errorf("no introspection endpoint is configured")
+	}
+	if req.Token == "" {
+		return nil, fmt.Errorf("no token to introspect")
+	}
+
+	form := url.Values{"token": {req.Token}}
+	if req.TokenTypeHint != "" {
+		form.Set("token_type_hint", req.TokenTypeHint)
+	}
+	if req.ClientSecret == "" {
+		form.Set("client_id", req.ClientID)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, introspectionTimeout)
+	defer cancel()
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, req.Endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create introspection request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	httpReq.Header.Set("Accept", "application/json")
+	if req.ClientSecret != "" {
+		httpReq.SetBasicAuth(url.QueryEscape(req.ClientID), url.QueryEscape(req.ClientSecret))
+	}
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("introspection request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxIntrospectionResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read introspection response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("introspection endpoint returned HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("introspection response is not JSON: %w", err)
+	}
+	if _, ok := result["active"].(bool); !ok {
+		return nil, fmt.Errorf("introspection response has no boolean \"active\" member")
+	}
+
+	return result, nil
+}
@@ -0,0 +1,85 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIntrospect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "client" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.FormValue("token") != "access" || r.FormValue("token_type_hint") != "access_token" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"active":true,"sub":"user1","scope":"openid"}`))
+	}))
+	defer server.Close()
+
+	result, err := Introspect(context.Background(), IntrospectionRequest{
+		Endpoint:      server.URL,
+		ClientID:      "client",
+		ClientSecret:  "secret",
+		Token:         "access",
+		TokenTypeHint: "access_token",
+	})
+	if err != nil {
+		t.Fatalf("Introspect() error = %v", err)
+	}
+	if result["active"] != true || result["sub"] != "user1" {
+		t.Errorf("Introspect() = %v, want active token for user1", result)
+	}
+}
+
+func TestIntrospectPublicClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, _, ok := r.BasicAuth(); ok || r.FormValue("client_id") != "client" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"active":false}`))
+	}))
+	defer server.Close()
+
+	result, err := Introspect(context.Background(), IntrospectionRequest{
+		Endpoint: server.URL,
+		ClientID: "client",
+		Token:    "access",
+	})
+	if err != nil {
+		t.Fatalf("Introspect() error = %v", err)
+	}
+	if result["active"] != false {
+		t.Errorf("Introspect() = %v, want inactive token", result)
+	}
+}
+
+func TestIntrospectErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"sub":"user1"}`))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name string
+		req  IntrospectionRequest
+	}{
+		{"no endpoint", IntrospectionRequest{Token: "access"}},
+		{"no token", IntrospectionRequest{Endpoint: server.URL}},
+		{"missing active member", IntrospectionRequest{Endpoint: server.URL, Token: "access"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Introspect(context.Background(), tt.req); err == nil {
+				t.Error("Introspect() should return an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package oidc
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func encodeSegment(s string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(s))
+}
+
+func TestInspectToken(t *testing.T) {
+	raw := encodeSegment(`{"alg":"RS256","kid":"key-1","typ":"JWT"}`) + "." +
+		encodeSegment(`{"sub":"user1","iat":1735689600,"exp":1735693200}`) + ".c2ln"
+
+	inspection := InspectToken("ID Token", raw)
+	if !inspection.JWT {
+		t.Fatalf("InspectToken() error = %s, want a decoded JWT", inspection.Error)
+	}
+	if inspection.Algorithm != "RS256" || inspection.KeyID != "key-1" || inspection.Type != "JWT" {
+		t.Errorf("header = %s/%s/%s, want RS256/key-1/JWT", inspection.Algorithm, inspection.KeyID, inspection.Type)
+	}
+	if inspection.Claims["sub"] != "user1" {
+		t.Errorf("sub claim = %v, want user1", inspection.Claims["sub"])
+	}
+	if inspection.ExpiresAt != 1735693200 {
+		t.Errorf("ExpiresAt = %d, want 1735693200", inspection.ExpiresAt)
+	}
+	if got := inspection.Expires(); got != "2025-01-01T01:00:00Z" {
+		t.Errorf("Expires() = %s, want 2025-01-01T01:00:00Z", got)
+	}
+	if got := inspection.Issued(); got != "2025-01-01T00:00:00Z" {
+		t.Errorf("Issued() = %s, want 2025-01-01T00:00:00Z", got)
+	}
+}
+
+func TestInspectTokenOpaque(t *testing.T) {
+	inspection := InspectToken("Access Token", "opaque-access-token")
+	if inspection.JWT {
+		t.Error("opaque token should not be reported as a JWT")
+	}
+	if inspection.Error == "" {
+		t.Error("opaque token should carry an explanation")
+	}
+}
+
+func TestInspectTokenInvalidClaims(t *testing.T) {
+	raw := encodeSegment(`{"alg":"none"}`) + ".!!!." + "sig"
+	inspection := InspectToken("ID Token", raw)
+	if inspection.JWT || inspection.Error == "" {
+		t.Errorf("InspectToken() = %+v, want an error for undecodable claims", inspection)
+	}
+}
@@ -0,0 +1,226 @@
+// Package provision creates Duo integrations through the Admin API and saves them as
+// applications in config.yaml, for both the configuration UI and uetctl
+package provision
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"user_experience_toolkit/internal/config"
+	"user_experience_toolkit/internal/duoadmin"
+
+	"github.com/google/uuid"
+)
+
+// Errors returned by CreateApplication, wrapped with the details
+var (
+	ErrInvalidRequest = errors.New("invalid request")
+	ErrCredentials    = errors.New("invalid Admin API credentials or insufficient permissions")
+	ErrAdminAPI       = errors.New("duo Admin API call failed")
+)
+
+// AdminAPI is the part of duoadmin.Client used to create integrations
+type AdminAPI interface {
+	ValidateCredentials() error
+	CreateIntegration(params duoadmin.CreateIntegrationParams) (*duoadmin.Integration, error)
+	CreateSAMLIntegration(params duoadmin.CreateSAMLIntegrationParams) (*duoadmin.SAMLIntegration, error)
+	CreateOIDCIntegration(params duoadmin.CreateOIDCIntegrationParams) (*duoadmin.OIDCIntegration, error)
+}
+
+// ClientFactory returns an Admin API client for a tenant
+type ClientFactory func(tenant config.Tenant) AdminAPI
+
+// NewAdminClient creates a duoadmin.Client with the tenant's Admin API credentials
+func NewAdminClient(tenant config.Tenant) AdminAPI {
+	return duoadmin.NewClient(tenant.AdminAPIKey, tenant.AdminAPISecret, tenant.APIHostname)
+}
+
+// Request describes an application to create in Duo and in config.yaml
+type Request struct {
+	Name     string
+	Type     string // a registered application type, e.g. "websdk" or "saml"
+	Enabled  bool
+	TenantID string
+	BaseURL  string // external URL of the toolkit, used for SAML and OIDC URLs
+
+	// OIDC only
+	Scopes   []string // scopes in addition to openid
+	PKCEMode string   // "", "s256" or "public"
+}
+
+// Provisioner creates applications with each tenant's Admin API credentials
+type Provisioner struct {
+	Config    *config.Config
+	NewClient ClientFactory
+}
+
+// NewProvisioner creates a provisioner that talks to Duo with the Admin API
+func NewProvisioner(cfg *config.Config) *Provisioner {
+	return &Provisioner{Config: cfg, NewClient: NewAdminClient}
+}
+
+// ApplicationName returns name prefixed with the tenant name, "<tenant name> - <name>",
+// the way applications and their Duo integrations are named. A name that already
+// carries the prefix is returned unchanged.
+func ApplicationName(tenantName, name string) string {
+	prefix := tenantName + " - "
+	if strings.HasPrefix(name, prefix) {
+		return name
+	}
+	return prefix + name
+}
+
+// CreateApplication creates the Duo integration for req and adds the matching
+// application, with its credentials and IdP metadata, to the configuration. The
+// application name is prefixed with the tenant name.
+func (p *Provisioner) CreateApplication(req Request) (*config.Application, error) {
+	if req.Name == "" {
+		return nil, fmt.Errorf("%w: application name is required", ErrInvalidRequest)
+	}
+	appType, ok := config.LookupApplicationType(req.Type)
+	if !ok || appType.IntegrationType == "" {
+		return nil, fmt.Errorf("%w: type '%s' cannot be created via the Duo Admin API", ErrInvalidRequest, req.Type)
+	}
+	if req.TenantID == "" {
+		return nil, fmt.Errorf("%w: tenant ID is required", ErrInvalidRequest)
+	}
+	if req.PKCEMode != config.PKCEModeOff && req.PKCEMode != config.PKCEModeS256 && req.PKCEMode != config.PKCEModePublic {
+		return nil, fmt.Errorf("%w: PKCE mode must be empty, 's256' or 'public'", ErrInvalidRequest)
+	}
+	if (req.Type == "saml" || req.Type == "oidc") && req.BaseURL == "" {
+		return nil, fmt.Errorf("%w: base URL is required for %s applications", ErrInvalidRequest, req.Type)
+	}
+
+	tenant, err := p.Config.GetTenant(req.TenantID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: tenant not found: %v", ErrInvalidRequest, err)
+	}
+
+	log.Printf("[Provision] Using tenant '%s' with hostname: %s", tenant.Name, tenant.APIHostname)
+
+	adminClient := p.NewClient(*tenant)
+	if err := adminClient.ValidateCredentials(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCredentials, err)
+	}
+
+	fullAppName := ApplicationName(tenant.Name, req.Name)
+	baseURL := strings.TrimRight(req.BaseURL, "/")
+
+	var app config.Application
+	switch req.Type {
+	case "saml":
+		app, err = createSAML(adminClient, fullAppName, baseURL)
+	case "oidc":
+		app, err = createOIDC(adminClient, fullAppName, baseURL, req)
+	default:
+		// The remaining types only need an integration key and secret; the registry maps
+		// them to Duo's integration type (e.g. "device-management-portal" for DMP)
+		var integration *duoadmin.Integration
+		integration, err = adminClient.CreateIntegration(duoadmin.CreateIntegrationParams{
+			Name:    fullAppName,
+			Type:    appType.IntegrationType,
+			Enabled: req.Enabled,
+		})
+		if err == nil {
+			app = config.Application{
+				Type:         req.Type,
+				ClientID:     integration.IntegrationKey,
+				ClientSecret: integration.SecretKey,
+			}
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to create %s application: %v", ErrAdminAPI, appType.Label, err)
+	}
+
+	if app.ID == "" {
+		app.ID = uuid.New().String()
+	}
+	app.TenantID = tenant.ID
+	app.Name = fullAppName
+	app.Enabled = req.Enabled
+	app.APIHostname = tenant.APIHostname
+
+	if err := p.Config.AddApplication(app); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+
+	log.Printf("[Provision] Created %s application %s (%s)", app.Type, app.Name, app.ID)
+	return &app, nil
+}
+
+// createSAML creates a generic SAML service provider whose entity ID and ACS URL point
+// at a new application ID of this toolkit
+func createSAML(adminClient AdminAPI, name, baseURL string) (config.Application, error) {
+	appID := uuid.New().String()
+	entityID := fmt.Sprintf("%s/app/%s/saml", baseURL, appID)
+	acsURL := fmt.Sprintf("%s/app/%s/saml/acs", baseURL, appID)
+
+	integration, err := adminClient.CreateSAMLIntegration(duoadmin.CreateSAMLIntegrationParams{
+		Name:            name,
+		EntityID:        entityID,
+		ACSURL:          acsURL,
+		NameIDFormat:    "urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress",
+		NameIDAttribute: "<Email Address>",
+	})
+	if err != nil {
+		return config.Application{}, err
+	}
+
+	idp := integration.SSO.IDPMetadata
+	return config.Application{
+		ID:          appID,
+		Type:        "saml",
+		ClientID:    integration.IntegrationKey,
+		EntityID:    entityID,
+		ACSURL:      acsURL,
+		MetadataURL: fmt.Sprintf("%s/app/%s/saml/metadata", baseURL, appID),
+		// IDP metadata from Duo API response
+		IDPEntityID:    idp.EntityID,
+		IDPSSOURL:      idp.SSOURL,
+		IDPSLOURL:      idp.SLOURL,
+		IDPCertificate: idp.Cert,
+	}, nil
+}
+
+// createOIDC creates a generic OIDC relying party whose redirect URI points at a new
+// application ID of this toolkit
+func createOIDC(adminClient AdminAPI, name, baseURL string, req Request) (config.Application, error) {
+	appID := uuid.New().String()
+	redirectURI := fmt.Sprintf("%s/app/%s/oidc/callback", baseURL, appID)
+
+	integration, err := adminClient.CreateOIDCIntegration(duoadmin.CreateOIDCIntegrationParams{
+		Name:                   name,
+		RedirectURIs:           []string{redirectURI},
+		Scopes:                 req.Scopes, // openid is added automatically
+		AccessTokenLifespan:    3600,
+		AllowPKCEOnly:          req.PKCEMode == config.PKCEModePublic,
+		EnableRefreshToken:     true,
+		RefreshTokenChainLife:  2592000,
+		RefreshTokenSingleLife: 86400,
+	})
+	if err != nil {
+		return config.Application{}, err
+	}
+
+	idp := integration.SSO.IDPMetadata
+	return config.Application{
+		ID:           appID,
+		Type:         "oidc",
+		ClientID:     idp.ClientID,
+		ClientSecret: idp.ClientSecret,
+		RedirectURI:  redirectURI,
+		// IDP metadata from Duo API response
+		IDPDiscoveryURL:          idp.DiscoveryURL,
+		IDPIssuer:                idp.Issuer,
+		IDPAuthorizationEndpoint: idp.AuthorizeEndpointURL,
+		IDPTokenEndpoint:         idp.TokenEndpointURL,
+		IDPUserInfoEndpoint:      idp.UserInfoEndpointURL,
+		IDPJWKSEndpoint:          idp.JWKSEndpointURL,
+		IDPIntrospectionEndpoint: idp.TokenIntrospectionEndpointURL,
+		// OIDC client settings
+		OIDCScopes: req.Scopes,
+		PKCEMode:   req.PKCEMode,
+	}, nil
+}
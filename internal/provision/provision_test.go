@@ -0,0 +1,180 @@
+package provision
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"user_experience_toolkit/internal/config"
+	"user_experience_toolkit/internal/duoadmin"
+)
+
+// fakeAdminAPI records the integrations it was asked to create
+type fakeAdminAPI struct {
+	invalid bool
+	created []string
+	saml    duoadmin.CreateSAMLIntegrationParams
+	oidc    duoadmin.CreateOIDCIntegrationParams
+}
+
+func (f *fakeAdminAPI) ValidateCredentials() error {
+	if f.invalid {
+		return errors.New("401 unauthorized")
+	}
+	return nil
+}
+
+func (f *fakeAdminAPI) CreateIntegration(params duoadmin.CreateIntegrationParams) (*duoadmin.Integration, error) {
+	f.created = append(f.created, params.Type)
+	return &duoadmin.Integration{IntegrationKey: "DINEW", SecretKey: "new_secret", Name: params.Name, Type: params.Type}, nil
+}
+
+func (f *fakeAdminAPI) CreateSAMLIntegration(params duoadmin.CreateSAMLIntegrationParams) (*duoadmin.SAMLIntegration, error) {
+	f.created = append(f.created, "sso-generic")
+	f.saml = params
+	var integration duoadmin.SAMLIntegration
+	err := json.Unmarshal([]byte(`{"integration_key": "DISAML", "sso": {"idp_metadata": {
+		"entity_id": "https://sso.example.com/saml2/sp/DISAML/metadata",
+		"sso_url": "https://sso.example.com/saml2/sp/DISAML/sso",
+		"cert": "MIIC"}}}`), &integration)
+	return &integration, err
+}
+
+func (f *fakeAdminAPI) CreateOIDCIntegration(params duoadmin.CreateOIDCIntegrationParams) (*duoadmin.OIDCIntegration, error) {
+	f.created = append(f.created, "sso-oidc-generic")
+	f.oidc = params
+	var integration duoadmin.OIDCIntegration
+	err := json.Unmarshal([]byte(`{"integration_key": "DIOIDC", "sso": {"idp_metadata": {
+		"client_id": "DIOIDC", "client_secret": "oidc_secret",
+		"issuer": "https://sso.example.com/oidc/DIOIDC",
+		"discovery_url": "https://sso.example.com/oidc/DIOIDC/.well-known/openid-configuration"}}}`), &integration)
+	return &integration, err
+}
+
+func newTestProvisioner(t *testing.T) (*Provisioner, *fakeAdminAPI) {
+	t.Helper()
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+tenants:
+  - id: "tenant1"
+    name: "Test Tenant"
+    admin_api_key: "key"
+    admin_api_secret: "secret"
+    api_hostname: "api-test.duosecurity.com"
+applications: []
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	fake := &fakeAdminAPI{}
+	return &Provisioner{Config: cfg, NewClient: func(config.Tenant) AdminAPI { return fake }}, fake
+}
+
+func TestApplicationName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Portal", "Test Tenant - Portal"},
+		{"Test Tenant - Portal", "Test Tenant - Portal"},
+		{"Other - Portal", "Test Tenant - Other - Portal"},
+	}
+
+	for _, tt := range tests {
+		if got := ApplicationName("Test Tenant", tt.name); got != tt.want {
+			t.Errorf("ApplicationName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCreateApplication(t *testing.T) {
+	p, fake := newTestProvisioner(t)
+
+	app, err := p.CreateApplication(Request{Name: "Portal", Type: "dmp", Enabled: true, TenantID: "tenant1"})
+	if err != nil {
+		t.Fatalf("CreateApplication() error = %v", err)
+	}
+	if fake.created[0] != "device-management-portal" {
+		t.Errorf("Created integration type = %s, want device-management-portal", fake.created[0])
+	}
+	if app.ID == "" || app.Name != "Test Tenant - Portal" || app.ClientID != "DINEW" || app.ClientSecret != "new_secret" {
+		t.Errorf("CreateApplication() = %+v", app)
+	}
+
+	saved, err := p.Config.GetApplication(app.ID)
+	if err != nil {
+		t.Fatalf("GetApplication() error = %v", err)
+	}
+	if saved.TenantID != "tenant1" || saved.APIHostname != "api-test.duosecurity.com" || !saved.Enabled {
+		t.Errorf("Saved application = %+v", saved)
+	}
+}
+
+func TestCreateSSOApplications(t *testing.T) {
+	p, fake := newTestProvisioner(t)
+
+	samlApp, err := p.CreateApplication(Request{Name: "SAML", Type: "saml", TenantID: "tenant1", BaseURL: "https://uet.example.com/"})
+	if err != nil {
+		t.Fatalf("CreateApplication(saml) error = %v", err)
+	}
+	wantACS := "https://uet.example.com/app/" + samlApp.ID + "/saml/acs"
+	if samlApp.ACSURL != wantACS || fake.saml.ACSURL != wantACS {
+		t.Errorf("ACS URL = %s (sent %s), want %s", samlApp.ACSURL, fake.saml.ACSURL, wantACS)
+	}
+	if samlApp.ClientID != "DISAML" || samlApp.IDPSSOURL == "" || samlApp.IDPCertificate != "MIIC" {
+		t.Errorf("SAML application IdP metadata = %+v", samlApp)
+	}
+
+	oidcApp, err := p.CreateApplication(Request{Name: "OIDC", Type: "oidc", TenantID: "tenant1", BaseURL: "https://uet.example.com", Scopes: []string{"email"}, PKCEMode: "public"})
+	if err != nil {
+		t.Fatalf("CreateApplication(oidc) error = %v", err)
+	}
+	if oidcApp.RedirectURI != "https://uet.example.com/app/"+oidcApp.ID+"/oidc/callback" {
+		t.Errorf("Redirect URI = %s", oidcApp.RedirectURI)
+	}
+	if !fake.oidc.AllowPKCEOnly || oidcApp.PKCEMode != "public" || oidcApp.ClientSecret != "oidc_secret" {
+		t.Errorf("OIDC application = %+v, params = %+v", oidcApp, fake.oidc)
+	}
+}
+
+func TestCreateApplicationErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     Request
+		invalid bool
+		want    error
+	}{
+		{"missing name", Request{Type: "websdk", TenantID: "tenant1"}, false, ErrInvalidRequest},
+		{"unknown type", Request{Name: "App", Type: "ldap", TenantID: "tenant1"}, false, ErrInvalidRequest},
+		{"missing tenant", Request{Name: "App", Type: "websdk"}, false, ErrInvalidRequest},
+		{"unknown tenant", Request{Name: "App", Type: "websdk", TenantID: "nope"}, false, ErrInvalidRequest},
+		{"saml without base URL", Request{Name: "App", Type: "saml", TenantID: "tenant1"}, false, ErrInvalidRequest},
+		{"bad credentials", Request{Name: "App", Type: "websdk", TenantID: "tenant1"}, true, ErrCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, fake := newTestProvisioner(t)
+			fake.invalid = tt.invalid
+
+			_, err := p.CreateApplication(tt.req)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("CreateApplication() error = %v, want %v", err, tt.want)
+			}
+			if len(fake.created) != 0 {
+				t.Errorf("No integration should be created, got %v", fake.created)
+			}
+			if !strings.Contains(err.Error(), tt.want.Error()) {
+				t.Errorf("Error %q should include %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package saml
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+
+	"github.com/beevik/etree"
+	"github.com/russellhaering/gosaml2/types"
+	"github.com/russellhaering/goxmldsig/etreeutils"
+)
+
+// SupportedEncryptionMethods are the XML Encryption algorithms the SP can decrypt,
+// advertised on the encryption KeyDescriptor of the SP metadata
+var SupportedEncryptionMethods = []string{
+	types.MethodAES256GCM,
+	types.MethodAES128GCM,
+	types.MethodAES256CBC,
+	types.MethodAES128CBC,
+	types.MethodRSAOAEP,
+	types.MethodRSAOAEP2,
+}
+
+// EncryptionInfo describes the EncryptedAssertion found in a SAML response, if any
+type EncryptionInfo struct {
+	Encrypted     bool   `json:"encrypted"`
+	Count         int    `json:"count,omitempty"`
+	DataAlgorithm string `json:"data_algorithm,omitempty"`
+	KeyAlgorithm  string `json:"key_algorithm,omitempty"`
+	KeyDigest     string `json:"key_digest,omitempty"`
+}
+
+// DecryptResponse replaces every EncryptedAssertion in a base64 encoded SAML response
+// with its decrypted Assertion, using the SP key pair, and returns the re-encoded response.
+// Responses without encrypted assertions are returned unchanged.
+func DecryptResponse(encodedResponse string, cert *tls.Certificate) (string, *EncryptionInfo, error) {
+	info := &EncryptionInfo{}
+
+	raw, err := base64.StdEncoding.DecodeString(encodedResponse)
+	if err != nil {
+		return "", info, fmt.Errorf("SAMLResponse is not valid base64: %w", err)
+	}
+
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(raw); err != nil || doc.Root() == nil {
+		return "", info, fmt.Errorf("SAMLResponse is not valid XML: %v", err)
+	}
+	root := doc.Root()
+
+	type encryptedElement struct {
+		el        *etree.Element
+		decrypted *etree.Element
+	}
+	var found []encryptedElement
+
+	err = etreeutils.NSFindIterate(root, samlAssertionNamespace, "EncryptedAssertion", func(ctx etreeutils.NSContext, el *etree.Element) error {
+		if el.Parent() != root {
+			return fmt.Errorf("found EncryptedAssertion with unexpected parent element: %s", el.Parent().Tag)
+		}
+
+		encrypted, err := unmarshalEncryptedAssertion(ctx, el)
+		if err != nil {
+			return err
+		}
+
+		info.Count++
+		info.DataAlgorithm = encrypted.EncryptionMethod.Algorithm
+		key := encrypted.EncryptedKey
+		if key.CipherValue == "" {
+			key = encrypted.DetEncryptedKey
+		}
+		info.KeyAlgorithm = key.EncryptionMethod.Algorithm
+		if key.EncryptionMethod.DigestMethod != nil {
+			info.KeyDigest = key.EncryptionMethod.DigestMethod.Algorithm
+		}
+
+		if cert == nil {
+			return fmt.Errorf("response contains an encrypted assertion but no SP decryption key is available")
+		}
+
+		decrypted, err := decryptAssertion(encrypted, cert)
+		if err != nil {
+			return err
+		}
+		found = append(found, encryptedElement{el: el, decrypted: decrypted})
+		return nil
+	})
+	if err != nil {
+		return "", info, err
+	}
+
+	if len(found) == 0 {
+		return encodedResponse, info, nil
+	}
+	info.Encrypted = true
+
+	// Swap the elements once iteration is done so the tree is not modified while walking it
+	for _, f := range found {
+		root.RemoveChild(f.el)
+		root.AddChild(f.decrypted)
+	}
+
+	out, err := doc.WriteToBytes()
+	if err != nil {
+		return "", info, fmt.Errorf("failed to serialize decrypted response: %w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(out), info, nil
+}
+
+// unmarshalEncryptedAssertion parses an EncryptedAssertion element, keeping the
+// namespace declarations inherited from its parent
+func unmarshalEncryptedAssertion(ctx etreeutils.NSContext, el *etree.Element) (*types.EncryptedAssertion, error) {
+	detached, err := etreeutils.NSDetatch(ctx, el)
+	if err != nil {
+		return nil, fmt.Errorf("unable to detach encrypted assertion: %w", err)
+	}
+
+	doc := etree.NewDocument()
+	doc.SetRoot(detached)
+	data, err := doc.WriteToBytes()
+	if err != nil {
+		return nil, fmt.Errorf("unable to serialize encrypted assertion: %w", err)
+	}
+
+	encrypted := &types.EncryptedAssertion{}
+	if err := xml.Unmarshal(data, encrypted); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal encrypted assertion: %w", err)
+	}
+
+	return encrypted, nil
+}
+
+// decryptAssertion decrypts an EncryptedAssertion into a standalone Assertion element
+func decryptAssertion(encrypted *types.EncryptedAssertion, cert *tls.Certificate) (*etree.Element, error) {
+	plaintext, err := encrypted.DecryptBytes(cert)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt assertion: %w", err)
+	}
+
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(plaintext); err != nil || doc.Root() == nil {
+		return nil, fmt.Errorf("decrypted assertion is not valid XML: %v", err)
+	}
+
+	return doc.Root(), nil
+}
@@ -0,0 +1,170 @@
+package saml
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/beevik/etree"
+	"github.com/russellhaering/gosaml2/types"
+)
+
+// encryptResponseAssertion replaces the assertion of a base64 encoded response with an
+// EncryptedAssertion (AES-256-CBC, RSA-OAEP key transport) addressed to the SP key pair
+func encryptResponseAssertion(t *testing.T, encodedResponse string, sp *tls.Certificate) string {
+	t.Helper()
+
+	raw, err := base64.StdEncoding.DecodeString(encodedResponse)
+	if err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(raw); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	root := doc.Root()
+	assertion := root.FindElement("./Assertion")
+	if assertion == nil {
+		t.Fatal("response has no assertion")
+	}
+
+	assertionDoc := etree.NewDocument()
+	assertionDoc.SetRoot(assertion.Copy())
+	plaintext, err := assertionDoc.WriteToBytes()
+	if err != nil {
+		t.Fatalf("failed to serialize assertion: %v", err)
+	}
+
+	key := make([]byte, 32)
+	iv := make([]byte, aes.BlockSize)
+	rand.Read(key)
+	rand.Read(iv)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatalf("aes.NewCipher() error = %v", err)
+	}
+	padding := aes.BlockSize - len(plaintext)%aes.BlockSize
+	padded := append(plaintext, bytes.Repeat([]byte{byte(padding)}, padding)...)
+	ciphertext := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, padded)
+
+	encryptedKey, err := rsa.EncryptOAEP(sha1.New(), rand.Reader, &sp.PrivateKey.(*rsa.PrivateKey).PublicKey, key, nil)
+	if err != nil {
+		t.Fatalf("rsa.EncryptOAEP() error = %v", err)
+	}
+
+	encryptedXML := fmt.Sprintf(`<saml:EncryptedAssertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion">
+<xenc:EncryptedData xmlns:xenc="http://www.w3.org/2001/04/xmlenc#" Type="http://www.w3.org/2001/04/xmlenc#Element">
+<xenc:EncryptionMethod Algorithm="%s"/>
+<ds:KeyInfo xmlns:ds="http://www.w3.org/2000/09/xmldsig#">
+<xenc:EncryptedKey>
+<xenc:EncryptionMethod Algorithm="%s"><ds:DigestMethod Algorithm="%s"/></xenc:EncryptionMethod>
+<xenc:CipherData><xenc:CipherValue>%s</xenc:CipherValue></xenc:CipherData>
+</xenc:EncryptedKey>
+</ds:KeyInfo>
+<xenc:CipherData><xenc:CipherValue>%s</xenc:CipherValue></xenc:CipherData>
+</xenc:EncryptedData>
+</saml:EncryptedAssertion>`, types.MethodAES256CBC, types.MethodRSAOAEP, types.MethodSHA1,
+		base64.StdEncoding.EncodeToString(encryptedKey),
+		base64.StdEncoding.EncodeToString(append(iv, ciphertext...)))
+
+	encryptedDoc := etree.NewDocument()
+	if err := encryptedDoc.ReadFromString(encryptedXML); err != nil {
+		t.Fatalf("failed to parse encrypted assertion: %v", err)
+	}
+	root.RemoveChild(assertion)
+	root.AddChild(encryptedDoc.Root())
+
+	out, err := doc.WriteToBytes()
+	if err != nil {
+		t.Fatalf("failed to serialize response: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(out)
+}
+
+func newSPCertificate(t *testing.T) *tls.Certificate {
+	t.Helper()
+	cert, key, err := GenerateSelfSignedCert("sp.example.com")
+	if err != nil {
+		t.Fatalf("GenerateSelfSignedCert() error = %v", err)
+	}
+	return &tls.Certificate{Certificate: [][]byte{cert.Raw}, PrivateKey: key, Leaf: cert}
+}
+
+func TestDecryptResponse(t *testing.T) {
+	sp := newSPCertificate(t)
+	plain, _ := buildSignedResponse(t, true, false)
+	encrypted := encryptResponseAssertion(t, plain, sp)
+
+	t.Run("decrypts encrypted assertion", func(t *testing.T) {
+		decrypted, info, err := DecryptResponse(encrypted, sp)
+		if err != nil {
+			t.Fatalf("DecryptResponse() error = %v", err)
+		}
+		if !info.Encrypted || info.Count != 1 {
+			t.Errorf("EncryptionInfo = %+v, want one encrypted assertion", info)
+		}
+		if info.DataAlgorithm != types.MethodAES256CBC || info.KeyAlgorithm != types.MethodRSAOAEP {
+			t.Errorf("EncryptionInfo algorithms = %s / %s", info.DataAlgorithm, info.KeyAlgorithm)
+		}
+
+		raw, _ := base64.StdEncoding.DecodeString(decrypted)
+		if !strings.Contains(string(raw), "user@example.com") || strings.Contains(string(raw), "EncryptedAssertion") {
+			t.Errorf("decrypted response does not contain the plain assertion:\n%s", raw)
+		}
+	})
+
+	t.Run("plain response unchanged", func(t *testing.T) {
+		out, info, err := DecryptResponse(plain, sp)
+		if err != nil {
+			t.Fatalf("DecryptResponse() error = %v", err)
+		}
+		if info.Encrypted || out != plain {
+			t.Error("plain response should be returned unchanged")
+		}
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		if _, _, err := DecryptResponse(encrypted, newSPCertificate(t)); err == nil {
+			t.Error("DecryptResponse() with the wrong key should fail")
+		}
+	})
+
+	t.Run("no key", func(t *testing.T) {
+		if _, _, err := DecryptResponse(encrypted, nil); err == nil {
+			t.Error("DecryptResponse() without a key should fail")
+		}
+	})
+}
+
+func TestVerifySignaturesWithDecryption(t *testing.T) {
+	sp := newSPCertificate(t)
+	plain, certPEM := buildSignedResponse(t, true, false)
+	encrypted := encryptResponseAssertion(t, plain, sp)
+	certs, err := ParseIDPCertificates(certPEM)
+	if err != nil {
+		t.Fatalf("ParseIDPCertificates() error = %v", err)
+	}
+
+	report := VerifySignaturesWithDecryption(encrypted, certs, true, sp)
+	if check := findCheck(report, "Assertion signature"); check == nil || !check.Passed() {
+		t.Errorf("Assertion signature check = %+v, want pass", check)
+	}
+	if !report.Valid() {
+		t.Errorf("report should be valid, failures: %+v", report.Failures())
+	}
+
+	// Without the SP key the encrypted assertion cannot be inspected
+	report = VerifySignatures(encrypted, certs, true)
+	if check := findCheck(report, "Assertion signature"); check == nil || check.Status != CheckSkipped {
+		t.Errorf("Assertion signature check = %+v, want skipped", check)
+	}
+}
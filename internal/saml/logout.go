@@ -0,0 +1,79 @@
+package saml
+
+import (
+	"encoding/base64"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+const (
+	// LogoutSPInitiated, LogoutIdPInitiated and LogoutLocal describe who started a logout
+	LogoutSPInitiated  = "sp-initiated"
+	LogoutIdPInitiated = "idp-initiated"
+	LogoutLocal        = "local"
+)
+
+// LogoutExchange captures the messages and checks of a Single Logout round trip
+// so they can be shown on the logout result page
+type LogoutExchange struct {
+	Direction    string            `json:"direction"`
+	NameID       string            `json:"name_id,omitempty"`
+	SessionIndex string            `json:"session_index,omitempty"`
+	RequestID    string            `json:"request_id,omitempty"`
+	RequestXML   string            `json:"request_xml,omitempty"`
+	ResponseXML  string            `json:"response_xml,omitempty"`
+	Status       string            `json:"status,omitempty"`
+	Note         string            `json:"note,omitempty"`
+	Checks       []ValidationCheck `json:"checks,omitempty"`
+}
+
+// Valid reports whether every logout check passed or was skipped
+func (e *LogoutExchange) Valid() bool {
+	if e == nil {
+		return false
+	}
+	return len(failedChecks(e.Checks)) == 0
+}
+
+// IndentXML pretty-prints an XML document for display. Input that cannot be parsed
+// is returned as is.
+func IndentXML(raw []byte) string {
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(raw); err != nil || doc.Root() == nil {
+		return string(raw)
+	}
+	doc.Indent(2)
+
+	out, err := doc.WriteToString()
+	if err != nil {
+		return string(raw)
+	}
+	return strings.TrimSpace(out)
+}
+
+// DocumentXML serializes and pretty-prints an etree document for display
+func DocumentXML(doc *etree.Document) string {
+	raw, err := doc.WriteToBytes()
+	if err != nil {
+		return ""
+	}
+	return IndentXML(raw)
+}
+
+// ResponseNameIDFormat returns the Format of the assertion NameID in a base64 encoded
+// (and already decrypted) SAML response, so a later LogoutRequest can echo it
+func ResponseNameIDFormat(encodedResponse string) string {
+	raw, err := base64.StdEncoding.DecodeString(encodedResponse)
+	if err != nil {
+		return ""
+	}
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(raw); err != nil {
+		return ""
+	}
+	if nameID := doc.FindElement("//Assertion/Subject/NameID"); nameID != nil {
+		return nameID.SelectAttrValue("Format", "")
+	}
+	return ""
+}
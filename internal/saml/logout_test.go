@@ -0,0 +1,70 @@
+package saml
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+const testLogoutRequest = `<samlp:LogoutRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_lr1" Version="2.0"><saml:NameID>user@example.com</saml:NameID></samlp:LogoutRequest>`
+
+func TestDecodeMessage(t *testing.T) {
+	var deflated bytes.Buffer
+	w, err := flate.NewWriter(&deflated, flate.BestCompression)
+	if err != nil {
+		t.Fatalf("flate.NewWriter() error = %v", err)
+	}
+	w.Write([]byte(testLogoutRequest))
+	w.Close()
+
+	tests := []struct {
+		name    string
+		encoded string
+		wantErr bool
+	}{
+		{"post binding", base64.StdEncoding.EncodeToString([]byte(testLogoutRequest)), false},
+		{"redirect binding", base64.StdEncoding.EncodeToString(deflated.Bytes()), false},
+		{"invalid base64", "not base64!", true},
+		{"garbage", base64.StdEncoding.EncodeToString([]byte{0xff, 0xfe, 0xfd}), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeMessage(tt.encoded)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecodeMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && string(got) != testLogoutRequest {
+				t.Errorf("DecodeMessage() = %q, want %q", got, testLogoutRequest)
+			}
+		})
+	}
+}
+
+func TestIndentXML(t *testing.T) {
+	got := IndentXML([]byte(testLogoutRequest))
+	if !strings.Contains(got, "\n  <saml:NameID>user@example.com</saml:NameID>") {
+		t.Errorf("IndentXML() did not indent child elements:\n%s", got)
+	}
+
+	if got := IndentXML([]byte("not xml")); got != "not xml" {
+		t.Errorf("IndentXML() on invalid input = %q, want input unchanged", got)
+	}
+}
+
+func TestResponseNameIDFormat(t *testing.T) {
+	response := `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion">` +
+		`<saml:Assertion><saml:Subject><saml:NameID Format="urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress">user@example.com</saml:NameID></saml:Subject></saml:Assertion>` +
+		`</samlp:Response>`
+
+	got := ResponseNameIDFormat(base64.StdEncoding.EncodeToString([]byte(response)))
+	if want := "urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress"; got != want {
+		t.Errorf("ResponseNameIDFormat() = %q, want %q", got, want)
+	}
+
+	if got := ResponseNameIDFormat("invalid"); got != "" {
+		t.Errorf("ResponseNameIDFormat() on invalid input = %q, want empty", got)
+	}
+}
@@ -0,0 +1,35 @@
+package saml
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"fmt"
+	"io"
+)
+
+// maxInflatedMessageSize caps the size of a DEFLATE encoded redirect binding message
+const maxInflatedMessageSize = 1 << 20
+
+// DecodeMessage decodes a SAML protocol message received over the POST binding (base64)
+// or the redirect binding (base64 of DEFLATE compressed XML)
+func DecodeMessage(encoded string) ([]byte, error) {
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("message is not valid base64: %w", err)
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(raw), []byte("<")) {
+		return raw, nil
+	}
+
+	inflated, err := io.ReadAll(io.LimitReader(flate.NewReader(bytes.NewReader(raw)), maxInflatedMessageSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("message is neither XML nor DEFLATE encoded: %w", err)
+	}
+	if len(inflated) > maxInflatedMessageSize {
+		return nil, fmt.Errorf("inflated message exceeds %d bytes", maxInflatedMessageSize)
+	}
+
+	return inflated, nil
+}
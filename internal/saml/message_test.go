@@ -0,0 +1,43 @@
+package saml
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"testing"
+)
+
+const testAuthnRequest = `<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_request-1" Version="2.0"></samlp:AuthnRequest>`
+
+func TestDecodeMessageBindings(t *testing.T) {
+	var deflated bytes.Buffer
+	w, err := flate.NewWriter(&deflated, flate.DefaultCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte(testAuthnRequest))
+	w.Close()
+
+	tests := []struct {
+		name    string
+		encoded string
+		wantErr bool
+	}{
+		{"post binding", base64.StdEncoding.EncodeToString([]byte(testAuthnRequest)), false},
+		{"redirect binding", base64.StdEncoding.EncodeToString(deflated.Bytes()), false},
+		{"not base64", "%%%", true},
+		{"not deflate", base64.StdEncoding.EncodeToString([]byte("garbage")), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeMessage(tt.encoded)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecodeMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && string(got) != testAuthnRequest {
+				t.Errorf("DecodeMessage() = %q, want %q", got, testAuthnRequest)
+			}
+		})
+	}
+}
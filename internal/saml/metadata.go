@@ -0,0 +1,189 @@
+package saml
+
+import (
+	"context"
+	"encoding/pem"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	saml2 "github.com/russellhaering/gosaml2"
+	"github.com/russellhaering/gosaml2/types"
+)
+
+const (
+	// MaxMetadataSize caps the size of IdP metadata accepted from a URL or upload
+	MaxMetadataSize = 1 << 20
+
+	// metadataFetchTimeout bounds how long fetching IdP metadata by URL may take
+	metadataFetchTimeout = 15 * time.Second
+)
+
+// IDPMetadata holds the IdP settings extracted from a SAML metadata document
+type IDPMetadata struct {
+	EntityID     string            `json:"entity_id"`
+	SSOURL       string            `json:"sso_url"`
+	SSOBinding   string            `json:"sso_binding"`
+	SLOURL       string            `json:"slo_url,omitempty"`
+	SLOBinding   string            `json:"slo_binding,omitempty"`
+	Certificates []CertificateInfo `json:"certificates"`
+
+	// CertificatePEM contains every signing certificate, PEM encoded and concatenated,
+	// in the form stored on the application's idp_certificate field
+	CertificatePEM string `json:"-"`
+}
+
+// entitiesDescriptor is an aggregate metadata document wrapping several entities
+type entitiesDescriptor struct {
+	XMLName           xml.Name                 `xml:"urn:oasis:names:tc:SAML:2.0:metadata EntitiesDescriptor"`
+	EntityDescriptors []types.EntityDescriptor `xml:"EntityDescriptor"`
+}
+
+// ParseIDPMetadata extracts the entity ID, SSO/SLO endpoints and signing certificates
+// from an IdP EntityDescriptor. Aggregate EntitiesDescriptor documents are accepted
+// and the first entity with an IDPSSODescriptor is used.
+func ParseIDPMetadata(data []byte) (*IDPMetadata, error) {
+	if len(data) > MaxMetadataSize {
+		return nil, fmt.Errorf("metadata exceeds %d bytes", MaxMetadataSize)
+	}
+
+	entity, err := findIDPEntity(data)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := &IDPMetadata{EntityID: strings.TrimSpace(entity.EntityID)}
+	if metadata.EntityID == "" {
+		return nil, fmt.Errorf("metadata EntityDescriptor has no entityID")
+	}
+
+	idp := entity.IDPSSODescriptor
+	for _, binding := range []string{saml2.BindingHttpRedirect, saml2.BindingHttpPost} {
+		if metadata.SSOURL == "" {
+			for _, svc := range idp.SingleSignOnServices {
+				if svc.Binding == binding && svc.Location != "" {
+					metadata.SSOURL, metadata.SSOBinding = svc.Location, svc.Binding
+					break
+				}
+			}
+		}
+		if metadata.SLOURL == "" {
+			for _, svc := range idp.SingleLogoutServices {
+				if svc.Binding == binding && svc.Location != "" {
+					metadata.SLOURL, metadata.SLOBinding = svc.Location, svc.Binding
+					break
+				}
+			}
+		}
+	}
+	if metadata.SSOURL == "" {
+		return nil, fmt.Errorf("IDPSSODescriptor has no SingleSignOnService with an HTTP-Redirect or HTTP-POST binding")
+	}
+
+	var pemBlocks strings.Builder
+	seen := make(map[string]bool)
+	for _, kd := range idp.KeyDescriptors {
+		// Keys without a use attribute are valid for both signing and encryption
+		if kd.Use != "" && kd.Use != "signing" {
+			continue
+		}
+		for _, x509Cert := range kd.KeyInfo.X509Data.X509Certificates {
+			data := strings.Join(strings.Fields(x509Cert.Data), "")
+			if data == "" || seen[data] {
+				continue
+			}
+			seen[data] = true
+
+			certs, err := ParseIDPCertificates(data)
+			if err != nil {
+				return nil, fmt.Errorf("invalid signing certificate in metadata: %w", err)
+			}
+			for _, cert := range certs {
+				metadata.Certificates = append(metadata.Certificates, DescribeCertificate(cert))
+				pem.Encode(&pemBlocks, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+			}
+		}
+	}
+	if len(metadata.Certificates) == 0 {
+		return nil, fmt.Errorf("IDPSSODescriptor has no signing certificate")
+	}
+	metadata.CertificatePEM = pemBlocks.String()
+
+	return metadata, nil
+}
+
+// findIDPEntity returns the first EntityDescriptor carrying an IDPSSODescriptor
+func findIDPEntity(data []byte) (*types.EntityDescriptor, error) {
+	var root struct {
+		XMLName xml.Name
+	}
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("metadata is not valid XML: %w", err)
+	}
+
+	var candidates []types.EntityDescriptor
+	switch root.XMLName.Local {
+	case "EntityDescriptor":
+		var entity types.EntityDescriptor
+		if err := xml.Unmarshal(data, &entity); err != nil {
+			return nil, fmt.Errorf("failed to parse EntityDescriptor: %w", err)
+		}
+		candidates = append(candidates, entity)
+	case "EntitiesDescriptor":
+		var entities entitiesDescriptor
+		if err := xml.Unmarshal(data, &entities); err != nil {
+			return nil, fmt.Errorf("failed to parse EntitiesDescriptor: %w", err)
+		}
+		candidates = entities.EntityDescriptors
+	default:
+		return nil, fmt.Errorf("unexpected metadata root element %q, want EntityDescriptor", root.XMLName.Local)
+	}
+
+	for i := range candidates {
+		if candidates[i].IDPSSODescriptor != nil {
+			return &candidates[i], nil
+		}
+	}
+	return nil, fmt.Errorf("metadata does not describe an identity provider (no IDPSSODescriptor)")
+}
+
+// FetchIDPMetadata downloads an IdP metadata document over HTTP(S)
+func FetchIDPMetadata(ctx context.Context, metadataURL string) ([]byte, error) {
+	parsed, err := url.Parse(metadataURL)
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+		return nil, fmt.Errorf("metadata URL must be an absolute http(s) URL")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, metadataFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadataURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metadata request: %w", err)
+	}
+	req.Header.Set("Accept", "application/samlmetadata+xml, application/xml, text/xml")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch metadata: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("metadata URL returned HTTP %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxMetadataSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata: %w", err)
+	}
+	if len(data) > MaxMetadataSize {
+		return nil, fmt.Errorf("metadata exceeds %d bytes", MaxMetadataSize)
+	}
+
+	return data, nil
+}
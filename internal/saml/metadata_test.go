@@ -0,0 +1,143 @@
+package saml
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func buildIDPMetadata(t *testing.T, withSLO bool) (string, int) {
+	t.Helper()
+
+	var keys strings.Builder
+	for i, use := range []string{"signing", "", "encryption"} {
+		cert, _, err := GenerateSelfSignedCert(fmt.Sprintf("idp-%d", i))
+		if err != nil {
+			t.Fatalf("GenerateSelfSignedCert() error = %v", err)
+		}
+		useAttr := ""
+		if use != "" {
+			useAttr = fmt.Sprintf(` use="%s"`, use)
+		}
+		fmt.Fprintf(&keys, `<md:KeyDescriptor%s><ds:KeyInfo><ds:X509Data><ds:X509Certificate>%s</ds:X509Certificate></ds:X509Data></ds:KeyInfo></md:KeyDescriptor>`,
+			useAttr, base64.StdEncoding.EncodeToString(cert.Raw))
+	}
+
+	slo := ""
+	if withSLO {
+		slo = `<md:SingleLogoutService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST" Location="https://idp.example.com/slo/post"/>` +
+			`<md:SingleLogoutService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="https://idp.example.com/slo"/>`
+	}
+
+	metadata := `<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" xmlns:ds="http://www.w3.org/2000/09/xmldsig#" entityID="https://idp.example.com/metadata">` +
+		`<md:IDPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">` + keys.String() + slo +
+		`<md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST" Location="https://idp.example.com/sso/post"/>` +
+		`<md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="https://idp.example.com/sso"/>` +
+		`</md:IDPSSODescriptor></md:EntityDescriptor>`
+
+	// The encryption-only key is not a signing certificate
+	return metadata, 2
+}
+
+func TestParseIDPMetadata(t *testing.T) {
+	data, wantCerts := buildIDPMetadata(t, true)
+
+	metadata, err := ParseIDPMetadata([]byte(data))
+	if err != nil {
+		t.Fatalf("ParseIDPMetadata() error = %v", err)
+	}
+
+	if metadata.EntityID != "https://idp.example.com/metadata" {
+		t.Errorf("EntityID = %q", metadata.EntityID)
+	}
+	// The redirect binding is preferred for both endpoints
+	if metadata.SSOURL != "https://idp.example.com/sso" {
+		t.Errorf("SSOURL = %q, want redirect endpoint", metadata.SSOURL)
+	}
+	if metadata.SLOURL != "https://idp.example.com/slo" {
+		t.Errorf("SLOURL = %q, want redirect endpoint", metadata.SLOURL)
+	}
+	if len(metadata.Certificates) != wantCerts {
+		t.Fatalf("len(Certificates) = %d, want %d", len(metadata.Certificates), wantCerts)
+	}
+
+	certs, err := ParseIDPCertificates(metadata.CertificatePEM)
+	if err != nil {
+		t.Fatalf("ParseIDPCertificates(CertificatePEM) error = %v", err)
+	}
+	if len(certs) != wantCerts {
+		t.Errorf("CertificatePEM holds %d certificates, want %d", len(certs), wantCerts)
+	}
+}
+
+func TestParseIDPMetadataAggregate(t *testing.T) {
+	data, _ := buildIDPMetadata(t, false)
+	aggregate := `<md:EntitiesDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata">` +
+		`<md:EntityDescriptor entityID="https://sp.example.com"><md:SPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol"/></md:EntityDescriptor>` +
+		data + `</md:EntitiesDescriptor>`
+
+	metadata, err := ParseIDPMetadata([]byte(aggregate))
+	if err != nil {
+		t.Fatalf("ParseIDPMetadata() error = %v", err)
+	}
+	if metadata.EntityID != "https://idp.example.com/metadata" {
+		t.Errorf("EntityID = %q, want the IdP entity", metadata.EntityID)
+	}
+	if metadata.SLOURL != "" {
+		t.Errorf("SLOURL = %q, want empty", metadata.SLOURL)
+	}
+}
+
+func TestParseIDPMetadataErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"not xml", "not xml"},
+		{"wrong root", `<foo/>`},
+		{"sp only", `<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" entityID="https://sp.example.com"><md:SPSSODescriptor/></md:EntityDescriptor>`},
+		{"no sso", `<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" entityID="https://idp.example.com"><md:IDPSSODescriptor/></md:EntityDescriptor>`},
+		{"no certificate", `<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" entityID="https://idp.example.com"><md:IDPSSODescriptor>` +
+			`<md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="https://idp.example.com/sso"/></md:IDPSSODescriptor></md:EntityDescriptor>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseIDPMetadata([]byte(tt.data)); err == nil {
+				t.Error("ParseIDPMetadata() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestFetchIDPMetadata(t *testing.T) {
+	data, _ := buildIDPMetadata(t, true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/metadata" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/samlmetadata+xml")
+		w.Write([]byte(data))
+	}))
+	defer server.Close()
+
+	got, err := FetchIDPMetadata(context.Background(), server.URL+"/metadata")
+	if err != nil {
+		t.Fatalf("FetchIDPMetadata() error = %v", err)
+	}
+	if string(got) != data {
+		t.Error("FetchIDPMetadata() returned unexpected body")
+	}
+
+	if _, err := FetchIDPMetadata(context.Background(), server.URL+"/missing"); err == nil {
+		t.Error("FetchIDPMetadata() on 404 error = nil, want error")
+	}
+	if _, err := FetchIDPMetadata(context.Background(), "file:///etc/passwd"); err == nil {
+		t.Error("FetchIDPMetadata() with file URL error = nil, want error")
+	}
+}
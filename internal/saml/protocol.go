@@ -0,0 +1,255 @@
+package saml
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/russellhaering/gosaml2/types"
+)
+
+// defaultReplayWindow is how long an assertion ID is remembered when the assertion
+// carries no usable NotOnOrAfter condition
+const defaultReplayWindow = time.Hour
+
+// ProtocolOptions controls the protocol level checks performed on a SAML response
+type ProtocolOptions struct {
+	// ExpectedRequestID is the AuthnRequest ID stored in the session, empty if none is pending
+	ExpectedRequestID string
+	// AllowIdPInitiated accepts unsolicited responses that carry no InResponseTo
+	AllowIdPInitiated bool
+	// ClockSkew is the tolerance applied to NotBefore/NotOnOrAfter conditions
+	ClockSkew time.Duration
+	// ReplayCache records consumed assertion IDs; replay detection is skipped when nil
+	ReplayCache *ReplayCache
+	// Now overrides the current time (used by tests)
+	Now time.Time
+}
+
+// ProtocolReport summarizes the InResponseTo, replay and timing checks of a SAML response
+type ProtocolReport struct {
+	RequestID    string            `json:"request_id,omitempty"`
+	InResponseTo string            `json:"in_response_to,omitempty"`
+	AssertionID  string            `json:"assertion_id,omitempty"`
+	IdPInitiated bool              `json:"idp_initiated"`
+	ClockSkew    string            `json:"clock_skew"`
+	Checks       []ValidationCheck `json:"checks"`
+}
+
+// Valid reports whether every protocol check passed or was skipped
+func (r *ProtocolReport) Valid() bool {
+	if r == nil || len(r.Checks) == 0 {
+		return false
+	}
+	return len(r.Failures()) == 0
+}
+
+// Failures returns the checks that failed
+func (r *ProtocolReport) Failures() []ValidationCheck {
+	if r == nil {
+		return nil
+	}
+	return failedChecks(r.Checks)
+}
+
+// VerifyProtocol checks a SAML response against the pending AuthnRequest, the replay
+// cache and the assertion validity window. The assertion is passed separately so that
+// decrypted assertions can be checked the same way as plain ones.
+func VerifyProtocol(response *types.Response, assertion *types.Assertion, opts ProtocolOptions) *ProtocolReport {
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	report := &ProtocolReport{
+		RequestID: opts.ExpectedRequestID,
+		ClockSkew: opts.ClockSkew.String(),
+	}
+	if response != nil {
+		report.InResponseTo = response.InResponseTo
+	}
+	if assertion != nil {
+		report.AssertionID = assertion.ID
+	}
+
+	report.Checks = append(report.Checks, checkInResponseTo(report, assertion, opts))
+
+	if assertion == nil {
+		report.Checks = append(report.Checks, ValidationCheck{
+			Name:   "Assertion present",
+			Status: CheckFail,
+			Detail: "Response does not contain an assertion to validate",
+		})
+		return report
+	}
+
+	report.Checks = append(report.Checks, checkConditions(assertion.Conditions, now, opts.ClockSkew))
+	report.Checks = append(report.Checks, checkSubjectConfirmation(assertion.Subject, now, opts.ClockSkew))
+
+	// Only a response that passed every other check may consume its assertion ID, so a
+	// forged or unsolicited post cannot burn the ID of a legitimate assertion
+	record := len(failedChecks(report.Checks)) == 0
+	report.Checks = append(report.Checks, checkReplay(assertion, now, opts, record))
+
+	return report
+}
+
+// checkInResponseTo matches the response (and its subject confirmation) against the
+// AuthnRequest ID stored in the session
+func checkInResponseTo(report *ProtocolReport, assertion *types.Assertion, opts ProtocolOptions) ValidationCheck {
+	check := ValidationCheck{Name: "InResponseTo"}
+
+	inResponseTo := report.InResponseTo
+	if assertion != nil && assertion.Subject != nil && assertion.Subject.SubjectConfirmation != nil &&
+		assertion.Subject.SubjectConfirmation.SubjectConfirmationData != nil {
+		confirmationID := assertion.Subject.SubjectConfirmation.SubjectConfirmationData.InResponseTo
+		if inResponseTo == "" {
+			inResponseTo = confirmationID
+			report.InResponseTo = confirmationID
+		} else if confirmationID != "" && confirmationID != inResponseTo {
+			check.Status = CheckFail
+			check.Detail = fmt.Sprintf("Response InResponseTo %q does not match SubjectConfirmationData InResponseTo %q",
+				inResponseTo, confirmationID)
+			return check
+		}
+	}
+
+	switch {
+	case inResponseTo == "":
+		report.IdPInitiated = true
+		if opts.AllowIdPInitiated {
+			check.Status = CheckPass
+			check.Detail = "Unsolicited (IdP-initiated) response accepted by configuration"
+		} else {
+			check.Status = CheckFail
+			check.Detail = "Response is unsolicited (IdP-initiated) and IdP-initiated login is not allowed"
+		}
+	case opts.ExpectedRequestID == "":
+		check.Status = CheckFail
+		check.Detail = fmt.Sprintf("Response answers request %s but no AuthnRequest is pending in this session", inResponseTo)
+	case inResponseTo != opts.ExpectedRequestID:
+		check.Status = CheckFail
+		check.Detail = fmt.Sprintf("Response answers request %s, expected %s", inResponseTo, opts.ExpectedRequestID)
+	default:
+		check.Status = CheckPass
+		check.Detail = fmt.Sprintf("Matches AuthnRequest %s", inResponseTo)
+	}
+
+	return check
+}
+
+// checkConditions verifies the assertion NotBefore/NotOnOrAfter window with clock skew applied
+func checkConditions(conditions *types.Conditions, now time.Time, skew time.Duration) ValidationCheck {
+	check := ValidationCheck{Name: "Assertion validity window"}
+
+	if conditions == nil {
+		check.Status = CheckFail
+		check.Detail = "Assertion has no Conditions element"
+		return check
+	}
+
+	notBefore, err := time.Parse(time.RFC3339, conditions.NotBefore)
+	if err != nil {
+		check.Status = CheckFail
+		check.Detail = fmt.Sprintf("Invalid NotBefore %q", conditions.NotBefore)
+		return check
+	}
+	notOnOrAfter, err := time.Parse(time.RFC3339, conditions.NotOnOrAfter)
+	if err != nil {
+		check.Status = CheckFail
+		check.Detail = fmt.Sprintf("Invalid NotOnOrAfter %q", conditions.NotOnOrAfter)
+		return check
+	}
+
+	switch {
+	case now.Add(skew).Before(notBefore):
+		check.Status = CheckFail
+		check.Detail = fmt.Sprintf("Assertion not valid before %s (now %s, skew %s)",
+			notBefore.Format(time.RFC3339), now.UTC().Format(time.RFC3339), skew)
+	case !now.Add(-skew).Before(notOnOrAfter):
+		check.Status = CheckFail
+		check.Detail = fmt.Sprintf("Assertion expired at %s (now %s, skew %s)",
+			notOnOrAfter.Format(time.RFC3339), now.UTC().Format(time.RFC3339), skew)
+	default:
+		check.Status = CheckPass
+		check.Detail = fmt.Sprintf("Valid from %s to %s (skew %s)",
+			notBefore.Format(time.RFC3339), notOnOrAfter.Format(time.RFC3339), skew)
+	}
+
+	return check
+}
+
+// checkSubjectConfirmation verifies the bearer confirmation NotOnOrAfter with clock skew applied
+func checkSubjectConfirmation(subject *types.Subject, now time.Time, skew time.Duration) ValidationCheck {
+	check := ValidationCheck{Name: "Subject confirmation"}
+
+	if subject == nil || subject.SubjectConfirmation == nil || subject.SubjectConfirmation.SubjectConfirmationData == nil {
+		check.Status = CheckFail
+		check.Detail = "Assertion has no SubjectConfirmationData"
+		return check
+	}
+
+	data := subject.SubjectConfirmation.SubjectConfirmationData
+	notOnOrAfter, err := time.Parse(time.RFC3339, data.NotOnOrAfter)
+	if err != nil {
+		check.Status = CheckFail
+		check.Detail = fmt.Sprintf("Invalid SubjectConfirmationData NotOnOrAfter %q", data.NotOnOrAfter)
+		return check
+	}
+
+	if !now.Add(-skew).Before(notOnOrAfter) {
+		check.Status = CheckFail
+		check.Detail = fmt.Sprintf("Subject confirmation expired at %s (now %s, skew %s)",
+			notOnOrAfter.Format(time.RFC3339), now.UTC().Format(time.RFC3339), skew)
+		return check
+	}
+
+	check.Status = CheckPass
+	check.Detail = fmt.Sprintf("Bearer confirmation for %s valid until %s", data.Recipient, notOnOrAfter.Format(time.RFC3339))
+	return check
+}
+
+// checkReplay fails if the assertion ID has already been consumed. The ID is only
+// recorded as consumed when record is set.
+func checkReplay(assertion *types.Assertion, now time.Time, opts ProtocolOptions, record bool) ValidationCheck {
+	check := ValidationCheck{Name: "Assertion replay"}
+
+	if opts.ReplayCache == nil {
+		check.Status = CheckSkipped
+		check.Detail = "Replay detection is disabled"
+		return check
+	}
+
+	if assertion.ID == "" {
+		check.Status = CheckFail
+		check.Detail = "Assertion has no ID"
+		return check
+	}
+
+	if !record {
+		if opts.ReplayCache.Seen(assertion.ID, now) {
+			check.Status = CheckFail
+			check.Detail = fmt.Sprintf("Assertion %s has already been consumed", assertion.ID)
+			return check
+		}
+		check.Status = CheckSkipped
+		check.Detail = fmt.Sprintf("Assertion %s not recorded because other checks failed", assertion.ID)
+		return check
+	}
+
+	expires := now.Add(defaultReplayWindow)
+	if assertion.Conditions != nil {
+		if notOnOrAfter, err := time.Parse(time.RFC3339, assertion.Conditions.NotOnOrAfter); err == nil {
+			expires = notOnOrAfter.Add(opts.ClockSkew)
+		}
+	}
+
+	if !opts.ReplayCache.Use(assertion.ID, expires, now) {
+		check.Status = CheckFail
+		check.Detail = fmt.Sprintf("Assertion %s has already been consumed", assertion.ID)
+		return check
+	}
+
+	check.Status = CheckPass
+	check.Detail = fmt.Sprintf("Assertion %s seen for the first time", assertion.ID)
+	return check
+}
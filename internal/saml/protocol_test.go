@@ -0,0 +1,184 @@
+package saml
+
+import (
+	"testing"
+	"time"
+
+	"github.com/russellhaering/gosaml2/types"
+)
+
+// buildProtocolAssertion returns a response/assertion pair answering requestID,
+// valid for five minutes from issued
+func buildProtocolAssertion(id, requestID string, issued time.Time) (*types.Response, *types.Assertion) {
+	response := &types.Response{ID: "_resp-" + id, InResponseTo: requestID}
+	assertion := &types.Assertion{
+		ID: id,
+		Conditions: &types.Conditions{
+			NotBefore:    issued.UTC().Format(time.RFC3339),
+			NotOnOrAfter: issued.Add(5 * time.Minute).UTC().Format(time.RFC3339),
+		},
+		Subject: &types.Subject{
+			SubjectConfirmation: &types.SubjectConfirmation{
+				Method: "urn:oasis:names:tc:SAML:2.0:cm:bearer",
+				SubjectConfirmationData: &types.SubjectConfirmationData{
+					NotOnOrAfter: issued.Add(5 * time.Minute).UTC().Format(time.RFC3339),
+					Recipient:    "http://sp.example.com/acs",
+					InResponseTo: requestID,
+				},
+			},
+		},
+	}
+	return response, assertion
+}
+
+func findProtocolCheck(report *ProtocolReport, name string) *ValidationCheck {
+	for i := range report.Checks {
+		if report.Checks[i].Name == name {
+			return &report.Checks[i]
+		}
+	}
+	return nil
+}
+
+func TestVerifyProtocolInResponseTo(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name              string
+		inResponseTo      string
+		expected          string
+		allowIdPInitiated bool
+		wantStatus        string
+		wantIdPInitiated  bool
+	}{
+		{name: "matches pending request", inResponseTo: "_req1", expected: "_req1", wantStatus: CheckPass},
+		{name: "mismatched request", inResponseTo: "_other", expected: "_req1", wantStatus: CheckFail},
+		{name: "no pending request", inResponseTo: "_req1", wantStatus: CheckFail},
+		{name: "idp-initiated rejected", wantStatus: CheckFail, wantIdPInitiated: true},
+		{name: "idp-initiated allowed", allowIdPInitiated: true, wantStatus: CheckPass, wantIdPInitiated: true},
+		{name: "idp-initiated with pending request", expected: "_req1", allowIdPInitiated: true, wantStatus: CheckPass, wantIdPInitiated: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, assertion := buildProtocolAssertion("_assert-"+tt.name, tt.inResponseTo, now)
+			report := VerifyProtocol(response, assertion, ProtocolOptions{
+				ExpectedRequestID: tt.expected,
+				AllowIdPInitiated: tt.allowIdPInitiated,
+				Now:               now,
+			})
+
+			check := findProtocolCheck(report, "InResponseTo")
+			if check == nil {
+				t.Fatal("InResponseTo check missing")
+			}
+			if check.Status != tt.wantStatus {
+				t.Errorf("InResponseTo status = %s, want %s (%s)", check.Status, tt.wantStatus, check.Detail)
+			}
+			if report.IdPInitiated != tt.wantIdPInitiated {
+				t.Errorf("IdPInitiated = %v, want %v", report.IdPInitiated, tt.wantIdPInitiated)
+			}
+		})
+	}
+
+	t.Run("subject confirmation disagrees with response", func(t *testing.T) {
+		response, assertion := buildProtocolAssertion("_assert-sc", "_req1", now)
+		assertion.Subject.SubjectConfirmation.SubjectConfirmationData.InResponseTo = "_other"
+		report := VerifyProtocol(response, assertion, ProtocolOptions{ExpectedRequestID: "_req1", Now: now})
+		if check := findProtocolCheck(report, "InResponseTo"); check == nil || !check.Failed() {
+			t.Errorf("InResponseTo check = %+v, want failure", check)
+		}
+	})
+}
+
+func TestVerifyProtocolClockSkew(t *testing.T) {
+	issued := time.Now().Truncate(time.Second)
+
+	tests := []struct {
+		name   string
+		now    time.Time
+		skew   time.Duration
+		wantOK bool
+	}{
+		{name: "within window", now: issued.Add(time.Minute), wantOK: true},
+		{name: "not yet valid", now: issued.Add(-30 * time.Second), wantOK: false},
+		{name: "not yet valid within skew", now: issued.Add(-30 * time.Second), skew: time.Minute, wantOK: true},
+		{name: "expired", now: issued.Add(6 * time.Minute), wantOK: false},
+		{name: "expired within skew", now: issued.Add(6 * time.Minute), skew: 2 * time.Minute, wantOK: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, assertion := buildProtocolAssertion("_assert-"+tt.name, "_req1", issued)
+			report := VerifyProtocol(response, assertion, ProtocolOptions{
+				ExpectedRequestID: "_req1",
+				ClockSkew:         tt.skew,
+				Now:               tt.now,
+			})
+			if report.Valid() != tt.wantOK {
+				t.Errorf("Valid() = %v, want %v; failures: %+v", report.Valid(), tt.wantOK, report.Failures())
+			}
+		})
+	}
+}
+
+func TestVerifyProtocolReplay(t *testing.T) {
+	now := time.Now()
+	cache := NewReplayCache(10)
+	opts := ProtocolOptions{ExpectedRequestID: "_req1", ReplayCache: cache, Now: now}
+
+	response, assertion := buildProtocolAssertion("_assert-replay", "_req1", now)
+
+	first := VerifyProtocol(response, assertion, opts)
+	if !first.Valid() {
+		t.Fatalf("first use should be valid, failures: %+v", first.Failures())
+	}
+
+	second := VerifyProtocol(response, assertion, opts)
+	check := findProtocolCheck(second, "Assertion replay")
+	if check == nil || !check.Failed() {
+		t.Errorf("Assertion replay check = %+v, want failure", check)
+	}
+
+	t.Run("no cache skips replay check", func(t *testing.T) {
+		report := VerifyProtocol(response, assertion, ProtocolOptions{ExpectedRequestID: "_req1", Now: now})
+		if check := findProtocolCheck(report, "Assertion replay"); check == nil || check.Status != CheckSkipped {
+			t.Errorf("Assertion replay check = %+v, want skipped", check)
+		}
+	})
+}
+
+func TestVerifyProtocolFailedChecksDoNotConsumeAssertion(t *testing.T) {
+	now := time.Now()
+	cache := NewReplayCache(10)
+
+	// A post answering the wrong request carries the ID of a real assertion
+	response, assertion := buildProtocolAssertion("_assert-real", "_forged", now)
+	forged := VerifyProtocol(response, assertion, ProtocolOptions{ExpectedRequestID: "_req1", ReplayCache: cache, Now: now})
+	if forged.Valid() {
+		t.Fatal("response answering another request should be invalid")
+	}
+	if check := findProtocolCheck(forged, "Assertion replay"); check == nil || check.Status != CheckSkipped {
+		t.Errorf("Assertion replay check = %+v, want skipped", check)
+	}
+	if cache.Len() != 0 {
+		t.Fatalf("cache holds %d IDs after a failed response, want 0", cache.Len())
+	}
+
+	// The legitimate response is still accepted, and a failed replay of it is detected
+	response, assertion = buildProtocolAssertion("_assert-real", "_req1", now)
+	if report := VerifyProtocol(response, assertion, ProtocolOptions{ExpectedRequestID: "_req1", ReplayCache: cache, Now: now}); !report.Valid() {
+		t.Fatalf("legitimate response rejected: %+v", report.Failures())
+	}
+	replayed := VerifyProtocol(response, assertion, ProtocolOptions{ReplayCache: cache, Now: now})
+	if check := findProtocolCheck(replayed, "Assertion replay"); check == nil || !check.Failed() {
+		t.Errorf("Assertion replay check = %+v, want failure", check)
+	}
+}
+
+func TestVerifyProtocolMissingAssertion(t *testing.T) {
+	report := VerifyProtocol(&types.Response{InResponseTo: "_req1"}, nil, ProtocolOptions{ExpectedRequestID: "_req1"})
+	if report.Valid() {
+		t.Error("Valid() = true for response without assertion")
+	}
+}
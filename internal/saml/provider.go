@@ -4,6 +4,8 @@ import (
 	"crypto/rsa"
 	"crypto/tls"
 	"crypto/x509"
+	"fmt"
+	"time"
 
 	saml2 "github.com/russellhaering/gosaml2"
 	dsig "github.com/russellhaering/goxmldsig"
@@ -21,6 +23,16 @@ type ServiceProviderConfig struct {
 	IDPMetadata interface{} // Not used in gosaml2, kept for backward compatibility
 	IDPSSOURL   string
 	IDPIssuer   string
+	// IDPSLOURL is the IdP Single Logout endpoint LogoutRequests/Responses are sent to
+	IDPSLOURL string
+
+	// IDPCertificate is the PEM encoded IdP signing certificate captured from Duo
+	IDPCertificate string
+	// StrictSignatureValidation loads IDPCertificate into the IdP store and
+	// requires signed responses instead of skipping signature validation
+	StrictSignatureValidation bool
+	// ClockSkew is the tolerance allowed when checking assertion expiry
+	ClockSkew time.Duration
 }
 
 // NewSAMLServiceProvider creates a new SAML Service Provider instance using gosaml2
@@ -35,23 +47,40 @@ func NewSAMLServiceProvider(config ServiceProviderConfig) (*saml2.SAMLServicePro
 	// Create keystore from tls.Certificate (TLSCertKeyStore is a type alias for tls.Certificate)
 	certStore := dsig.TLSCertKeyStore(tlsCert)
 
-	// Create empty IDP certificate store (skip validation for test utility)
+	// Create IDP certificate store (empty unless strict validation is requested)
 	idpCertStore := dsig.MemoryX509CertificateStore{
 		Roots: []*x509.Certificate{},
 	}
 
+	if config.StrictSignatureValidation {
+		certs, err := ParseIDPCertificates(config.IDPCertificate)
+		if err != nil {
+			return nil, fmt.Errorf("strict signature validation requires a valid IdP certificate: %w", err)
+		}
+		idpCertStore.Roots = certs
+	}
+
 	// Create Service Provider
 	sp := &saml2.SAMLServiceProvider{
 		IdentityProviderSSOURL:      config.IDPSSOURL,
+		IdentityProviderSLOURL:      config.IDPSLOURL,
+		IdentityProviderSLOBinding:  saml2.BindingHttpRedirect,
 		IdentityProviderIssuer:      config.IDPIssuer,
 		ServiceProviderIssuer:       config.EntityID,
+		ServiceProviderSLOURL:       config.SLOURL,
 		AssertionConsumerServiceURL: config.ACSURL,
 		SignAuthnRequests:           true,
 		AudienceURI:                 config.EntityID,
 		IDPCertificateStore:         &idpCertStore,
 		SPKeyStore:                  certStore,
-		SkipSignatureValidation:     true, // Skip cert validation for testing tool
-		AllowMissingAttributes:      true, // Allow SAML responses without AttributeStatement
+		SkipSignatureValidation:     !config.StrictSignatureValidation, // Skip cert validation unless strict
+		AllowMissingAttributes:      true,                              // Allow SAML responses without AttributeStatement
+	}
+
+	// gosaml2 hard-fails expired subject confirmations using its own clock, so run it
+	// behind by the configured skew. The full validity window is checked by VerifyProtocol.
+	if config.ClockSkew > 0 {
+		sp.Clock = dsig.NewFakeClockAt(time.Now().Add(-config.ClockSkew))
 	}
 
 	return sp, nil
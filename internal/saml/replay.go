@@ -0,0 +1,83 @@
+package saml
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultReplayCacheSize is the number of assertion IDs remembered by a replay cache
+const DefaultReplayCacheSize = 1000
+
+// ReplayCache remembers recently consumed assertion IDs so a captured SAML response
+// cannot be posted to the ACS a second time. It is bounded: expired entries are
+// dropped first and the oldest entry is evicted once the capacity is reached.
+type ReplayCache struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]time.Time
+	order    []string
+}
+
+// NewReplayCache creates a replay cache holding at most capacity assertion IDs
+func NewReplayCache(capacity int) *ReplayCache {
+	if capacity <= 0 {
+		capacity = DefaultReplayCacheSize
+	}
+	return &ReplayCache{
+		capacity: capacity,
+		entries:  make(map[string]time.Time),
+	}
+}
+
+// Use records an assertion ID as consumed until expires. It returns false if the
+// ID was already consumed and has not expired yet, meaning the assertion is replayed.
+func (c *ReplayCache) Use(id string, expires, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.evictExpired(now)
+
+	if _, seen := c.entries[id]; seen {
+		return false
+	}
+
+	for len(c.order) >= c.capacity {
+		oldest := c.order[0]
+		c.order = c.order[1:]
+		delete(c.entries, oldest)
+	}
+
+	c.entries[id] = expires
+	c.order = append(c.order, id)
+	return true
+}
+
+// Seen reports whether an assertion ID has been consumed and has not expired yet,
+// without recording it
+func (c *ReplayCache) Seen(id string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires, seen := c.entries[id]
+	return seen && now.Before(expires)
+}
+
+// Len returns the number of assertion IDs currently remembered
+func (c *ReplayCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// evictExpired drops entries whose expiry has passed (assumes lock is held)
+func (c *ReplayCache) evictExpired(now time.Time) {
+	remaining := c.order[:0]
+	for _, id := range c.order {
+		if now.Before(c.entries[id]) {
+			remaining = append(remaining, id)
+		} else {
+			delete(c.entries, id)
+		}
+	}
+	c.order = remaining
+}
@@ -0,0 +1,63 @@
+package saml
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReplayCacheUse(t *testing.T) {
+	now := time.Now()
+	cache := NewReplayCache(10)
+
+	if !cache.Use("_a1", now.Add(time.Minute), now) {
+		t.Fatal("Use() first time = false, want true")
+	}
+	if cache.Use("_a1", now.Add(time.Minute), now) {
+		t.Fatal("Use() replayed ID = true, want false")
+	}
+
+	// Once the entry expires the ID is forgotten
+	later := now.Add(2 * time.Minute)
+	if !cache.Use("_a1", later.Add(time.Minute), later) {
+		t.Fatal("Use() after expiry = false, want true")
+	}
+}
+
+func TestReplayCacheSeen(t *testing.T) {
+	now := time.Now()
+	cache := NewReplayCache(10)
+
+	if cache.Seen("_a1", now) {
+		t.Fatal("Seen() unknown ID = true, want false")
+	}
+	if cache.Len() != 0 {
+		t.Fatal("Seen() must not record the ID")
+	}
+	cache.Use("_a1", now.Add(time.Minute), now)
+	if !cache.Seen("_a1", now) {
+		t.Error("Seen() consumed ID = false, want true")
+	}
+	if cache.Seen("_a1", now.Add(2*time.Minute)) {
+		t.Error("Seen() expired ID = true, want false")
+	}
+}
+
+func TestReplayCacheBounded(t *testing.T) {
+	now := time.Now()
+	cache := NewReplayCache(2)
+
+	cache.Use("_a1", now.Add(time.Hour), now)
+	cache.Use("_a2", now.Add(time.Hour), now)
+	cache.Use("_a3", now.Add(time.Hour), now)
+
+	if got := cache.Len(); got != 2 {
+		t.Fatalf("Len() = %d, want 2", got)
+	}
+	// The oldest entry was evicted to make room
+	if !cache.Use("_a1", now.Add(time.Hour), now) {
+		t.Error("Use() evicted ID = false, want true")
+	}
+	if cache.Use("_a3", now.Add(time.Hour), now) {
+		t.Error("Use() retained ID = true, want false")
+	}
+}
@@ -0,0 +1,250 @@
+package saml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/russellhaering/gosaml2/types"
+)
+
+// Trace records the messages exchanged during one SAML login together with the
+// assertion fields that matter when debugging an IdP integration
+type Trace struct {
+	CapturedAt            string `json:"captured_at"`
+	AuthnRequestID        string `json:"authn_request_id,omitempty"`
+	AuthnRequestXML       string `json:"authn_request_xml,omitempty"`
+	ResponseXML           string `json:"response_xml"`
+	DecryptedAssertionXML string `json:"decrypted_assertion_xml,omitempty"`
+
+	ResponseID   string `json:"response_id,omitempty"`
+	InResponseTo string `json:"in_response_to,omitempty"`
+	Destination  string `json:"destination,omitempty"`
+	Issuer       string `json:"issuer,omitempty"`
+	StatusCode   string `json:"status_code,omitempty"`
+	AssertionID  string `json:"assertion_id,omitempty"`
+
+	Conditions          *TraceConditions          `json:"conditions,omitempty"`
+	SubjectConfirmation *TraceSubjectConfirmation `json:"subject_confirmation,omitempty"`
+	AuthnContext        *TraceAuthnContext        `json:"authn_context,omitempty"`
+	Signatures          []TraceSignature          `json:"signatures,omitempty"`
+}
+
+// TraceConditions is the assertion Conditions element
+type TraceConditions struct {
+	NotBefore    string   `json:"not_before,omitempty"`
+	NotOnOrAfter string   `json:"not_on_or_after,omitempty"`
+	Audiences    []string `json:"audiences,omitempty"`
+	OneTimeUse   bool     `json:"one_time_use,omitempty"`
+}
+
+// TraceSubjectConfirmation is the assertion subject and its bearer confirmation
+type TraceSubjectConfirmation struct {
+	NameID       string `json:"name_id,omitempty"`
+	NameIDFormat string `json:"name_id_format,omitempty"`
+	Method       string `json:"method,omitempty"`
+	Recipient    string `json:"recipient,omitempty"`
+	NotOnOrAfter string `json:"not_on_or_after,omitempty"`
+	InResponseTo string `json:"in_response_to,omitempty"`
+}
+
+// TraceAuthnContext is the assertion AuthnStatement
+type TraceAuthnContext struct {
+	AuthnInstant        string `json:"authn_instant,omitempty"`
+	SessionIndex        string `json:"session_index,omitempty"`
+	SessionNotOnOrAfter string `json:"session_not_on_or_after,omitempty"`
+	ClassRef            string `json:"class_ref,omitempty"`
+}
+
+// TraceSignature describes one XML signature found in the response
+type TraceSignature struct {
+	Element                string           `json:"element"`
+	Reference              string           `json:"reference,omitempty"`
+	SignatureMethod        string           `json:"signature_method,omitempty"`
+	DigestMethod           string           `json:"digest_method,omitempty"`
+	CanonicalizationMethod string           `json:"canonicalization_method,omitempty"`
+	Certificate            *CertificateInfo `json:"certificate,omitempty"`
+}
+
+// BuildTrace assembles a trace from the pretty-printed AuthnRequest, the SAML response
+// as received and the response after decryption (identical to raw when not encrypted)
+func BuildTrace(authnRequestID, authnRequestXML string, rawResponse, decryptedResponse []byte) *Trace {
+	trace := &Trace{
+		CapturedAt:      time.Now().UTC().Format(time.RFC3339),
+		AuthnRequestID:  authnRequestID,
+		AuthnRequestXML: authnRequestXML,
+		ResponseXML:     string(rawResponse),
+	}
+
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(decryptedResponse); err != nil || doc.Root() == nil {
+		return trace
+	}
+
+	if string(decryptedResponse) != string(rawResponse) {
+		if assertion := doc.FindElement("//Assertion"); assertion != nil {
+			assertionDoc := etree.NewDocument()
+			assertionDoc.SetRoot(assertion.Copy())
+			trace.DecryptedAssertionXML = DocumentXML(assertionDoc)
+		}
+	}
+
+	trace.Signatures = traceSignatures(doc.Root())
+
+	response := &types.Response{}
+	if err := xml.Unmarshal(decryptedResponse, response); err != nil {
+		return trace
+	}
+
+	trace.ResponseID = response.ID
+	trace.InResponseTo = response.InResponseTo
+	trace.Destination = response.Destination
+	if response.Issuer != nil {
+		trace.Issuer = response.Issuer.Value
+	}
+	if response.Status != nil && response.Status.StatusCode != nil {
+		trace.StatusCode = response.Status.StatusCode.Value
+	}
+
+	if len(response.Assertions) == 0 {
+		return trace
+	}
+	assertion := response.Assertions[0]
+	trace.AssertionID = assertion.ID
+
+	if c := assertion.Conditions; c != nil {
+		trace.Conditions = &TraceConditions{
+			NotBefore:    c.NotBefore,
+			NotOnOrAfter: c.NotOnOrAfter,
+			OneTimeUse:   c.OneTimeUse != nil,
+		}
+		for _, restriction := range c.AudienceRestrictions {
+			for _, audience := range restriction.Audiences {
+				trace.Conditions.Audiences = append(trace.Conditions.Audiences, strings.TrimSpace(audience.Value))
+			}
+		}
+	}
+
+	if s := assertion.Subject; s != nil {
+		trace.SubjectConfirmation = &TraceSubjectConfirmation{}
+		if s.NameID != nil {
+			trace.SubjectConfirmation.NameID = strings.TrimSpace(s.NameID.Value)
+		}
+		if nameID := doc.FindElement("//Assertion/Subject/NameID"); nameID != nil {
+			trace.SubjectConfirmation.NameIDFormat = nameID.SelectAttrValue("Format", "")
+		}
+		if sc := s.SubjectConfirmation; sc != nil {
+			trace.SubjectConfirmation.Method = sc.Method
+			if data := sc.SubjectConfirmationData; data != nil {
+				trace.SubjectConfirmation.Recipient = data.Recipient
+				trace.SubjectConfirmation.NotOnOrAfter = data.NotOnOrAfter
+				trace.SubjectConfirmation.InResponseTo = data.InResponseTo
+			}
+		}
+	}
+
+	if a := assertion.AuthnStatement; a != nil {
+		trace.AuthnContext = &TraceAuthnContext{SessionIndex: a.SessionIndex}
+		if a.AuthnInstant != nil {
+			trace.AuthnContext.AuthnInstant = a.AuthnInstant.UTC().Format(time.RFC3339)
+		}
+		if a.SessionNotOnOrAfter != nil {
+			trace.AuthnContext.SessionNotOnOrAfter = a.SessionNotOnOrAfter.UTC().Format(time.RFC3339)
+		}
+		if a.AuthnContext != nil && a.AuthnContext.AuthnContextClassRef != nil {
+			trace.AuthnContext.ClassRef = strings.TrimSpace(a.AuthnContext.AuthnContextClassRef.Value)
+		}
+	}
+
+	return trace
+}
+
+// traceSignatures describes the signatures on the Response and its Assertions
+func traceSignatures(root *etree.Element) []TraceSignature {
+	var signatures []TraceSignature
+
+	elements := []*etree.Element{root}
+	elements = append(elements, root.SelectElements("Assertion")...)
+
+	for _, el := range elements {
+		sig := el.SelectElement("Signature")
+		if sig == nil {
+			continue
+		}
+
+		info := TraceSignature{Element: el.Tag}
+		if signedInfo := sig.SelectElement("SignedInfo"); signedInfo != nil {
+			if m := signedInfo.SelectElement("SignatureMethod"); m != nil {
+				info.SignatureMethod = m.SelectAttrValue("Algorithm", "")
+			}
+			if m := signedInfo.SelectElement("CanonicalizationMethod"); m != nil {
+				info.CanonicalizationMethod = m.SelectAttrValue("Algorithm", "")
+			}
+			if ref := signedInfo.SelectElement("Reference"); ref != nil {
+				info.Reference = ref.SelectAttrValue("URI", "")
+				if m := ref.SelectElement("DigestMethod"); m != nil {
+					info.DigestMethod = m.SelectAttrValue("Algorithm", "")
+				}
+			}
+		}
+		if certEl := sig.FindElement("./KeyInfo/X509Data/X509Certificate"); certEl != nil {
+			if certs, err := ParseIDPCertificates(certEl.Text()); err == nil {
+				described := DescribeCertificate(certs[0])
+				info.Certificate = &described
+			}
+		}
+
+		signatures = append(signatures, info)
+	}
+
+	return signatures
+}
+
+// ResponseXMLIndented returns the response pretty-printed for display. ResponseXML
+// itself is kept byte for byte so its signatures can still be verified.
+func (t *Trace) ResponseXMLIndented() string {
+	return IndentXML([]byte(t.ResponseXML))
+}
+
+// XML returns the trace as a single downloadable XML document holding the
+// AuthnRequest, the response as received and the decrypted assertion. The
+// messages are embedded without reformatting.
+func (t *Trace) XML() ([]byte, error) {
+	doc := etree.NewDocument()
+	doc.CreateProcInst("xml", `version="1.0" encoding="UTF-8"`)
+	doc.CreateCharData("\n")
+
+	root := doc.CreateElement("SAMLTrace")
+	root.CreateAttr("capturedAt", t.CapturedAt)
+	if t.AuthnRequestID != "" {
+		root.CreateAttr("authnRequestID", t.AuthnRequestID)
+	}
+
+	for _, part := range []struct {
+		name string
+		xml  string
+	}{
+		{"AuthnRequest", t.AuthnRequestXML},
+		{"Response", t.ResponseXML},
+		{"DecryptedAssertion", t.DecryptedAssertionXML},
+	} {
+		if part.xml == "" {
+			continue
+		}
+		partDoc := etree.NewDocument()
+		if err := partDoc.ReadFromString(part.xml); err != nil || partDoc.Root() == nil {
+			return nil, fmt.Errorf("trace %s is not valid XML: %v", part.name, err)
+		}
+		root.CreateCharData("\n")
+		el := root.CreateElement(part.name)
+		el.CreateCharData("\n")
+		el.AddChild(partDoc.Root())
+		el.CreateCharData("\n")
+	}
+	root.CreateCharData("\n")
+	doc.CreateCharData("\n")
+
+	return doc.WriteToBytes()
+}
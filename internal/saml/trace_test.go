@@ -0,0 +1,127 @@
+package saml
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/beevik/etree"
+)
+
+const testTraceResponseXML = `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_resp1" InResponseTo="_req1" Destination="https://sp.example.com/acs" Version="2.0" IssueInstant="2025-01-01T00:00:00Z">` +
+	`<saml:Issuer>http://idp.example.com</saml:Issuer>` +
+	`<samlp:Status><samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:Success"/></samlp:Status>` +
+	`<saml:Assertion ID="_assert1" Version="2.0" IssueInstant="2025-01-01T00:00:00Z">` +
+	`<saml:Issuer>http://idp.example.com</saml:Issuer>` +
+	`<saml:Subject><saml:NameID Format="urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress">user@example.com</saml:NameID>` +
+	`<saml:SubjectConfirmation Method="urn:oasis:names:tc:SAML:2.0:cm:bearer"><saml:SubjectConfirmationData InResponseTo="_req1" NotOnOrAfter="2025-01-01T00:05:00Z" Recipient="https://sp.example.com/acs"/></saml:SubjectConfirmation></saml:Subject>` +
+	`<saml:Conditions NotBefore="2025-01-01T00:00:00Z" NotOnOrAfter="2025-01-01T00:05:00Z"><saml:AudienceRestriction><saml:Audience>https://sp.example.com</saml:Audience></saml:AudienceRestriction></saml:Conditions>` +
+	`<saml:AuthnStatement AuthnInstant="2025-01-01T00:00:00Z" SessionIndex="_sess1"><saml:AuthnContext><saml:AuthnContextClassRef>urn:oasis:names:tc:SAML:2.0:ac:classes:PasswordProtectedTransport</saml:AuthnContextClassRef></saml:AuthnContext></saml:AuthnStatement>` +
+	`</saml:Assertion></samlp:Response>`
+
+func TestBuildTrace(t *testing.T) {
+	raw := []byte(testTraceResponseXML)
+	trace := BuildTrace("_req1", "<samlp:AuthnRequest ID=\"_req1\"/>", raw, raw)
+
+	if trace.ResponseXML != testTraceResponseXML {
+		t.Error("ResponseXML should hold the response exactly as received")
+	}
+	if trace.DecryptedAssertionXML != "" {
+		t.Error("DecryptedAssertionXML should be empty for a plain response")
+	}
+	if trace.InResponseTo != "_req1" || trace.Issuer != "http://idp.example.com" || trace.AssertionID != "_assert1" {
+		t.Errorf("response fields = %q / %q / %q", trace.InResponseTo, trace.Issuer, trace.AssertionID)
+	}
+	if trace.StatusCode != "urn:oasis:names:tc:SAML:2.0:status:Success" {
+		t.Errorf("StatusCode = %q", trace.StatusCode)
+	}
+
+	if trace.Conditions == nil || len(trace.Conditions.Audiences) != 1 || trace.Conditions.Audiences[0] != "https://sp.example.com" {
+		t.Errorf("Conditions = %+v", trace.Conditions)
+	}
+
+	sc := trace.SubjectConfirmation
+	if sc == nil || sc.NameID != "user@example.com" || sc.Recipient != "https://sp.example.com/acs" ||
+		sc.NameIDFormat != "urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress" {
+		t.Errorf("SubjectConfirmation = %+v", sc)
+	}
+
+	ac := trace.AuthnContext
+	if ac == nil || ac.SessionIndex != "_sess1" || ac.AuthnInstant != "2025-01-01T00:00:00Z" ||
+		!strings.HasSuffix(ac.ClassRef, "PasswordProtectedTransport") {
+		t.Errorf("AuthnContext = %+v", ac)
+	}
+
+	if len(trace.Signatures) != 0 {
+		t.Errorf("Signatures = %+v, want none", trace.Signatures)
+	}
+}
+
+func TestBuildTraceSignatures(t *testing.T) {
+	encoded, _ := buildSignedResponse(t, true, true)
+	raw, _ := base64.StdEncoding.DecodeString(encoded)
+
+	trace := BuildTrace("", "", raw, raw)
+	if len(trace.Signatures) != 2 {
+		t.Fatalf("len(Signatures) = %d, want 2", len(trace.Signatures))
+	}
+
+	for i, want := range []struct{ element, reference string }{{"Response", "#_resp1"}, {"Assertion", "#_assert1"}} {
+		sig := trace.Signatures[i]
+		if sig.Element != want.element || sig.Reference != want.reference {
+			t.Errorf("Signatures[%d] = %s %s, want %s %s", i, sig.Element, sig.Reference, want.element, want.reference)
+		}
+		if sig.SignatureMethod == "" || sig.DigestMethod == "" || sig.Certificate == nil {
+			t.Errorf("Signatures[%d] is missing details: %+v", i, sig)
+		}
+	}
+}
+
+func TestBuildTraceEncrypted(t *testing.T) {
+	sp := newSPCertificate(t)
+	plain, _ := buildSignedResponse(t, true, false)
+	encrypted := encryptResponseAssertion(t, plain, sp)
+
+	decrypted, _, err := DecryptResponse(encrypted, sp)
+	if err != nil {
+		t.Fatalf("DecryptResponse() error = %v", err)
+	}
+	rawEncrypted, _ := base64.StdEncoding.DecodeString(encrypted)
+	rawDecrypted, _ := base64.StdEncoding.DecodeString(decrypted)
+
+	trace := BuildTrace("", "", rawEncrypted, rawDecrypted)
+	if !strings.Contains(trace.ResponseXML, "EncryptedAssertion") {
+		t.Error("ResponseXML should hold the encrypted response as received")
+	}
+	if !strings.Contains(trace.DecryptedAssertionXML, "user@example.com") {
+		t.Errorf("DecryptedAssertionXML = %q, want the decrypted assertion", trace.DecryptedAssertionXML)
+	}
+	if trace.SubjectConfirmation == nil || trace.SubjectConfirmation.NameID != "user@example.com" {
+		t.Errorf("SubjectConfirmation = %+v, want fields from the decrypted assertion", trace.SubjectConfirmation)
+	}
+}
+
+func TestTraceXML(t *testing.T) {
+	raw := []byte(testTraceResponseXML)
+	trace := BuildTrace("_req1", "<samlp:AuthnRequest xmlns:samlp=\"urn:oasis:names:tc:SAML:2.0:protocol\" ID=\"_req1\"/>", raw, raw)
+
+	out, err := trace.XML()
+	if err != nil {
+		t.Fatalf("XML() error = %v", err)
+	}
+
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(out); err != nil {
+		t.Fatalf("XML() is not well-formed: %v", err)
+	}
+	if doc.FindElement("/SAMLTrace/AuthnRequest/AuthnRequest") == nil {
+		t.Error("trace is missing the AuthnRequest")
+	}
+	if doc.FindElement("/SAMLTrace/Response/Response") == nil {
+		t.Error("trace is missing the Response")
+	}
+	// The response is embedded without reformatting
+	if !strings.Contains(string(out), testTraceResponseXML[strings.Index(testTraceResponseXML, "<saml:Issuer>"):]) {
+		t.Error("trace does not contain the response verbatim")
+	}
+}
@@ -0,0 +1,325 @@
+package saml
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+	dsig "github.com/russellhaering/goxmldsig"
+	"github.com/russellhaering/goxmldsig/etreeutils"
+)
+
+const (
+	samlAssertionNamespace = "urn:oasis:names:tc:SAML:2.0:assertion"
+
+	// CheckPass, CheckFail and CheckSkipped are the possible outcomes of a validation check
+	CheckPass    = "pass"
+	CheckFail    = "fail"
+	CheckSkipped = "skipped"
+)
+
+// ValidationCheck is the outcome of a single validation step performed on a SAML response
+type ValidationCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"` // "pass", "fail" or "skipped"
+	Detail string `json:"detail,omitempty"`
+}
+
+// Passed reports whether the check succeeded
+func (c ValidationCheck) Passed() bool {
+	return c.Status == CheckPass
+}
+
+// Failed reports whether the check failed
+func (c ValidationCheck) Failed() bool {
+	return c.Status == CheckFail
+}
+
+// SignatureReport summarizes how the Response and Assertion signatures of a SAML
+// response hold up against the IdP certificates stored for the application
+type SignatureReport struct {
+	Strict       bool              `json:"strict"`
+	Certificates []CertificateInfo `json:"certificates,omitempty"`
+	Checks       []ValidationCheck `json:"checks"`
+}
+
+// CertificateInfo describes an IdP certificate used for signature validation
+type CertificateInfo struct {
+	Subject     string `json:"subject"`
+	Fingerprint string `json:"fingerprint"`
+	NotAfter    string `json:"not_after"`
+}
+
+// Valid reports whether the response would be accepted by a strict SP
+func (r *SignatureReport) Valid() bool {
+	if r == nil || len(r.Checks) == 0 {
+		return false
+	}
+	return len(r.Failures()) == 0
+}
+
+// Failures returns the checks that failed
+func (r *SignatureReport) Failures() []ValidationCheck {
+	if r == nil {
+		return nil
+	}
+	return failedChecks(r.Checks)
+}
+
+// failedChecks returns the checks in the list that failed
+func failedChecks(checks []ValidationCheck) []ValidationCheck {
+	var failures []ValidationCheck
+	for _, check := range checks {
+		if check.Failed() {
+			failures = append(failures, check)
+		}
+	}
+	return failures
+}
+
+// ParseIDPCertificates parses the IdP certificate(s) stored on an application.
+// Duo returns the certificate as PEM, but bare base64 DER (as found in metadata) is accepted too.
+func ParseIDPCertificates(data string) ([]*x509.Certificate, error) {
+	data = strings.TrimSpace(data)
+	if data == "" {
+		return nil, fmt.Errorf("no IdP certificate configured")
+	}
+
+	var certs []*x509.Certificate
+
+	if strings.Contains(data, "-----BEGIN") {
+		rest := []byte(data)
+		for {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse IdP certificate: %w", err)
+			}
+			certs = append(certs, cert)
+		}
+	} else {
+		der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(data), ""))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode IdP certificate: %w", err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse IdP certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificates found in IdP certificate data")
+	}
+
+	return certs, nil
+}
+
+// DescribeCertificate returns display information for a certificate
+func DescribeCertificate(cert *x509.Certificate) CertificateInfo {
+	fingerprint := sha256.Sum256(cert.Raw)
+	return CertificateInfo{
+		Subject:     cert.Subject.String(),
+		Fingerprint: fmt.Sprintf("%X", fingerprint),
+		NotAfter:    cert.NotAfter.Format(time.RFC3339),
+	}
+}
+
+// VerifySignatures checks the Response and Assertion signatures of a base64 encoded
+// SAML response independently, so each can be reported as its own pass/fail result.
+// Unlike gosaml2, which stops at the first signed element, both are always inspected.
+func VerifySignatures(encodedResponse string, certs []*x509.Certificate, strict bool) *SignatureReport {
+	return VerifySignaturesWithDecryption(encodedResponse, certs, strict, nil)
+}
+
+// VerifySignaturesWithDecryption behaves like VerifySignatures, but decrypts any
+// EncryptedAssertion with the SP key pair so the signature inside it can be checked too.
+// The Response signature is always checked on the response as received.
+func VerifySignaturesWithDecryption(encodedResponse string, certs []*x509.Certificate, strict bool, decryptCert *tls.Certificate) *SignatureReport {
+	report := &SignatureReport{Strict: strict}
+	for _, cert := range certs {
+		report.Certificates = append(report.Certificates, DescribeCertificate(cert))
+	}
+
+	if len(certs) == 0 {
+		report.Checks = append(report.Checks, ValidationCheck{
+			Name:   "IdP certificate",
+			Status: CheckFail,
+			Detail: "No IdP certificate is configured for this application",
+		})
+		return report
+	}
+
+	report.Checks = append(report.Checks, ValidationCheck{
+		Name:   "IdP certificate",
+		Status: CheckPass,
+		Detail: fmt.Sprintf("%d certificate(s) loaded into the IdP store", len(certs)),
+	})
+
+	now := time.Now()
+	for _, cert := range certs {
+		if now.After(cert.NotAfter) || now.Before(cert.NotBefore) {
+			report.Checks = append(report.Checks, ValidationCheck{
+				Name:   "IdP certificate validity",
+				Status: CheckFail,
+				Detail: fmt.Sprintf("Certificate %s is only valid from %s to %s", cert.Subject.CommonName,
+					cert.NotBefore.Format(time.RFC3339), cert.NotAfter.Format(time.RFC3339)),
+			})
+		}
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(encodedResponse)
+	if err != nil {
+		report.Checks = append(report.Checks, ValidationCheck{
+			Name:   "Response decoding",
+			Status: CheckFail,
+			Detail: fmt.Sprintf("SAMLResponse is not valid base64: %v", err),
+		})
+		return report
+	}
+
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(raw); err != nil || doc.Root() == nil {
+		report.Checks = append(report.Checks, ValidationCheck{
+			Name:   "Response decoding",
+			Status: CheckFail,
+			Detail: fmt.Sprintf("SAMLResponse is not valid XML: %v", err),
+		})
+		return report
+	}
+
+	store := &dsig.MemoryX509CertificateStore{Roots: certs}
+	root := doc.Root()
+
+	report.Checks = append(report.Checks, verifyElement("Response signature", root.Copy(), store))
+
+	assertionFound := false
+	encryptedFound := false
+	err = etreeutils.NSFindIterate(root, samlAssertionNamespace, "Assertion", func(ctx etreeutils.NSContext, el *etree.Element) error {
+		if el.Parent() != root {
+			return nil
+		}
+		assertionFound = true
+		detached, err := etreeutils.NSDetatch(ctx, el)
+		if err != nil {
+			report.Checks = append(report.Checks, ValidationCheck{
+				Name:   "Assertion signature",
+				Status: CheckFail,
+				Detail: fmt.Sprintf("Unable to isolate assertion: %v", err),
+			})
+			return nil
+		}
+		report.Checks = append(report.Checks, verifyElement("Assertion signature", detached, store))
+		return nil
+	})
+	if err != nil {
+		report.Checks = append(report.Checks, ValidationCheck{
+			Name:   "Assertion signature",
+			Status: CheckFail,
+			Detail: fmt.Sprintf("Unable to locate assertion: %v", err),
+		})
+	}
+
+	if !assertionFound {
+		_ = etreeutils.NSFindIterate(root, samlAssertionNamespace, "EncryptedAssertion", func(ctx etreeutils.NSContext, el *etree.Element) error {
+			if el.Parent() != root {
+				return nil
+			}
+			encryptedFound = true
+			if decryptCert == nil {
+				return nil
+			}
+			report.Checks = append(report.Checks, verifyEncryptedElement(ctx, el, decryptCert, store))
+			return nil
+		})
+
+		if !encryptedFound || decryptCert == nil {
+			detail := "Response does not contain an Assertion"
+			if encryptedFound {
+				detail = "Assertion is encrypted and cannot be inspected"
+			}
+			report.Checks = append(report.Checks, ValidationCheck{
+				Name:   "Assertion signature",
+				Status: CheckSkipped,
+				Detail: detail,
+			})
+		}
+	}
+
+	signed := false
+	for _, check := range report.Checks {
+		if check.Passed() && (check.Name == "Response signature" || check.Name == "Assertion signature") {
+			signed = true
+		}
+	}
+	if !signed && len(report.Failures()) == 0 {
+		report.Checks = append(report.Checks, ValidationCheck{
+			Name:   "Signature present",
+			Status: CheckFail,
+			Detail: "Neither the Response nor the Assertion carries a verifiable signature",
+		})
+	}
+
+	return report
+}
+
+// verifyEncryptedElement decrypts an EncryptedAssertion and validates the signature of
+// the assertion inside it
+func verifyEncryptedElement(ctx etreeutils.NSContext, el *etree.Element, cert *tls.Certificate, store dsig.X509CertificateStore) ValidationCheck {
+	encrypted, err := unmarshalEncryptedAssertion(ctx, el)
+	if err == nil {
+		var decrypted *etree.Element
+		if decrypted, err = decryptAssertion(encrypted, cert); err == nil {
+			check := verifyElement("Assertion signature", decrypted, store)
+			if check.Passed() {
+				check.Detail = "Signature of the decrypted assertion verified against the IdP certificate"
+			}
+			return check
+		}
+	}
+
+	return ValidationCheck{
+		Name:   "Assertion signature",
+		Status: CheckFail,
+		Detail: fmt.Sprintf("Unable to decrypt assertion: %v", err),
+	}
+}
+
+// verifyElement validates the enveloped signature of a single element
+func verifyElement(name string, el *etree.Element, store dsig.X509CertificateStore) ValidationCheck {
+	ctx := dsig.NewDefaultValidationContext(store)
+	if _, err := ctx.Validate(el); err != nil {
+		if err == dsig.ErrMissingSignature {
+			return ValidationCheck{
+				Name:   name,
+				Status: CheckSkipped,
+				Detail: "Element is not signed",
+			}
+		}
+		return ValidationCheck{
+			Name:   name,
+			Status: CheckFail,
+			Detail: err.Error(),
+		}
+	}
+
+	return ValidationCheck{
+		Name:   name,
+		Status: CheckPass,
+		Detail: "Signature verified against the IdP certificate",
+	}
+}
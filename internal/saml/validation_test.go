@@ -0,0 +1,262 @@
+package saml
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/beevik/etree"
+	dsig "github.com/russellhaering/goxmldsig"
+)
+
+const testResponseXML = `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_resp1" Version="2.0" IssueInstant="2025-01-01T00:00:00Z">
+<saml:Issuer xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion">http://idp.example.com</saml:Issuer>
+<saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_assert1" Version="2.0" IssueInstant="2025-01-01T00:00:00Z">
+<saml:Issuer>http://idp.example.com</saml:Issuer>
+<saml:Subject><saml:NameID>user@example.com</saml:NameID></saml:Subject>
+</saml:Assertion>
+</samlp:Response>`
+
+// buildSignedResponse returns a base64 encoded response, optionally signing the
+// assertion and/or the response with the given test certificate
+func buildSignedResponse(t *testing.T, signAssertion, signResponse bool) (string, string) {
+	t.Helper()
+
+	cert, key, err := GenerateSelfSignedCert("idp.example.com")
+	if err != nil {
+		t.Fatalf("GenerateSelfSignedCert() error = %v", err)
+	}
+	signer := dsig.NewDefaultSigningContext(dsig.TLSCertKeyStore(tls.Certificate{
+		Certificate: [][]byte{cert.Raw},
+		PrivateKey:  key,
+		Leaf:        cert,
+	}))
+	// IdPs (Duo included) sign with exclusive canonicalization
+	signer.Canonicalizer = dsig.MakeC14N10ExclusiveCanonicalizerWithPrefixList("")
+
+	doc := etree.NewDocument()
+	if err := doc.ReadFromString(testResponseXML); err != nil {
+		t.Fatalf("failed to parse test response: %v", err)
+	}
+	root := doc.Root()
+
+	if signAssertion {
+		assertion := root.FindElement("./Assertion")
+		if assertion == nil {
+			t.Fatal("test response has no assertion")
+		}
+		signed, err := signer.SignEnveloped(assertion)
+		if err != nil {
+			t.Fatalf("failed to sign assertion: %v", err)
+		}
+		root.RemoveChild(assertion)
+		root.AddChild(signed)
+	}
+
+	if signResponse {
+		signed, err := signer.SignEnveloped(root)
+		if err != nil {
+			t.Fatalf("failed to sign response: %v", err)
+		}
+		doc.SetRoot(signed)
+	}
+
+	raw, err := doc.WriteToBytes()
+	if err != nil {
+		t.Fatalf("failed to serialize response: %v", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(raw), CertToPEM(cert)
+}
+
+func findCheck(report *SignatureReport, name string) *ValidationCheck {
+	for i := range report.Checks {
+		if report.Checks[i].Name == name {
+			return &report.Checks[i]
+		}
+	}
+	return nil
+}
+
+func TestParseIDPCertificates(t *testing.T) {
+	cert, _, err := GenerateSelfSignedCert("idp.example.com")
+	if err != nil {
+		t.Fatalf("GenerateSelfSignedCert() error = %v", err)
+	}
+	certPEM := CertToPEM(cert)
+	bareBase64 := base64.StdEncoding.EncodeToString(cert.Raw)
+
+	tests := []struct {
+		name      string
+		data      string
+		wantCount int
+		wantErr   bool
+	}{
+		{name: "PEM certificate", data: certPEM, wantCount: 1},
+		{name: "multiple PEM certificates", data: certPEM + certPEM, wantCount: 2},
+		{name: "bare base64 DER", data: bareBase64, wantCount: 1},
+		{name: "wrapped base64 DER", data: bareBase64[:40] + "\n" + bareBase64[40:], wantCount: 1},
+		{name: "empty", data: "", wantErr: true},
+		{name: "garbage", data: "not a certificate", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			certs, err := ParseIDPCertificates(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseIDPCertificates() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(certs) != tt.wantCount {
+				t.Errorf("ParseIDPCertificates() returned %d certs, want %d", len(certs), tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestVerifySignatures(t *testing.T) {
+	t.Run("signed assertion and response", func(t *testing.T) {
+		encoded, certPEM := buildSignedResponse(t, true, true)
+		certs, err := ParseIDPCertificates(certPEM)
+		if err != nil {
+			t.Fatalf("ParseIDPCertificates() error = %v", err)
+		}
+
+		report := VerifySignatures(encoded, certs, true)
+		if !report.Valid() {
+			t.Fatalf("expected valid report, failures: %+v", report.Failures())
+		}
+		if check := findCheck(report, "Response signature"); check == nil || !check.Passed() {
+			t.Errorf("Response signature check = %+v, want pass", check)
+		}
+		if check := findCheck(report, "Assertion signature"); check == nil || !check.Passed() {
+			t.Errorf("Assertion signature check = %+v, want pass", check)
+		}
+		if !report.Strict {
+			t.Error("report should be marked strict")
+		}
+		if len(report.Certificates) != 1 {
+			t.Errorf("expected 1 certificate in report, got %d", len(report.Certificates))
+		}
+	})
+
+	t.Run("only assertion signed", func(t *testing.T) {
+		encoded, certPEM := buildSignedResponse(t, true, false)
+		certs, _ := ParseIDPCertificates(certPEM)
+
+		report := VerifySignatures(encoded, certs, false)
+		if !report.Valid() {
+			t.Fatalf("expected valid report, failures: %+v", report.Failures())
+		}
+		if check := findCheck(report, "Response signature"); check == nil || check.Status != CheckSkipped {
+			t.Errorf("Response signature check = %+v, want skipped", check)
+		}
+	})
+
+	t.Run("wrong certificate", func(t *testing.T) {
+		encoded, _ := buildSignedResponse(t, true, true)
+		other, _, err := GenerateSelfSignedCert("attacker.example.com")
+		if err != nil {
+			t.Fatalf("GenerateSelfSignedCert() error = %v", err)
+		}
+		certs, _ := ParseIDPCertificates(CertToPEM(other))
+
+		report := VerifySignatures(encoded, certs, true)
+		if report.Valid() {
+			t.Fatal("expected invalid report when signed by an unknown certificate")
+		}
+		if check := findCheck(report, "Response signature"); check == nil || !check.Failed() {
+			t.Errorf("Response signature check = %+v, want fail", check)
+		}
+	})
+
+	t.Run("tampered response", func(t *testing.T) {
+		encoded, certPEM := buildSignedResponse(t, true, true)
+		certs, _ := ParseIDPCertificates(certPEM)
+
+		raw, _ := base64.StdEncoding.DecodeString(encoded)
+		tampered := strings.Replace(string(raw), "user@example.com", "admin@example.com", 1)
+
+		report := VerifySignatures(base64.StdEncoding.EncodeToString([]byte(tampered)), certs, true)
+		if report.Valid() {
+			t.Fatal("expected invalid report for tampered response")
+		}
+	})
+
+	t.Run("unsigned response", func(t *testing.T) {
+		encoded, certPEM := buildSignedResponse(t, false, false)
+		certs, _ := ParseIDPCertificates(certPEM)
+
+		report := VerifySignatures(encoded, certs, true)
+		if report.Valid() {
+			t.Fatal("expected invalid report for unsigned response")
+		}
+		if check := findCheck(report, "Signature present"); check == nil || !check.Failed() {
+			t.Errorf("Signature present check = %+v, want fail", check)
+		}
+	})
+
+	t.Run("no certificate", func(t *testing.T) {
+		encoded, _ := buildSignedResponse(t, true, true)
+
+		report := VerifySignatures(encoded, nil, true)
+		if report.Valid() {
+			t.Fatal("expected invalid report without IdP certificate")
+		}
+	})
+
+	t.Run("invalid base64", func(t *testing.T) {
+		_, certPEM := buildSignedResponse(t, false, false)
+		certs, _ := ParseIDPCertificates(certPEM)
+
+		report := VerifySignatures("%%%not-base64", certs, false)
+		if check := findCheck(report, "Response decoding"); check == nil || !check.Failed() {
+			t.Errorf("Response decoding check = %+v, want fail", check)
+		}
+	})
+}
+
+func TestNewSAMLServiceProvider_Strict(t *testing.T) {
+	cert, key, err := GenerateSelfSignedCert("sp.example.com")
+	if err != nil {
+		t.Fatalf("GenerateSelfSignedCert() error = %v", err)
+	}
+	idpCert, _, err := GenerateSelfSignedCert("idp.example.com")
+	if err != nil {
+		t.Fatalf("GenerateSelfSignedCert() error = %v", err)
+	}
+
+	config := ServiceProviderConfig{
+		AppID:                     "strict-app",
+		EntityID:                  "http://example.com/entity",
+		ACSURL:                    "http://example.com/acs",
+		Certificate:               cert,
+		PrivateKey:                key,
+		IDPSSOURL:                 "http://idp.example.com/sso",
+		IDPIssuer:                 "http://idp.example.com",
+		IDPCertificate:            CertToPEM(idpCert),
+		StrictSignatureValidation: true,
+	}
+
+	sp, err := NewSAMLServiceProvider(config)
+	if err != nil {
+		t.Fatalf("NewSAMLServiceProvider() error = %v", err)
+	}
+
+	if sp.SkipSignatureValidation {
+		t.Error("SkipSignatureValidation should be false in strict mode")
+	}
+
+	store, ok := sp.IDPCertificateStore.(*dsig.MemoryX509CertificateStore)
+	if !ok {
+		t.Fatalf("IDPCertificateStore has unexpected type %T", sp.IDPCertificateStore)
+	}
+	if len(store.Roots) != 1 {
+		t.Errorf("IDP certificate store has %d roots, want 1", len(store.Roots))
+	}
+
+	config.IDPCertificate = ""
+	if _, err := NewSAMLServiceProvider(config); err == nil {
+		t.Error("NewSAMLServiceProvider() should fail in strict mode without an IdP certificate")
+	}
+}
@@ -0,0 +1,178 @@
+package sessionstore
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// boltBucket holds every session record
+	boltBucket = "sessions"
+
+	// boltGCInterval is how often expired sessions are purged from the database
+	boltGCInterval = 10 * time.Minute
+)
+
+// BoltStorage is a fiber.Storage backed by a local bbolt database file. Each record is
+// the expiry (Unix seconds, 0 for none) followed by the session data.
+type BoltStorage struct {
+	db   *bolt.DB
+	done chan struct{}
+}
+
+// NewBoltStorage opens (or creates) the bbolt database at path and starts purging
+// expired sessions in the background
+func NewBoltStorage(path string) (*BoltStorage, error) {
+	if dir := filepath.Dir(path); dir != "" && dir != "." {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, fmt.Errorf("failed to create session directory: %w", err)
+		}
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session database: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(boltBucket))
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create session bucket: %w", err)
+	}
+
+	s := &BoltStorage{db: db, done: make(chan struct{})}
+	go s.gcLoop()
+	return s, nil
+}
+
+// Get returns the session data for key, or nil if it does not exist or has expired
+func (s *BoltStorage) Get(key string) ([]byte, error) {
+	if key == "" {
+		return nil, nil
+	}
+
+	var value []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		record := tx.Bucket([]byte(boltBucket)).Get([]byte(key))
+		if len(record) < 8 {
+			return nil
+		}
+		if expired(record, time.Now()) {
+			return nil
+		}
+		// bbolt memory is only valid inside the transaction
+		value = append([]byte(nil), record[8:]...)
+		return nil
+	})
+	return value, err
+}
+
+// Set stores the session data for key; an exp of 0 means it never expires
+func (s *BoltStorage) Set(key string, val []byte, exp time.Duration) error {
+	if key == "" || len(val) == 0 {
+		return nil
+	}
+
+	record := make([]byte, 8+len(val))
+	if exp > 0 {
+		binary.BigEndian.PutUint64(record, uint64(time.Now().Add(exp).Unix()))
+	}
+	copy(record[8:], val)
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(boltBucket)).Put([]byte(key), record)
+	})
+}
+
+// Delete removes the session data for key
+func (s *BoltStorage) Delete(key string) error {
+	if key == "" {
+		return nil
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(boltBucket)).Delete([]byte(key))
+	})
+}
+
+// Reset removes every session
+func (s *BoltStorage) Reset() error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket([]byte(boltBucket)); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucket([]byte(boltBucket))
+		return err
+	})
+}
+
+// Close stops the background purge and closes the database
+func (s *BoltStorage) Close() error {
+	close(s.done)
+	return s.db.Close()
+}
+
+// GetWithContext is Get; bbolt operations are local and not cancellable
+func (s *BoltStorage) GetWithContext(_ context.Context, key string) ([]byte, error) {
+	return s.Get(key)
+}
+
+// SetWithContext is Set; bbolt operations are local and not cancellable
+func (s *BoltStorage) SetWithContext(_ context.Context, key string, val []byte, exp time.Duration) error {
+	return s.Set(key, val, exp)
+}
+
+// DeleteWithContext is Delete; bbolt operations are local and not cancellable
+func (s *BoltStorage) DeleteWithContext(_ context.Context, key string) error {
+	return s.Delete(key)
+}
+
+// ResetWithContext is Reset; bbolt operations are local and not cancellable
+func (s *BoltStorage) ResetWithContext(_ context.Context) error {
+	return s.Reset()
+}
+
+// gcLoop periodically purges expired sessions until the storage is closed
+func (s *BoltStorage) gcLoop() {
+	ticker := time.NewTicker(boltGCInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case now := <-ticker.C:
+			s.purgeExpired(now)
+		}
+	}
+}
+
+// purgeExpired deletes every session that expired before now
+func (s *BoltStorage) purgeExpired(now time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(boltBucket))
+		cursor := bucket.Cursor()
+		for key, record := cursor.First(); key != nil; key, record = cursor.Next() {
+			if len(record) < 8 || expired(record, now) {
+				if err := cursor.Delete(); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// expired reports whether a record's expiry prefix is set and in the past
+func expired(record []byte, now time.Time) bool {
+	expiry := int64(binary.BigEndian.Uint64(record[:8]))
+	return expiry != 0 && expiry <= now.Unix()
+}
@@ -0,0 +1,103 @@
+package sessionstore
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestBoltStorage(t *testing.T) *BoltStorage {
+	t.Helper()
+	s, err := NewBoltStorage(filepath.Join(t.TempDir(), "sessions", "sessions.db"))
+	if err != nil {
+		t.Fatalf("NewBoltStorage() error = %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestBoltStorageSetGetDelete(t *testing.T) {
+	s := newTestBoltStorage(t)
+
+	if err := s.Set("sid", []byte("data"), time.Hour); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	got, err := s.Get("sid")
+	if err != nil || string(got) != "data" {
+		t.Fatalf("Get() = %q, %v, want data", got, err)
+	}
+
+	if err := s.Delete("sid"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if got, _ := s.Get("sid"); got != nil {
+		t.Errorf("Get() after Delete = %q, want nil", got)
+	}
+
+	if got, err := s.Get("missing"); got != nil || err != nil {
+		t.Errorf("Get(missing) = %q, %v, want nil, nil", got, err)
+	}
+}
+
+func TestBoltStorageExpiry(t *testing.T) {
+	s := newTestBoltStorage(t)
+
+	if err := s.Set("expired", []byte("old"), time.Nanosecond); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := s.Set("forever", []byte("kept"), 0); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	time.Sleep(1100 * time.Millisecond)
+
+	if got, _ := s.Get("expired"); got != nil {
+		t.Errorf("Get(expired) = %q, want nil", got)
+	}
+
+	if err := s.purgeExpired(time.Now()); err != nil {
+		t.Fatalf("purgeExpired() error = %v", err)
+	}
+	if got, _ := s.Get("forever"); string(got) != "kept" {
+		t.Errorf("Get(forever) = %q, want kept", got)
+	}
+}
+
+func TestBoltStorageReset(t *testing.T) {
+	s := newTestBoltStorage(t)
+
+	s.Set("a", []byte("1"), 0)
+	s.Set("b", []byte("2"), 0)
+	if err := s.Reset(); err != nil {
+		t.Fatalf("Reset() error = %v", err)
+	}
+	if got, _ := s.Get("a"); got != nil {
+		t.Errorf("Get(a) after Reset = %q, want nil", got)
+	}
+}
+
+func TestBoltStoragePersistsAcrossReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.db")
+
+	s, err := NewBoltStorage(path)
+	if err != nil {
+		t.Fatalf("NewBoltStorage() error = %v", err)
+	}
+	s.Set("sid", []byte("data"), time.Hour)
+	s.Close()
+
+	reopened, err := NewBoltStorage(path)
+	if err != nil {
+		t.Fatalf("NewBoltStorage() reopen error = %v", err)
+	}
+	defer reopened.Close()
+
+	if got, _ := reopened.Get("sid"); string(got) != "data" {
+		t.Errorf("Get() after reopen = %q, want data", got)
+	}
+}
+
+func TestNewRedisStorageInvalidURL(t *testing.T) {
+	if _, err := NewRedisStorage("http://localhost:6379"); err == nil {
+		t.Error("NewRedisStorage() should reject a non-redis URL")
+	}
+}
@@ -0,0 +1,116 @@
+package sessionstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// redisKeyPrefix namespaces session keys on a shared Redis server
+	redisKeyPrefix = "uet:session:"
+
+	// redisTimeout bounds each Redis operation made without a caller context
+	redisTimeout = 5 * time.Second
+)
+
+// RedisStorage is a fiber.Storage backed by a Redis-compatible server (Redis, Valkey,
+// KeyDB, ...) so sessions can be shared between replicas
+type RedisStorage struct {
+	client *redis.Client
+}
+
+// NewRedisStorage connects to the server at a redis:// or rediss:// URL, e.g.
+// rediss://:password@redis.example.com:6380/0
+func NewRedisStorage(redisURL string) (*RedisStorage, error) {
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session redis_url: %w", err)
+	}
+
+	client := redis.NewClient(opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to session Redis at %s: %w", opts.Addr, err)
+	}
+
+	return &RedisStorage{client: client}, nil
+}
+
+// GetWithContext returns the session data for key, or nil if it does not exist
+func (s *RedisStorage) GetWithContext(ctx context.Context, key string) ([]byte, error) {
+	if key == "" {
+		return nil, nil
+	}
+	val, err := s.client.Get(ctx, redisKeyPrefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	return val, err
+}
+
+// SetWithContext stores the session data for key; an exp of 0 means it never expires
+func (s *RedisStorage) SetWithContext(ctx context.Context, key string, val []byte, exp time.Duration) error {
+	if key == "" || len(val) == 0 {
+		return nil
+	}
+	return s.client.Set(ctx, redisKeyPrefix+key, val, exp).Err()
+}
+
+// DeleteWithContext removes the session data for key
+func (s *RedisStorage) DeleteWithContext(ctx context.Context, key string) error {
+	if key == "" {
+		return nil
+	}
+	return s.client.Del(ctx, redisKeyPrefix+key).Err()
+}
+
+// ResetWithContext removes every session key; other data on the server is left alone
+func (s *RedisStorage) ResetWithContext(ctx context.Context) error {
+	iter := s.client.Scan(ctx, 0, redisKeyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		if err := s.client.Del(ctx, iter.Val()).Err(); err != nil {
+			return err
+		}
+	}
+	return iter.Err()
+}
+
+// Get returns the session data for key, or nil if it does not exist
+func (s *RedisStorage) Get(key string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	return s.GetWithContext(ctx, key)
+}
+
+// Set stores the session data for key; an exp of 0 means it never expires
+func (s *RedisStorage) Set(key string, val []byte, exp time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	return s.SetWithContext(ctx, key, val, exp)
+}
+
+// Delete removes the session data for key
+func (s *RedisStorage) Delete(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	return s.DeleteWithContext(ctx, key)
+}
+
+// Reset removes every session key
+func (s *RedisStorage) Reset() error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	return s.ResetWithContext(ctx)
+}
+
+// Close closes the connection pool
+func (s *RedisStorage) Close() error {
+	return s.client.Close()
+}
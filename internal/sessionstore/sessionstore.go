@@ -0,0 +1,40 @@
+// Package sessionstore provides the persistent fiber.Storage backends used for the
+// session store, so in-flight SAML/OIDC/Universal Prompt flows survive restarts and
+// can be shared between replicas
+package sessionstore
+
+import (
+	"fmt"
+	"log"
+
+	"user_experience_toolkit/internal/config"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+var (
+	_ fiber.Storage = (*BoltStorage)(nil)
+	_ fiber.Storage = (*RedisStorage)(nil)
+)
+
+// New returns the storage for the configured session backend. The memory backend
+// returns a nil storage so the session middleware uses its built-in memory store.
+func New(cfg config.SessionConfig) (fiber.Storage, error) {
+	switch cfg.BackendName() {
+	case config.SessionBackendMemory:
+		log.Printf("[SessionStore] Using in-memory session storage")
+		return nil, nil
+	case config.SessionBackendFile:
+		path := cfg.FilePath
+		if path == "" {
+			path = config.DefaultSessionFile
+		}
+		log.Printf("[SessionStore] Using file session storage: %s", path)
+		return NewBoltStorage(path)
+	case config.SessionBackendRedis:
+		log.Printf("[SessionStore] Using Redis session storage")
+		return NewRedisStorage(cfg.RedisURL)
+	default:
+		return nil, fmt.Errorf("unsupported session backend: %s", cfg.Backend)
+	}
+}